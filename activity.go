@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 动态列表默认和最多返回的条数
+const (
+	defaultActivityLimit = 20
+	maxActivityLimit     = 100
+)
+
+// ActivityEntry 动态列表中的一次投票
+type ActivityEntry struct {
+	ID      int64     `json:"id"` // 翻页游标，作为 before 参数获取更早的动态
+	VotedAt time.Time `json:"voted_at"`
+	Voter   string    `json:"voter,omitempty"`   // 仅实名投票
+	Options []string  `json:"options,omitempty"` // 结果隐藏时不返回
+}
+
+// RecentVotes 按时间倒序返回 limit 次投票。before 为上一页最后一条的 ID 时从其之后继续（键集分页，
+// 按 (voted_at, id) 排序，翻页期间有新投票也不会重复或遗漏），为 0 时从最新一条开始。
+// withChoices 为 false 时不读取所选选项
+func (ps *PollStore) RecentVotes(pollID string, before int64, limit int, withChoices bool) ([]ActivityEntry, error) {
+	query := `SELECT id, voted_at, voter, options FROM vote_events WHERE poll_id = ?`
+	args := []interface{}{pollID}
+	if before > 0 {
+		query += ` AND (voted_at, id) < (SELECT voted_at, id FROM vote_events WHERE id = ? AND poll_id = ?)`
+		args = append(args, before, pollID)
+	}
+	query += ` ORDER BY voted_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := ps.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []ActivityEntry{}
+	for rows.Next() {
+		var e ActivityEntry
+		var options string
+		if err := rows.Scan(&e.ID, &e.VotedAt, &e.Voter, &options); err != nil {
+			return nil, err
+		}
+		if withChoices && options != "" {
+			e.Options = strings.Split(options, "|||")
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// apiActivityHandler 最近投票动态。匿名投票不含投票人（本来也不记录），结果隐藏时不含所选选项
+func apiActivityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultActivityLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid limit",
+			})
+			return
+		}
+		limit = min(n, maxActivityLimit)
+	}
+
+	var before int64
+	if v := r.URL.Query().Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid before",
+			})
+			return
+		}
+		before = n
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	// 投票动态的条数就是投票次数，不公开投票人数时只对管理员开放
+	if poll.HideVoterCount && !isAdmin(r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "voter count is hidden for this poll",
+		})
+		return
+	}
+
+	withChoices := !resultsWithheldFor(poll, r)
+	entries, err := store.RecentVotes(poll.ID, before, limit, withChoices)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if poll.Anonymous {
+		for i := range entries {
+			entries[i].Voter = ""
+		}
+	}
+	// 隐藏选项照常计票，但不公开谁选了它们
+	if len(poll.HiddenOptions) > 0 && !isAdmin(r) {
+		for i := range entries {
+			entries[i].Options = slices.DeleteFunc(entries[i].Options, func(opt string) bool {
+				return slices.Contains(poll.HiddenOptions, opt)
+			})
+		}
+	}
+
+	resp := map[string]interface{}{
+		"success":  true,
+		"activity": entries,
+	}
+	// 取满一页时可能还有更早的动态
+	if len(entries) == limit {
+		resp["next_before"] = entries[len(entries)-1].ID
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// VotesByHourOfDay 按投票时间在 loc 时区中的小时（0-23）统计投票次数，覆盖投票的整个周期
+func (ps *PollStore) VotesByHourOfDay(pollID string, loc *time.Location) ([24]int, error) {
+	var hours [24]int
+	rows, err := ps.db.Query(`SELECT voted_at FROM vote_events WHERE poll_id = ?`, pollID)
+	if err != nil {
+		return hours, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var votedAt time.Time
+		if err := rows.Scan(&votedAt); err != nil {
+			return hours, err
+		}
+		hours[votedAt.In(loc).Hour()]++
+	}
+	return hours, rows.Err()
+}
+
+// parseTimezone 解析时区参数：IANA 名称（如 Asia/Shanghai）或 UTC 偏移（如 +08:00、-0530），空串为 UTC
+func parseTimezone(s string) (*time.Location, error) {
+	if s == "" {
+		return time.UTC, nil
+	}
+	if s[0] == '+' || s[0] == '-' {
+		for _, layout := range []string{"-07:00", "-0700", "-07"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				_, offset := t.Zone()
+				return time.FixedZone(s, offset), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timezone %q", s)
+	}
+	return time.LoadLocation(s)
+}
+
+// apiHourHistogramHandler 各小时的投票次数，tz 参数指定按哪个时区划分小时，默认 UTC
+func apiHourHistogramHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	loc, err := parseTimezone(r.URL.Query().Get("tz"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "invalid tz",
+		})
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	// 各小时投票次数之和就是投票次数，不公开投票人数时只对管理员开放
+	if poll.HideVoterCount && !isAdmin(r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "voter count is hidden for this poll",
+		})
+		return
+	}
+
+	hours, err := store.VotesByHourOfDay(poll.ID, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"timezone": loc.String(),
+		"hours":    hours,
+	})
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type activityResponse struct {
+	Activity   []ActivityEntry `json:"activity"`
+	NextBefore int64           `json:"next_before"`
+}
+
+func getActivity(t *testing.T, path string, headers ...string) activityResponse {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, path, nil, headers...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s 状态码 = %d: %s", path, rec.Code, rec.Body.String())
+	}
+	var resp activityResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestActivityNewestFirst(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "anonymous": false})
+	for i := 1; i <= 5; i++ {
+		option := "a"
+		if i%2 == 0 {
+			option = "b"
+		}
+		if rec := voteAs(t, pollID, fmt.Sprintf("voter-%d", i), option); decodeBody(t, rec)["success"] != true {
+			t.Fatalf("投票失败: %s", rec.Body.String())
+		}
+	}
+
+	page := getActivity(t, "/api/poll/"+pollID+"/activity?limit=3")
+	if len(page.Activity) != 3 {
+		t.Fatalf("limit=3 返回了 %d 条", len(page.Activity))
+	}
+	for i, want := range []string{"voter-5", "voter-4", "voter-3"} {
+		e := page.Activity[i]
+		if e.Voter != want || len(e.Options) != 1 {
+			t.Errorf("第 %d 条 = %+v，期望 %s", i, e, want)
+		}
+		if i > 0 && e.VotedAt.After(page.Activity[i-1].VotedAt) {
+			t.Errorf("第 %d 条比前一条更新", i)
+		}
+	}
+	if page.NextBefore != page.Activity[2].ID {
+		t.Errorf("next_before = %d，期望 %d", page.NextBefore, page.Activity[2].ID)
+	}
+
+	rest := getActivity(t, fmt.Sprintf("/api/poll/%s/activity?limit=3&before=%d", pollID, page.NextBefore))
+	if len(rest.Activity) != 2 || rest.Activity[0].Voter != "voter-2" || rest.Activity[1].Voter != "voter-1" || rest.NextBefore != 0 {
+		t.Errorf("第二页 = %+v", rest)
+	}
+
+	for _, limit := range []string{"0", "-1", "x"} {
+		if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/activity?limit="+limit, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s 状态码 = %d，期望 400", limit, rec.Code)
+		}
+	}
+}
+
+func TestActivityOfAnonymousHiddenPoll(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_results": true})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+
+	page := getActivity(t, "/api/poll/"+pollID+"/activity")
+	if len(page.Activity) != 2 {
+		t.Fatalf("返回了 %d 条，期望 2", len(page.Activity))
+	}
+	for _, e := range page.Activity {
+		if e.Voter != "" || e.Options != nil {
+			t.Errorf("匿名且隐藏结果的投票公开了 %+v", e)
+		}
+	}
+	// 管理员可以看到所选选项
+	if admin := getActivity(t, "/api/poll/"+pollID+"/activity?preview=1", adminHeader...); admin.Activity[0].Options == nil {
+		t.Error("管理员预览时没有所选选项")
+	}
+
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+hidden+"/activity", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("不公开投票人数时状态码 = %d，期望 403", rec.Code)
+	}
+}
+
+func TestActivityPagingIsStable(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	// 许多投票时间相同，且时间顺序与写入顺序不一致
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const total = 103
+	for i := 0; i < total; i++ {
+		votedAt := base.Add(time.Duration((i*7)%10) * time.Second)
+		if _, err := store.db.Exec(`INSERT INTO vote_events (poll_id, options, voted_at) VALUES (?, 'a', ?)`, pollID, votedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[int64]bool{}
+	var prev *ActivityEntry
+	path := "/api/poll/" + pollID + "/activity?limit=10"
+	for pages := 0; ; pages++ {
+		if pages > total {
+			t.Fatal("翻页没有结束")
+		}
+		page := getActivity(t, path)
+		for i := range page.Activity {
+			e := page.Activity[i]
+			if seen[e.ID] {
+				t.Errorf("动态 %d 重复出现", e.ID)
+			}
+			seen[e.ID] = true
+			if prev != nil && (e.VotedAt.After(prev.VotedAt) || e.VotedAt.Equal(prev.VotedAt) && e.ID > prev.ID) {
+				t.Errorf("动态 %d 排在 %d 之后，顺序错误", e.ID, prev.ID)
+			}
+			prev = &e
+		}
+		if page.NextBefore == 0 {
+			break
+		}
+		// 翻页期间的新投票不影响后面的页
+		if pages == 2 {
+			mustVote(t, pollID, "b")
+		}
+		path = fmt.Sprintf("/api/poll/%s/activity?limit=10&before=%d", pollID, page.NextBefore)
+	}
+	if len(seen) != total {
+		t.Errorf("翻页共得到 %d 条，期望 %d 条", len(seen), total)
+	}
+
+	// 按投票和时间的索引查询
+	rows, err := store.db.Query(`EXPLAIN QUERY PLAN SELECT id, voted_at, voter, options FROM vote_events WHERE poll_id = ? ORDER BY voted_at DESC, id DESC LIMIT 10`, pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan += detail + "\n"
+	}
+	if !strings.Contains(plan, "idx_vote_events_poll") {
+		t.Errorf("查询计划未使用 idx_vote_events_poll:\n%s", plan)
+	}
+}
+
+func TestHourHistogram(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{
+		day.Add(9 * time.Hour),
+		day.Add(9*time.Hour + 59*time.Minute),
+		day.Add(24*time.Hour + 9*time.Hour), // 另一天的同一小时
+		day.Add(23*time.Hour + 30*time.Minute),
+		day.Add(time.Hour),
+	} {
+		if _, err := store.db.Exec(`INSERT INTO vote_events (poll_id, options, voted_at) VALUES (?, 'a', ?)`, pollID, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	histogram := func(query string) [24]int {
+		t.Helper()
+		rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/hour-histogram"+query, nil)
+		var resp struct {
+			Hours [24]int `json:"hours"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s（%d）: %s", query, rec.Code, rec.Body.String())
+		}
+		return resp.Hours
+	}
+
+	var want [24]int
+	want[9], want[23], want[1] = 3, 1, 1
+	if got := histogram(""); got != want {
+		t.Errorf("UTC = %v，期望 %v", got, want)
+	}
+	// 按东八区划分：9 点变为 17 点，23:30 变为次日 7:30，1 点变为 9 点
+	var shifted [24]int
+	shifted[17], shifted[7], shifted[9] = 3, 1, 1
+	for _, tz := range []string{"%2B08:00", "%2B0800", "Asia/Shanghai"} {
+		if got := histogram("?tz=" + tz); got != shifted {
+			t.Errorf("tz=%s = %v，期望 %v", tz, got, shifted)
+		}
+	}
+	// 负偏移和非整点偏移：9:59 加 5:30 进入 15 点
+	var india [24]int
+	india[14], india[15], india[5], india[6] = 2, 1, 1, 1
+	if got := histogram("?tz=%2B05:30"); got != india {
+		t.Errorf("tz=+05:30 = %v，期望 %v", got, india)
+	}
+	var west [24]int
+	west[4], west[18], west[20] = 3, 1, 1
+	if got := histogram("?tz=-05"); got != west {
+		t.Errorf("tz=-05 = %v，期望 %v", got, west)
+	}
+
+	for _, tz := range []string{"%2B8h", "Mars/Olympus"} {
+		if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/hour-histogram?tz="+tz, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("tz=%s 状态码 = %d，期望 400", tz, rec.Code)
+		}
+	}
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+hidden+"/hour-histogram", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("不公开投票人数时状态码 = %d，期望 403", rec.Code)
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+hidden+"/hour-histogram", nil, adminHeader...); rec.Code != http.StatusOK {
+		t.Errorf("管理员查看不公开投票人数的投票状态码 = %d，期望 200", rec.Code)
+	}
+}
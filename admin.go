@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requireAdmin 校验管理令牌（X-Admin-Token 或 Authorization: Bearer），失败时写入响应并返回 false
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if isAdmin(r) {
+		return true
+	}
+	if cfg.AdminToken == "" {
+		http.Error(w, "Admin API disabled", http.StatusForbidden)
+	} else {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+	return false
+}
+
+// isAdmin 请求是否携带了正确的管理令牌
+func isAdmin(r *http.Request) bool {
+	if cfg.AdminToken == "" {
+		return false
+	}
+	token := r.Header.Get("X-Admin-Token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) == 1
+}
+
+// Anomaly 疑似刷票的投票
+type Anomaly struct {
+	PollID     string     `json:"poll_id"`
+	Title      string     `json:"title"`
+	Reason     string     `json:"reason"`
+	BurstVotes int        `json:"burst_votes,omitempty"` // 窗口内的最大票数
+	BurstStart *time.Time `json:"burst_start,omitempty"` // 最密集窗口的开始时间
+}
+
+// FindAnomalies 找出在 window 时间内收到超过 maxVotes 票，或某选项票数超过投票人数的投票
+func (ps *PollStore) FindAnomalies(window time.Duration, maxVotes int) ([]Anomaly, error) {
+	var anomalies []Anomaly
+
+	// 只检查总事件数超过阈值的投票，避免扫描全部时间线
+	rows, err := ps.db.Query(`
+		SELECT e.poll_id, p.title, e.voted_at
+		FROM vote_events e
+		JOIN polls p ON p.id = e.poll_id AND p.deleted_at IS NULL
+		WHERE e.poll_id IN (
+			SELECT poll_id FROM vote_events GROUP BY poll_id HAVING COUNT(*) > ?
+		)
+		ORDER BY e.poll_id, e.voted_at
+	`, maxVotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var currentID, currentTitle string
+	var times []time.Time
+	flush := func() {
+		if count, start := densestWindow(times, window); count > maxVotes {
+			anomalies = append(anomalies, Anomaly{
+				PollID:     currentID,
+				Title:      currentTitle,
+				Reason:     "vote burst",
+				BurstVotes: count,
+				BurstStart: &start,
+			})
+		}
+	}
+	for rows.Next() {
+		var pollID, title string
+		var votedAt time.Time
+		if err := rows.Scan(&pollID, &title, &votedAt); err != nil {
+			return nil, err
+		}
+		if pollID != currentID {
+			flush()
+			currentID, currentTitle, times = pollID, title, times[:0]
+		}
+		times = append(times, votedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	// 单个选项的票数不可能超过投票人数
+	boundRows, err := ps.db.Query(`
+		SELECT DISTINCT p.id, p.title
+		FROM polls p
+		JOIN votes v ON v.poll_id = p.id
+		WHERE v.vote_count > p.voter_count AND p.deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer boundRows.Close()
+
+	for boundRows.Next() {
+		var a Anomaly
+		if err := boundRows.Scan(&a.PollID, &a.Title); err != nil {
+			return nil, err
+		}
+		a.Reason = "option count exceeds voter count"
+		anomalies = append(anomalies, a)
+	}
+
+	return anomalies, boundRows.Err()
+}
+
+// densestWindow 返回有序时间序列中任意 window 长度内的最大事件数及其开始时间
+func densestWindow(times []time.Time, window time.Duration) (int, time.Time) {
+	best, start := 0, time.Time{}
+	left := 0
+	for right := range times {
+		for times[right].Sub(times[left]) >= window {
+			left++
+		}
+		if n := right - left + 1; n > best {
+			best, start = n, times[left]
+		}
+	}
+	return best, start
+}
+
+func apiAdminAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	anomalies, err := store.FindAnomalies(cfg.AnomalyWindow, cfg.AnomalyMaxVotes)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"anomalies": anomalies,
+	})
+}
+
+// PollSummary 投票的简要信息
+type PollSummary struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	VoterCount int    `json:"voter_count"`
+}
+
+// Stats 全部投票的汇总统计
+type Stats struct {
+	PollCount       int          `json:"poll_count"`
+	TotalVoters     int          `json:"total_voters"`     // 所有投票的投票人数之和
+	TotalSelections int          `json:"total_selections"` // 所有选项的票数之和
+	AvgOptions      float64      `json:"avg_options"`      // 平均每个投票的选项数
+	MostVoted       *PollSummary `json:"most_voted,omitempty"`
+}
+
+// Stats 用 SQL 聚合计算全部投票的统计，不逐个加载投票
+func (ps *PollStore) Stats() (*Stats, error) {
+	var s Stats
+	err := ps.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(voter_count), 0) FROM polls WHERE deleted_at IS NULL
+	`).Scan(&s.PollCount, &s.TotalVoters)
+	if err != nil {
+		return nil, err
+	}
+	if s.PollCount == 0 {
+		return &s, nil
+	}
+
+	var optionCount int
+	err = ps.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(v.vote_count), 0)
+		FROM votes v
+		JOIN polls p ON p.id = v.poll_id AND p.deleted_at IS NULL
+	`).Scan(&optionCount, &s.TotalSelections)
+	if err != nil {
+		return nil, err
+	}
+	s.AvgOptions = float64(optionCount) / float64(s.PollCount)
+
+	var top PollSummary
+	err = ps.db.QueryRow(`
+		SELECT id, title, voter_count FROM polls
+		WHERE deleted_at IS NULL
+		ORDER BY voter_count DESC, created_at ASC
+		LIMIT 1
+	`).Scan(&top.ID, &top.Title, &top.VoterCount)
+	if err != nil {
+		return nil, err
+	}
+	s.MostVoted = &top
+
+	return &s, nil
+}
+
+func apiAdminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"stats":    stats,
+		"qr_cache": qrCache.Stats(),
+	})
+}
+
+func apiAllowedVotersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		Voters []string `json:"voters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	if err := store.AddAllowedVoters(r.PathValue("id"), req.Voters); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Recount 用初始票数加投票事件重新计算各选项票数和投票人数，覆盖可能与事件记录不一致的汇总值
+func (ps *PollStore) Recount(id string) (*Poll, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var voterCount int
+	err = tx.QueryRow(`SELECT initial_voter_count FROM polls WHERE id = ? AND deleted_at IS NULL`, id).Scan(&voterCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("poll not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	rows, err := tx.Query(`SELECT option_name, initial_count FROM votes WHERE poll_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		counts[name] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	events, err := tx.Query(`SELECT options FROM vote_events WHERE poll_id = ? AND `+currentRoundEvents, id)
+	if err != nil {
+		return nil, err
+	}
+	for events.Next() {
+		var options string
+		if err := events.Scan(&options); err != nil {
+			events.Close()
+			return nil, err
+		}
+		voterCount++
+		for _, opt := range strings.Split(options, "|||") {
+			if _, ok := counts[opt]; ok {
+				counts[opt]++
+			}
+		}
+	}
+	events.Close()
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, count := range counts {
+		if _, err := tx.Exec(`
+			UPDATE votes SET vote_count = ? WHERE poll_id = ? AND option_name = ?
+		`, count, id, name); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE polls SET voter_count = ? WHERE id = ?`, voterCount, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(id)
+}
+
+func apiRecountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	poll, err := store.Recount(r.PathValue("id"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"voter_count": poll.VoterCount,
+		"votes":       poll.Votes,
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnomaliesFlagVoteBurst(t *testing.T) {
+	setupTest(t)
+	cfg.AnomalyMaxVotes = 3
+
+	burst, _ := createTestPoll(t, map[string]interface{}{"title": "burst", "options": []string{"a", "b"}})
+	quiet, _ := createTestPoll(t, map[string]interface{}{"title": "quiet", "options": []string{"a", "b"}})
+	for range 5 {
+		mustVote(t, burst, "a")
+	}
+	mustVote(t, quiet, "b")
+
+	if rec := doRequest(t, http.MethodGet, "/api/admin/anomalies", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/admin/anomalies", nil, adminHeader...)
+	var resp struct {
+		Anomalies []Anomaly `json:"anomalies"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Anomalies) != 1 {
+		t.Fatalf("anomalies = %+v，期望只有 burst", resp.Anomalies)
+	}
+	a := resp.Anomalies[0]
+	if a.PollID != burst || a.Reason != "vote burst" || a.BurstVotes != 5 {
+		t.Errorf("anomaly = %+v", a)
+	}
+}
+
+func TestDensestWindow(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		base,
+		base.Add(10 * time.Second),
+		base.Add(2 * time.Minute),
+		base.Add(2*time.Minute + time.Second),
+		base.Add(2*time.Minute + 2*time.Second),
+	}
+	count, start := densestWindow(times, time.Minute)
+	if count != 3 || !start.Equal(times[2]) {
+		t.Errorf("densestWindow = %d, %v，期望 3, %v", count, start, times[2])
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodGet, "/api/admin/stats", nil, adminHeader...)
+	var empty struct {
+		Stats Stats `json:"stats"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &empty); err != nil {
+		t.Fatal(err)
+	}
+	if empty.Stats.PollCount != 0 || empty.Stats.MostVoted != nil {
+		t.Errorf("空库统计 = %+v", empty.Stats)
+	}
+
+	small, _ := createTestPoll(t, map[string]interface{}{"title": "small", "options": []string{"a", "b"}})
+	big, _ := createTestPoll(t, map[string]interface{}{"title": "big", "options": []string{"a", "b", "c", "d"}, "multi_select": true})
+	deleted, _ := createTestPoll(t, map[string]interface{}{"title": "deleted", "options": []string{"a", "b", "c"}})
+	mustVote(t, small, "a")
+	mustVote(t, big, "a", "b")
+	mustVote(t, big, "c")
+	mustVote(t, big, "a", "c", "d")
+	mustVote(t, deleted, "a")
+	if err := store.Delete(deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/admin/stats", nil, adminHeader...)
+	var resp struct {
+		Stats Stats `json:"stats"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	s := resp.Stats
+	if s.PollCount != 2 || s.TotalVoters != 4 || s.TotalSelections != 7 || s.AvgOptions != 3 {
+		t.Errorf("stats = %+v，期望 2 个投票、4 人、7 票、平均 3 个选项", s)
+	}
+	if s.MostVoted == nil || s.MostVoted.ID != big || s.MostVoted.VoterCount != 3 {
+		t.Errorf("most_voted = %+v，期望 big", s.MostVoted)
+	}
+}
+
+func TestRecountRepairsCorruptedAggregates(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}, "multi_select": true})
+	mustVote(t, pollID, "a", "b")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "c")
+	want := mustGet(t, pollID)
+
+	// 人为篡改汇总值
+	for _, q := range []string{
+		`UPDATE votes SET vote_count = 42 WHERE poll_id = ? AND option_name = 'a'`,
+		`UPDATE votes SET vote_count = 0 WHERE poll_id = ? AND option_name = 'c'`,
+		`UPDATE polls SET voter_count = 99 WHERE id = ?`,
+	} {
+		if _, err := store.db.Exec(q, pollID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if poll := mustGet(t, pollID); poll.VoterCount != 99 || poll.Votes["a"] != 42 {
+		t.Fatalf("篡改没有生效: %+v", poll)
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/recount/"+pollID, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+	rec := doRequest(t, http.MethodPost, "/api/recount/"+pollID, nil, adminHeader...)
+	var resp struct {
+		Success    bool           `json:"success"`
+		VoterCount int            `json:"voter_count"`
+		Votes      map[string]int `json:"votes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.VoterCount != 3 || !reflect.DeepEqual(resp.Votes, map[string]int{"a": 2, "b": 1, "c": 1}) {
+		t.Errorf("重新计票响应 = %+v", resp)
+	}
+	if got := mustGet(t, pollID); got.VoterCount != want.VoterCount || !reflect.DeepEqual(got.Votes, want.Votes) {
+		t.Errorf("重新计票后 = %d %v，期望 %d %v", got.VoterCount, got.Votes, want.VoterCount, want.Votes)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithWriteLock 暂停投票和创建投票，执行 fn 后恢复，用于需要一致快照的操作（如复制数据库文件）
+func (ps *PollStore) WithWriteLock(fn func(db *sql.DB) error) error {
+	ps.writeMu.Lock()
+	defer ps.writeMu.Unlock()
+	return fn(ps.db.DB)
+}
+
+// Backup 把数据库快照写入 path（文件不能已存在）
+func (ps *PollStore) Backup(path string) error {
+	return ps.WithWriteLock(func(db *sql.DB) error {
+		_, err := db.Exec(`VACUUM INTO ?`, path)
+		return err
+	})
+}
+
+// apiBackupHandler 在 -backup-dir 中生成带时间戳的数据库备份
+func apiBackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if cfg.BackupDir == "" {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "backups are not configured",
+		})
+		return
+	}
+
+	if err := os.MkdirAll(cfg.BackupDir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	path := filepath.Join(cfg.BackupDir, "toupiao-"+time.Now().UTC().Format("20060102-150405.000")+".db")
+	if err := store.Backup(path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"path":    path,
+		"size":    size,
+	})
+}
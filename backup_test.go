@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackupDuringVotes(t *testing.T) {
+	setupTest(t)
+	cfg.BackupDir = filepath.Join(t.TempDir(), "backups")
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "multi_select": true})
+
+	if rec := doRequest(t, http.MethodPost, "/api/backup", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+
+	// 备份时持续有人投票
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			options := []string{"a"}
+			if i%2 == 0 {
+				options = []string{"a", "b"}
+			}
+			castVote(t, pollID, options...)
+		}()
+	}
+	rec := doRequest(t, http.MethodPost, "/api/backup", nil, adminHeader...)
+	wg.Wait()
+	body := decodeBody(t, rec)
+	if body["success"] != true {
+		t.Fatalf("备份失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	path := body["path"].(string)
+	if filepath.Dir(path) != cfg.BackupDir {
+		t.Errorf("备份路径 = %s，期望在 %s 中", path, cfg.BackupDir)
+	}
+
+	// 快照能重新打开，汇总值与投票记录一致
+	backup, err := NewPollStore(path)
+	if err != nil {
+		t.Fatalf("无法打开备份: %v", err)
+	}
+	defer backup.Close()
+	poll, err := backup.Get(pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events, selections int
+	if err := backup.db.QueryRow(`SELECT COUNT(*) FROM vote_events WHERE poll_id = ?`, pollID).Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range poll.Votes {
+		selections += n
+	}
+	if poll.VoterCount != events || poll.Votes["a"] != events {
+		t.Errorf("备份不一致: voter_count = %d，votes = %v，投票记录 %d 条", poll.VoterCount, poll.Votes, events)
+	}
+	if selections < events {
+		t.Errorf("备份中选择总数 %d 少于投票人数 %d", selections, events)
+	}
+	if live := mustGet(t, pollID).VoterCount; live != 20 {
+		t.Errorf("备份后 voter_count = %d，期望 20（备份期间的投票不应丢失）", live)
+	}
+}
+
+func TestWithWriteLockPausesVotes(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- store.WithWriteLock(func(db *sql.DB) error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	voted := make(chan struct{})
+	go func() {
+		castVote(t, pollID, "a")
+		close(voted)
+	}()
+	select {
+	case <-voted:
+		t.Fatal("持有写锁期间投票没有暂停")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-voted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("释放写锁后投票没有继续")
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
+
+func TestBackupNotConfigured(t *testing.T) {
+	setupTest(t)
+	if rec := doRequest(t, http.MethodPost, "/api/backup", nil, adminHeader...); rec.Code != http.StatusForbidden {
+		t.Errorf("未配置备份目录时状态码 = %d，期望 403", rec.Code)
+	}
+	if _, err := os.Stat("backups"); err == nil {
+		t.Error("未配置时不应创建目录")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxBatchResults 一次批量查询结果的投票数上限
+const maxBatchResults = 50
+
+// BatchResultsRequest 批量查询结果的请求
+type BatchResultsRequest struct {
+	PollIDs []string `json:"poll_ids"`
+}
+
+// PollResult 批量结果中单个投票的票数和百分比，公开规则与结果页相同
+type PollResult struct {
+	PollID      string             `json:"poll_id"`
+	Title       string             `json:"title,omitempty"`
+	Votes       map[string]int     `json:"votes,omitempty"`
+	Percentages map[string]float64 `json:"percentages,omitempty"`
+	VoterCount  *int               `json:"voter_count,omitempty"` // 投票设置了不公开投票人数时省略
+	Margin      *ResultMargin      `json:"margin,omitempty"`
+	Closed      bool               `json:"closed"`
+	Withheld    bool               `json:"withheld,omitempty"` // 结果尚未公开，不含票数
+	Error       string             `json:"error,omitempty"`
+}
+
+// GetMany 用一次查询读取多个投票及其票数，不存在或已删除的投票不在结果中
+func (ps *PollStore) GetMany(ids []string) ([]*Poll, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := ps.db.Query(`SELECT `+pollColumns+` FROM polls WHERE deleted_at IS NULL AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var polls []*Poll
+	for rows.Next() {
+		poll, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := ps.loadVotesBatch(polls); err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
+
+// pollResult 按请求者身份生成单个投票的结果块
+func pollResult(poll *Poll, r *http.Request) PollResult {
+	view := newResultsView(poll, r)
+	result := PollResult{
+		PollID:   poll.ID,
+		Title:    poll.Title,
+		Closed:   poll.IsClosed(),
+		Withheld: view.Withheld,
+	}
+	if view.Withheld {
+		return result
+	}
+	result.Percentages = votePercentages(poll.Votes, poll.PercentBase())
+	// 隐去投票人数时票数和领先差距也能推算出人数，只给百分比
+	if !poll.VoterCountWithheld() {
+		result.Votes = poll.Votes
+		result.Margin = poll.Margin()
+		result.VoterCount = &poll.VoterCount
+	}
+	return result
+}
+
+// apiBatchResultsHandler 看板用的批量结果：POST /api/results/batch，请求体 {"poll_ids": [...]}。
+// 结果按请求顺序返回，找不到的投票带有 error
+func apiBatchResultsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req BatchResultsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.PollIDs) == 0 || len(req.PollIDs) > maxBatchResults {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("poll_ids must contain between 1 and %d poll IDs", maxBatchResults),
+		})
+		return
+	}
+
+	polls, err := store.GetMany(req.PollIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[string]*Poll, len(polls))
+	for _, poll := range polls {
+		byID[poll.ID] = poll
+	}
+
+	results := make([]PollResult, len(req.PollIDs))
+	for i, id := range req.PollIDs {
+		if poll := byID[id]; poll != nil {
+			results[i] = pollResult(poll, r)
+		} else {
+			results[i] = PollResult{PollID: id, Error: "poll not found"}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"results": results,
+	})
+}
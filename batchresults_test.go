@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBatchResults(t *testing.T) {
+	setupTest(t)
+	first, _ := createTestPoll(t, map[string]interface{}{"title": "午饭", "options": []string{"面", "饭", "粥"}})
+	mustVote(t, first, "面")
+	mustVote(t, first, "面")
+	mustVote(t, first, "饭")
+
+	second, secondToken := createTestPoll(t, map[string]interface{}{"title": "晚饭", "options": []string{"x", "y"}, "multi_select": true, "max_choices": 2})
+	mustVote(t, second, "x", "y")
+	mustVote(t, second, "x")
+	doRequest(t, http.MethodPost, "/api/close-poll/"+second, nil, manageTokenHeader, secondToken)
+
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "隐藏", "options": []string{"a", "b"}, "hide_results": true})
+	mustVote(t, hidden, "a")
+
+	rec := doRequest(t, http.MethodPost, "/api/results/batch", map[string]interface{}{
+		"poll_ids": []string{second, "no-such-poll", first, hidden},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("批量结果状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	results := decodeBody(t, rec)["results"].([]interface{})
+	if len(results) != 4 {
+		t.Fatalf("返回 %d 个结果，期望 4", len(results))
+	}
+	block := func(i int) map[string]interface{} { return results[i].(map[string]interface{}) }
+
+	// 结果按请求顺序返回
+	for i, id := range []string{second, "no-such-poll", first, hidden} {
+		if block(i)["poll_id"] != id {
+			t.Errorf("第 %d 个结果 poll_id = %v，期望 %s", i, block(i)["poll_id"], id)
+		}
+	}
+
+	if got := block(0); got["closed"] != true || got["voter_count"] != float64(2) ||
+		!reflect.DeepEqual(got["votes"], map[string]interface{}{"x": float64(2), "y": float64(1)}) ||
+		!reflect.DeepEqual(got["percentages"], map[string]interface{}{"x": float64(100), "y": float64(50)}) {
+		t.Errorf("晚饭结果 = %v", got)
+	}
+	if got := block(1); got["error"] != "poll not found" || got["votes"] != nil {
+		t.Errorf("不存在的投票结果 = %v", got)
+	}
+	if got := block(2); got["title"] != "午饭" || got["closed"] != false || got["voter_count"] != float64(3) ||
+		!reflect.DeepEqual(got["votes"], map[string]interface{}{"面": float64(2), "饭": float64(1), "粥": float64(0)}) ||
+		!reflect.DeepEqual(got["percentages"], map[string]interface{}{"面": 66.7, "饭": 33.3, "粥": float64(0)}) {
+		t.Errorf("午饭结果 = %v", got)
+	}
+	if got := block(3); got["withheld"] != true || got["votes"] != nil || got["percentages"] != nil {
+		t.Errorf("未公开的结果 = %v", got)
+	}
+
+	// 管理员加 ?preview=1 能看到未公开的结果
+	admin := decodeBody(t, doRequest(t, http.MethodPost, "/api/results/batch?preview=1", map[string]interface{}{"poll_ids": []string{hidden}}, adminHeader...))
+	if got := admin["results"].([]interface{})[0].(map[string]interface{}); got["withheld"] != nil || got["voter_count"] != float64(1) {
+		t.Errorf("管理员看到的未公开结果 = %v", got)
+	}
+}
+
+func TestBatchResultsLimits(t *testing.T) {
+	setupTest(t)
+	tooMany := make([]string, maxBatchResults+1)
+	for i := range tooMany {
+		tooMany[i] = "p"
+	}
+	for name, ids := range map[string][]string{"空列表": {}, "超过上限": tooMany} {
+		if rec := doRequest(t, http.MethodPost, "/api/results/batch", map[string]interface{}{"poll_ids": ids}); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400", name, rec.Code)
+		}
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/results/batch", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET 状态码 = %d，期望 405", rec.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// errWriteInBlocked 自填答案包含屏蔽词，接口返回 400
+var errWriteInBlocked = errors.New("write-in contains a blocked word")
+
+// wordBlocklist 屏蔽词过滤，不区分大小写。西文词按整词匹配（"ass" 不会命中 "class"）；
+// 含汉字等不以空格分词的词按子串匹配
+type wordBlocklist struct {
+	pattern *regexp.Regexp
+}
+
+// blocklist 在 main 中按 -blocklist-file 初始化，为 nil 时不过滤
+var blocklist *wordBlocklist
+
+// newWordBlocklist 编译屏蔽词，忽略空白项；没有屏蔽词时返回 nil
+func newWordBlocklist(words []string) *wordBlocklist {
+	var alts []string
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		quoted := regexp.QuoteMeta(w)
+		if needsWordBoundary(w) {
+			// Go 的 \b 只认 ASCII 单词字符，这里用 Unicode 字母和数字判断词边界
+			quoted = `(?:^|[^\p{L}\p{N}_])` + quoted + `(?:$|[^\p{L}\p{N}_])`
+		}
+		alts = append(alts, quoted)
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	return &wordBlocklist{pattern: regexp.MustCompile(`(?i)` + strings.Join(alts, "|"))}
+}
+
+// needsWordBoundary 屏蔽词中有汉字、假名或谚文时按子串匹配，否则按整词匹配
+func needsWordBoundary(word string) bool {
+	for _, r := range word {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadBlocklistFile 读取屏蔽词文件，每行一个词，# 开头的行为注释
+func loadBlocklistFile(path string) (*wordBlocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newWordBlocklist(words), nil
+}
+
+// Contains 文本是否包含屏蔽词，b 为 nil 时总是返回 false
+func (b *wordBlocklist) Contains(text string) bool {
+	return b != nil && b.pattern.MatchString(text)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWordBlocklistContains(t *testing.T) {
+	b := newWordBlocklist([]string{"ass", "  ", "badword", "傻瓜"})
+	for text, want := range map[string]bool{
+		"you ass":        true,
+		"ASS!":           true,
+		"Ass-kicking":    true,
+		"class":          false,
+		"assets":         false,
+		"passé":          false,
+		"a BadWord here": true,
+		"badwords":       false,
+		"你这个傻瓜":          true,
+		"傻瓜相机":           true,
+		"nothing to see": false,
+	} {
+		if got := b.Contains(text); got != want {
+			t.Errorf("Contains(%q) = %v，期望 %v", text, got, want)
+		}
+	}
+	if newWordBlocklist([]string{"", " "}) != nil {
+		t.Error("没有屏蔽词时应返回 nil")
+	}
+	var none *wordBlocklist
+	if none.Contains("ass") {
+		t.Error("未配置屏蔽词时不应过滤")
+	}
+}
+
+func TestLoadBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# 注释\nbadword\n\n  spam  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := loadBlocklistFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Contains("SPAM") || !b.Contains("badword") || b.Contains("注释") {
+		t.Errorf("屏蔽词文件解析错误: %v", b.pattern)
+	}
+}
+
+func TestBlocklistRejectsPollsAndWriteIns(t *testing.T) {
+	setupTest(t)
+	prev := blocklist
+	blocklist = newWordBlocklist([]string{"badword"})
+	t.Cleanup(func() { blocklist = prev })
+
+	for field, req := range map[string]map[string]interface{}{
+		"title":      {"title": "a BADWORD poll", "options": []string{"a", "b"}},
+		"options[1]": {"title": "t", "options": []string{"a", "badword"}},
+	} {
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "blocked word") || !strings.Contains(rec.Body.String(), `"`+field+`"`) {
+			t.Errorf("%s 含屏蔽词（%d）: %s", field, rec.Code, rec.Body.String())
+		}
+	}
+	// 只是包含屏蔽词的片段时允许
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "badwords are fine", "options": []string{"a", "b"}, "allow_write_ins": true})
+
+	rec := voteWriteIn(t, pollID, "BadWord")
+	if rec.Code != http.StatusBadRequest || decodeBody(t, rec)["error"] != errWriteInBlocked.Error() {
+		t.Errorf("自填屏蔽词（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 0 {
+		t.Errorf("被拒绝的自填答案计入了人数: %d", got)
+	}
+	if rec := voteWriteIn(t, pollID, "goodword"); rec.Code != http.StatusOK {
+		t.Errorf("正常的自填答案（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
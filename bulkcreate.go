@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxBulkCreate 一次批量创建的投票数上限
+const maxBulkCreate = 50
+
+// BulkCreateResult 批量创建中单个投票的结果，Index 为请求数组中的位置
+type BulkCreateResult struct {
+	Index       int              `json:"index"`
+	Success     bool             `json:"success"`
+	PollID      string           `json:"poll_id,omitempty"`
+	JoinCode    string           `json:"join_code,omitempty"`
+	ManageToken string           `json:"manage_token,omitempty"` // 投票管理令牌，只在这里返回一次
+	Error       string           `json:"error,omitempty"`
+	Errors      ValidationErrors `json:"errors,omitempty"` // 字段校验错误
+
+	poll *Poll
+}
+
+// CreateMany 在同一个事务中创建多个已校验的投票。默认任何一个失败就全部回滚并返回该错误；
+// bestEffort 时每个投票用一个保存点，失败的投票单独回滚并记录在结果中，其余照常提交
+func (ps *PollStore) CreateMany(reqs []CreatePollRequest, bestEffort bool) ([]BulkCreateResult, error) {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkCreateResult, len(reqs))
+	for i := range reqs {
+		req := &reqs[i]
+		results[i].Index = i
+		if bestEffort {
+			if _, err := tx.Exec(`SAVEPOINT bulk_create`); err != nil {
+				return nil, err
+			}
+		}
+
+		minChoices, maxChoices := req.choiceLimits()
+		poll, err := createPollTx(tx, req.Title, req.Options, req.MultiSelect, minChoices, maxChoices, req.settings())
+		if err != nil && !bestEffort {
+			return nil, fmt.Errorf("poll %d: %w", i, err)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			if _, err := tx.Exec(`ROLLBACK TO bulk_create`); err != nil {
+				return nil, err
+			}
+		} else {
+			results[i].Success = true
+			results[i].PollID = poll.ID
+			results[i].JoinCode = poll.JoinCode
+			results[i].ManageToken = poll.ManageToken
+			results[i].poll = poll
+		}
+		if bestEffort {
+			if _, err := tx.Exec(`RELEASE bulk_create`); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// apiBulkCreatePollsHandler 批量创建：POST /api/create-polls，请求体为创建请求的数组。
+// 默认全部成功或全部不创建；?best_effort=1 时跳过失败的投票，逐个返回结果
+func apiBulkCreatePollsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var reqs []CreatePollRequest
+	if !decodeJSON(w, r, &reqs) {
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxBulkCreate {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("between 1 and %d polls are required", maxBulkCreate),
+		})
+		return
+	}
+	bestEffort := r.URL.Query().Get("best_effort") == "1"
+
+	creatorIP := hashVoterKey(clientIP(r))
+	var allErrs ValidationErrors
+	invalid := make(map[int]ValidationErrors)
+	var valid []CreatePollRequest
+	var validIndex []int
+	for i := range reqs {
+		req := &reqs[i]
+		req.creatorIP = creatorIP
+		if !isAdmin(r) {
+			req.maxPolls = cfg.MaxPollsPerCreator
+		}
+		req.applyOptionsText()
+		if errs := req.Validate(); len(errs) > 0 {
+			invalid[i] = errs
+			for _, e := range errs {
+				allErrs.Add(fmt.Sprintf("[%d].%s", i, e.Field), "%s", e.Message)
+			}
+			continue
+		}
+		valid = append(valid, *req)
+		validIndex = append(validIndex, i)
+	}
+	if len(allErrs) > 0 && !bestEffort {
+		writeCreateError(w, allErrs)
+		return
+	}
+
+	created, err := store.CreateMany(valid, bestEffort)
+	if err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	results := make([]BulkCreateResult, len(reqs))
+	for i, errs := range invalid {
+		results[i] = BulkCreateResult{Index: i, Error: errs.Error(), Errors: errs}
+	}
+	allCreated := len(invalid) == 0
+	for j, res := range created {
+		res.Index = validIndex[j]
+		results[res.Index] = res
+		if !res.Success {
+			allCreated = false
+			continue
+		}
+		notifyWebhook(res.poll, EventPollCreated, map[string]interface{}{
+			"title":        res.poll.Title,
+			"options":      res.poll.Options,
+			"multi_select": res.poll.MultiSelect,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": allCreated,
+		"results": results,
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// pollCount 数据库中的投票数
+func pollCount(t *testing.T) int {
+	t.Helper()
+	var n int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM polls`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBulkCreatePolls(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodPost, "/api/create-polls", []map[string]interface{}{
+		{"title": "第一场", "options": []string{"甲", "乙"}},
+		{"title": "第二场", "options": []string{"丙", "丁"}, "slug": "match-2"},
+		{"title": "决赛", "options_text": "胜者一\n胜者二"},
+	})
+	body := decodeBody(t, rec)
+	if rec.Code != http.StatusOK || body["success"] != true {
+		t.Fatalf("批量创建失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	results := body["results"].([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("返回 %d 个结果，期望 3", len(results))
+	}
+	for i, title := range []string{"第一场", "第二场", "决赛"} {
+		res := results[i].(map[string]interface{})
+		token, _ := res["manage_token"].(string)
+		if res["index"] != float64(i) || res["success"] != true || token == "" {
+			t.Errorf("第 %d 个结果 = %v", i, res)
+			continue
+		}
+		pollID := res["poll_id"].(string)
+		if poll := mustGet(t, pollID); poll.Title != title || len(poll.Options) != 2 {
+			t.Errorf("第 %d 个投票 = %+v", i, poll)
+		}
+		// 每个投票的管理令牌只能管理该投票
+		if i > 0 {
+			other := results[i-1].(map[string]interface{})["poll_id"].(string)
+			if rec := doRequest(t, http.MethodPost, "/api/close-poll/"+other, nil, manageTokenHeader, token); rec.Code != http.StatusForbidden {
+				t.Errorf("第 %d 个投票的令牌结束了其他投票: %d", i, rec.Code)
+			}
+		}
+		if rec := doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, manageTokenHeader, token); rec.Code != http.StatusOK {
+			t.Errorf("第 %d 个投票用自己的令牌结束失败: %d", i, rec.Code)
+		}
+	}
+	if poll, err := store.Resolve("match-2"); err != nil || poll.Title != "第二场" {
+		t.Errorf("短链接 match-2 = %v, %v", poll, err)
+	}
+}
+
+func TestBulkCreateAllOrNothing(t *testing.T) {
+	setupTest(t)
+	createTestPoll(t, map[string]interface{}{"title": "已有", "options": []string{"a", "b"}, "slug": "taken"})
+
+	// 校验失败：返回带序号的字段错误，一个都不创建
+	rec := doRequest(t, http.MethodPost, "/api/create-polls", []map[string]interface{}{
+		{"title": "一", "options": []string{"a", "b"}},
+		{"title": "二", "options": []string{"a", "b"}},
+		{"title": "", "options": []string{"a"}},
+		{"title": "四", "options": []string{"a", "b"}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("含无效项的批量创建状态码 = %d，期望 400", rec.Code)
+	}
+	fields := map[string]bool{}
+	for _, e := range decodeBody(t, rec)["errors"].([]interface{}) {
+		fields[e.(map[string]interface{})["field"].(string)] = true
+	}
+	if !fields["[2].title"] || !fields["[2].options"] || len(fields) != 2 {
+		t.Errorf("字段错误 = %v，期望 [2].title 和 [2].options", fields)
+	}
+	if n := pollCount(t); n != 1 {
+		t.Errorf("校验失败后有 %d 个投票，期望只有原来的 1 个", n)
+	}
+
+	// 写入时失败（短链接已被占用）：整批回滚
+	rec = doRequest(t, http.MethodPost, "/api/create-polls", []map[string]interface{}{
+		{"title": "一", "options": []string{"a", "b"}},
+		{"title": "二", "options": []string{"a", "b"}, "slug": "taken"},
+	})
+	if decodeBody(t, rec)["success"] != false {
+		t.Fatalf("短链接冲突时批量创建成功: %s", rec.Body.String())
+	}
+	if n := pollCount(t); n != 1 {
+		t.Errorf("写入失败后有 %d 个投票，期望整批回滚", n)
+	}
+}
+
+func TestBulkCreateBestEffort(t *testing.T) {
+	setupTest(t)
+	createTestPoll(t, map[string]interface{}{"title": "已有", "options": []string{"a", "b"}, "slug": "taken"})
+
+	rec := doRequest(t, http.MethodPost, "/api/create-polls?best_effort=1", []map[string]interface{}{
+		{"title": "一", "options": []string{"a", "b"}},
+		{"title": "", "options": []string{"a", "b"}},
+		{"title": "三", "options": []string{"a", "b"}, "slug": "taken"},
+		{"title": "四", "options": []string{"a", "b"}},
+	})
+	body := decodeBody(t, rec)
+	if rec.Code != http.StatusOK || body["success"] != false {
+		t.Fatalf("部分失败时状态码 = %d，success = %v", rec.Code, body["success"])
+	}
+	results := body["results"].([]interface{})
+	for i, wantOK := range []bool{true, false, false, true} {
+		res := results[i].(map[string]interface{})
+		if res["index"] != float64(i) || res["success"] != wantOK {
+			t.Errorf("第 %d 个结果 = %v，期望 success=%v", i, res, wantOK)
+		}
+		if !wantOK && res["error"] == nil {
+			t.Errorf("第 %d 个结果缺少错误说明", i)
+		}
+	}
+	if results[1].(map[string]interface{})["errors"] == nil {
+		t.Error("校验失败的项缺少字段错误")
+	}
+	if n := pollCount(t); n != 3 {
+		t.Errorf("有 %d 个投票，期望原有 1 个加新建 2 个", n)
+	}
+}
+
+func TestBulkCreateLimits(t *testing.T) {
+	setupTest(t)
+	tooMany := make([]map[string]interface{}, maxBulkCreate+1)
+	for i := range tooMany {
+		tooMany[i] = map[string]interface{}{"title": "t", "options": []string{"a", "b"}}
+	}
+	for name, reqs := range map[string][]map[string]interface{}{"空数组": {}, "超过上限": tooMany} {
+		if rec := doRequest(t, http.MethodPost, "/api/create-polls", reqs); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400", name, rec.Code)
+		}
+	}
+	if n := pollCount(t); n != 0 {
+		t.Errorf("有 %d 个投票，期望 0", n)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+// 建议的结果展示方式，只供前端参考
+const (
+	ChartList = "list" // 选项太少，直接列出票数
+	ChartPie  = "pie"
+	ChartBar  = "bar"
+)
+
+// maxPieOptions 超过该数量的选项用饼图难以分辨，改用柱状图
+const maxPieOptions = 6
+
+// chartHint 根据选项数量和单选/多选建议结果展示方式：选项少于 -chart-min-options 时只列出票数；
+// 多选投票各选项占比之和超过 100%，不适合饼图，总是用柱状图
+func chartHint(optionCount int, multiSelect bool) string {
+	switch {
+	case optionCount < cfg.ChartMinOptions:
+		return ChartList
+	case multiSelect || optionCount > maxPieOptions:
+		return ChartBar
+	default:
+		return ChartPie
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestChartHint(t *testing.T) {
+	setupTest(t)
+	tests := []struct {
+		options int
+		multi   bool
+		want    string
+	}{
+		{2, false, ChartList},
+		{2, true, ChartList},
+		{3, false, ChartPie},
+		{maxPieOptions, false, ChartPie},
+		{maxPieOptions + 1, false, ChartBar},
+		{3, true, ChartBar},
+		{20, true, ChartBar},
+	}
+	for _, tc := range tests {
+		if got := chartHint(tc.options, tc.multi); got != tc.want {
+			t.Errorf("chartHint(%d, %v) = %q，期望 %q", tc.options, tc.multi, got, tc.want)
+		}
+	}
+
+	// 阈值可配置
+	cfg.ChartMinOptions = 5
+	if got := chartHint(4, false); got != ChartList {
+		t.Errorf("-chart-min-options 5 时 4 个选项 = %q，期望 %q", got, ChartList)
+	}
+	cfg.ChartMinOptions = 3
+
+	// 提示随结果一起返回
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}})
+	if got := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))["visualization"]; got != ChartPie {
+		t.Errorf("counts visualization = %v，期望 %q", got, ChartPie)
+	}
+	list := decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil))
+	if got := list["polls"].([]interface{})[0].(map[string]interface{})["visualization"]; got != ChartPie {
+		t.Errorf("polls visualization = %v，期望 %q", got, ChartPie)
+	}
+}
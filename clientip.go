@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRs 解析逗号分隔的 CIDR 列表，单个 IP 视为 /32 或 /128
+func parseCIDRs(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", part)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy IP 是否属于配置的可信代理网段
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range cfg.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 返回请求的真实客户端 IP。只有直连地址是可信代理时才读取 X-Forwarded-For，
+// 并从右往左跳过可信代理，取第一个不可信的地址，防止客户端伪造该请求头
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrustedProxy(remote) {
+		return host
+	}
+
+	client := host
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	setupTest(t)
+	proxies, err := parseCIDRs("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TrustedProxies = proxies
+
+	for _, tc := range []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"没有代理", "198.51.100.7:5000", nil, "198.51.100.7"},
+		{"不可信的直连地址伪造请求头", "198.51.100.7:5000", []string{"203.0.113.1"}, "198.51.100.7"},
+		{"可信代理", "10.1.2.3:443", []string{"203.0.113.1"}, "203.0.113.1"},
+		{"单个 IP 的可信代理", "192.0.2.10:443", []string{"203.0.113.1"}, "203.0.113.1"},
+		{"多级可信代理", "10.1.2.3:443", []string{"203.0.113.1, 10.9.9.9"}, "203.0.113.1"},
+		{"客户端在前面伪造的地址被忽略", "10.1.2.3:443", []string{"1.1.1.1, 203.0.113.1"}, "203.0.113.1"},
+		{"多个请求头合并", "10.1.2.3:443", []string{"1.1.1.1", "203.0.113.1"}, "203.0.113.1"},
+		{"请求头中有非法地址", "10.1.2.3:443", []string{"garbage"}, "10.1.2.3"},
+		{"可信代理但没有请求头", "10.1.2.3:443", nil, "10.1.2.3"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		for _, v := range tc.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(req); got != tc.want {
+			t.Errorf("%s: clientIP = %s，期望 %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseCIDRsRejectsInvalid(t *testing.T) {
+	if _, err := parseCIDRs("10.0.0.0/8,not-an-ip"); err == nil {
+		t.Error("非法的代理地址没有报错")
+	}
+	nets, err := parseCIDRs("::1, ,127.0.0.1")
+	if err != nil || len(nets) != 2 {
+		t.Errorf("parseCIDRs = %v, %v", nets, err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 服务配置
+type Config struct {
+	BaseURL       string // 对外访问地址，用于二维码和分享链接
+	WebhookSecret string // webhook 请求签名密钥，为空时不签名
+
+	WebhookAllowPrivate bool   // 允许 webhook 投递到本机和内网地址
+	AdminToken          string // 管理接口令牌，为空时管理接口不可用
+	SecretKey           string // 签发令牌用的密钥，为空时启动后随机生成
+	HashSalt            string // 投票人标识、名单令牌和 IP 哈希时使用的密钥，为空时使用 HashSaltFile 中的密钥
+	HashSaltFile        string // 未设置 HashSalt 时保存密钥的文件，首次启动时随机生成
+
+	AnomalyWindow   time.Duration // 异常检测的时间窗口
+	AnomalyMaxVotes int           // 窗口内超过该票数视为异常
+
+	PoWDifficulty int           // 投票工作量证明难度（前导零比特数），0 表示关闭
+	MinVoteDelay  time.Duration // 打开投票页到投票的最短时间，0 表示关闭
+	VoteHoneypot  bool          // 投票需带有页面脚本计算的校验头，且隐藏的诱饵字段为空
+	GzipMinSize   int           // 响应体超过该字节数时压缩
+	JSONP         bool          // 结果类接口支持 ?callback= 返回 JSONP
+
+	RequestTimeout     time.Duration // 单个请求的处理时限，0 表示不限制
+	SlowQueryThreshold time.Duration // 耗时超过该值的查询记录日志，0 表示不记录
+
+	RenderConcurrency  int           // 同时渲染页面的上限，0 表示不限制
+	RenderQueueTimeout time.Duration // 名额已满时的排队时间，0 表示直接返回 503
+
+	TrustedProxies []*net.IPNet // 可信反向代理网段，只有来自这些地址的 X-Forwarded-For 才被采信
+
+	PDFFont string // PDF 导出用的 TTF 字体，为空时使用内置字体（不支持中文）
+
+	WriteInDistance int           // 自填答案归并建议的最大编辑距离
+	ViewWindow      time.Duration // 浏览次数去重的时间窗口
+	ChartMinOptions int           // 选项少于该数量时建议不画图表
+
+	DefaultMinChoices int // 多选投票未指定 min_choices 时使用的值
+	DefaultMaxChoices int // 多选投票未指定 max_choices 时使用的值
+	JoinCodeLength    int // 新投票的投票代码长度，0 表示不生成
+
+	StrictOptions  bool    // 选项重复检查忽略大小写和多余空白
+	DecisiveMargin float64 // 第一名领先第二名达到该百分比时结果视为明确
+
+	MaxPollsPerCreator int    // 每个创建者最多保留的投票数，0 表示不限制
+	BlocklistFile      string // 屏蔽词文件，标题、选项和自填答案不能包含其中的词
+
+	QRCacheSize int           // 缓存的二维码数量上限，0 表示不缓存
+	QRCacheTTL  time.Duration // 二维码缓存的有效期
+
+	Memory    bool   // 使用内存数据库，不写 data/toupiao.db
+	BackupDir string // 数据库备份目录，为空时不能备份
+	SPADir    string // 单页应用构建目录，为空时使用内置首页
+
+	PprofAddr string // pprof 调试接口的监听地址，为空时关闭
+
+	CSP        string        // HTML 页面的 Content-Security-Policy，为空时不发送
+	HSTSMaxAge time.Duration // HTTPS 访问时 Strict-Transport-Security 的 max-age，0 表示不发送
+
+	TLSCert          string   // HTTPS 证书文件，与 TLSKey 成对设置
+	TLSKey           string   // HTTPS 私钥文件
+	AutocertDomains  []string // 自动申请 Let's Encrypt 证书的域名，与证书文件二选一
+	AutocertCacheDir string   // 自动证书缓存目录
+	AutocertEmail    string   // Let's Encrypt 账号邮箱
+	TLSAddr          string   // HTTPS 监听地址
+	HTTPRedirectAddr string   // HTTP 到 HTTPS 重定向的监听地址，为空时不监听
+
+	SMTPAddr     string // 通知邮件的 SMTP 服务器，为空时不发邮件
+	SMTPFrom     string // 发件人地址
+	SMTPUser     string // SMTP 认证用户名，为空时不认证
+	SMTPPassword string // SMTP 认证密码
+}
+
+var cfg Config
+
+// envOr 读取环境变量，未设置时返回默认值
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// flagEnv 默认值来自环境变量的参数。环境变量优先于配置文件，新增此类参数时需同步登记
+var flagEnv = map[string]string{
+	"base-url":         "BASE_URL",
+	"webhook-secret":   "WEBHOOK_SECRET",
+	"admin-token":      "ADMIN_TOKEN",
+	"secret-key":       "SECRET_KEY",
+	"hash-salt":        "HASH_SALT",
+	"pdf-font":         "PDF_FONT",
+	"trusted-proxies":  "TRUSTED_PROXIES",
+	"backup-dir":       "BACKUP_DIR",
+	"spa":              "SPA_DIR",
+	"blocklist-file":   "BLOCKLIST_FILE",
+	"csp":              "CSP",
+	"tls-cert":         "TLS_CERT",
+	"tls-key":          "TLS_KEY",
+	"autocert-domains": "AUTOCERT_DOMAINS",
+	"autocert-email":   "AUTOCERT_EMAIL",
+	"smtp-addr":        "SMTP_ADDR",
+	"smtp-from":        "SMTP_FROM",
+	"smtp-user":        "SMTP_USER",
+	"smtp-password":    "SMTP_PASSWORD",
+}
+
+// loadConfigFile 读取 YAML 或 JSON 配置文件（JSON 是 YAML 的子集），键与命令行参数同名，
+// 如 base-url: https://vote.example.com。优先级：命令行参数 > 环境变量 > 配置文件 > 默认值。
+// 未知的键或无法解析的值都返回错误
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "config" || fs.Lookup(key) == nil {
+			return fmt.Errorf("%s: unknown key %q", path, key)
+		}
+		value, err := configValue(values[key])
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+		if explicit[key] || os.Getenv(flagEnv[key]) != "" {
+			continue
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %s: invalid value %q", path, key, value)
+		}
+	}
+	return nil
+}
+
+// configValue 把配置文件中的值转换为命令行参数的写法，列表以逗号连接
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFlagSet 与 main 中同名的部分参数，默认值同样来自环境变量
+func testFlagSet() (*flag.FlagSet, *Config) {
+	var c Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config", "", "")
+	fs.StringVar(&c.BaseURL, "base-url", envOr("BASE_URL", "https://tp.starpix.cn"), "")
+	fs.StringVar(&c.AdminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "")
+	fs.IntVar(&c.AnomalyMaxVotes, "anomaly-max-votes", 60, "")
+	fs.DurationVar(&c.ViewWindow, "view-window", 30*time.Minute, "")
+	fs.BoolVar(&c.Memory, "memory", false, "")
+	fs.StringVar(&c.WebhookSecret, "webhook-secret", os.Getenv("WEBHOOK_SECRET"), "")
+	return fs, &c
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	yamlPath := writeConfig(t, "config.yaml", `
+base-url: https://vote.example.com
+admin-token: from-file
+anomaly-max-votes: 10
+view-window: 5m
+memory: true
+webhook-secret: from-file
+`)
+
+	// 配置文件覆盖默认值
+	fs, c := testFlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(fs, yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseURL != "https://vote.example.com" || c.AdminToken != "from-file" || c.AnomalyMaxVotes != 10 || c.ViewWindow != 5*time.Minute || !c.Memory || c.WebhookSecret != "from-file" {
+		t.Errorf("配置文件的值 = %+v", *c)
+	}
+
+	// 命令行参数 > 环境变量 > 配置文件
+	t.Setenv("ADMIN_TOKEN", "from-env")
+	t.Setenv("WEBHOOK_SECRET", "from-env")
+	fs, c = testFlagSet()
+	if err := fs.Parse([]string{"-webhook-secret", "from-flag", "-anomaly-max-votes", "99"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(fs, yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if c.WebhookSecret != "from-flag" || c.AnomalyMaxVotes != 99 || c.AdminToken != "from-env" || c.BaseURL != "https://vote.example.com" {
+		t.Errorf("覆盖顺序错误: %+v", *c)
+	}
+
+	// JSON 同样可用，列表以逗号连接
+	fs, c = testFlagSet()
+	fs.Parse(nil)
+	if err := loadConfigFile(fs, writeConfig(t, "config.json", `{"base-url": "https://json.example.com", "webhook-secret": ["a", "b"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseURL != "https://json.example.com" || c.WebhookSecret != "from-env" {
+		t.Errorf("JSON 配置 = %+v", *c)
+	}
+}
+
+func TestLoadConfigFileRejectsInvalid(t *testing.T) {
+	for content, want := range map[string]string{
+		"unknown-key: 1":          `unknown key "unknown-key"`,
+		"config: other.yaml":      `unknown key "config"`,
+		"anomaly-max-votes: many": "anomaly-max-votes: invalid value",
+		"view-window: soon":       "view-window: invalid value",
+		"base-url: {a: b}":        "base-url: unsupported value",
+		"base-url: [":             "config.yaml",
+	} {
+		fs, _ := testFlagSet()
+		fs.Parse(nil)
+		err := loadConfigFile(fs, writeConfig(t, "config.yaml", content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v，期望包含 %q", content, err, want)
+		}
+	}
+
+	fs, _ := testFlagSet()
+	if err := loadConfigFile(fs, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("配置文件不存在时应返回错误")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// confirmTokenTTL 确认令牌的有效期，超时需重新提交
+const confirmTokenTTL = 5 * time.Minute
+
+var errConfirmRequired = errors.New("this poll requires confirmation: submit to /api/vote/prepare, then /api/vote/confirm")
+
+var usedConfirmTokens = newReplayGuard()
+
+// confirmPayload 确认令牌中签名的内容：待确认的投票和过期时间
+type confirmPayload struct {
+	Vote    VoteRequest `json:"vote"`
+	Expires int64       `json:"exp"`   // Unix 秒
+	Nonce   string      `json:"nonce"` // 同一选择多次 prepare 得到不同令牌
+}
+
+// newConfirmToken 签发确认令牌：base64url(JSON).签名
+func newConfirmToken(req VoteRequest, expires time.Time) (string, error) {
+	data, err := json.Marshal(confirmPayload{Vote: req, Expires: expires.Unix(), Nonce: randomHex(8)})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signString(payload), nil
+}
+
+// verifyConfirmToken 校验确认令牌的签名和有效期，并占用令牌，返回其中的投票。
+// 占用后并发的同一令牌会被拒绝；计票失败时由调用方 Release，令牌仍可使用
+func verifyConfirmToken(token string) (*VoteRequest, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !verifyString(payload, signature) {
+		return nil, errors.New("invalid confirm token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.New("invalid confirm token")
+	}
+	var p confirmPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, errors.New("invalid confirm token")
+	}
+	expires := time.Unix(p.Expires, 0)
+	if time.Now().After(expires) {
+		return nil, errors.New("confirm token expired, please submit again")
+	}
+	if !usedConfirmTokens.Use(token, expires) {
+		return nil, errors.New("confirm token already used")
+	}
+	return &p.Vote, nil
+}
+
+// apiVotePrepareHandler 两步投票的第一步：检查选择并返回确认令牌，此时不计票。
+// 防刷检查在这一步完成，确认时不再重复
+func apiVotePrepareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var req VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err := store.Get(req.PollID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if poll.IsClosed() {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "poll is closed",
+		})
+		return
+	}
+	// 自填答案的组合规则由计票时检查
+	if req.WriteIn == "" {
+		if err := checkBallot(poll, req.Options, inviteGroup(poll.ID, req.Token)); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+	if _, ok := checkVoteGuards(w, r, &req); !ok {
+		return
+	}
+
+	expires := time.Now().Add(confirmTokenTTL)
+	token, err := newConfirmToken(req, expires)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"confirm_token": token,
+		"expires_at":    expires.UTC(),
+		"options":       req.Options,
+		"write_in":      req.WriteIn,
+	})
+}
+
+// apiVoteConfirmHandler 两步投票的第二步：凭确认令牌计票，每个令牌只能使用一次
+func apiVoteConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var body struct {
+		ConfirmToken string `json:"confirm_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	req, err := verifyConfirmToken(body.ConfirmToken)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 计票成功（已提交）才算用掉令牌；名额已满、投票已结束等失败时释放，投票人不必重新确认
+	if !recordVote(w, r, req) {
+		usedConfirmTokens.Release(body.ConfirmToken)
+	}
+}
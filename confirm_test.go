@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func prepareVote(t *testing.T, pollID string, options ...string) string {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/vote/prepare", map[string]interface{}{"poll_id": pollID, "options": options})
+	body := decodeBody(t, rec)
+	if rec.Code != http.StatusOK || body["success"] != true {
+		t.Fatalf("prepare 失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	return body["confirm_token"].(string)
+}
+
+func confirmVote(t *testing.T, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/vote/confirm", map[string]string{"confirm_token": token})
+}
+
+func TestConfirmVote(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "confirm_vote": true})
+
+	// 需要确认的投票不能直接投
+	if body := decodeBody(t, castVote(t, pollID, "a")); body["success"] == true || body["error"] != errConfirmRequired.Error() {
+		t.Errorf("直接投票: %v", body)
+	}
+
+	// prepare 时不计票，confirm 后计票
+	token := prepareVote(t, pollID, "a")
+	if got := mustGet(t, pollID).VoterCount; got != 0 {
+		t.Fatalf("prepare 后 voter_count = %d，期望 0", got)
+	}
+	if rec := confirmVote(t, token); rec.Code != http.StatusOK || decodeBody(t, rec)["success"] != true {
+		t.Fatalf("confirm 失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if poll := mustGet(t, pollID); poll.VoterCount != 1 || poll.Votes["a"] != 1 {
+		t.Errorf("confirm 后 voter_count = %d，votes = %v", poll.VoterCount, poll.Votes)
+	}
+
+	// 同一令牌只能使用一次
+	if rec := confirmVote(t, token); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "already used") {
+		t.Errorf("重复 confirm（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	// 伪造、篡改和过期的令牌都被拒绝
+	payload, signature, _ := strings.Cut(prepareVote(t, pollID, "a"), ".")
+	forged, _ := newConfirmToken(VoteRequest{PollID: pollID, Options: []string{"b"}}, time.Now().Add(time.Minute))
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	expired, _ := newConfirmToken(VoteRequest{PollID: pollID, Options: []string{"b"}}, time.Now().Add(-time.Second))
+	for name, bad := range map[string]string{
+		"篡改内容": forgedPayload + "." + signature,
+		"篡改签名": payload + "." + strings.Repeat("0", len(signature)),
+		"没有签名": payload,
+		"过期":   expired,
+		"空令牌":  "",
+	} {
+		if rec := confirmVote(t, bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s的令牌状态码 = %d，期望 400", name, rec.Code)
+		}
+	}
+	if poll := mustGet(t, pollID); poll.VoterCount != 1 || poll.Votes["b"] != 0 {
+		t.Errorf("被拒绝的令牌计入了选票: voter_count = %d，votes = %v", poll.VoterCount, poll.Votes)
+	}
+
+	// prepare 时检查选择
+	rec := doRequest(t, http.MethodPost, "/api/vote/prepare", map[string]interface{}{"poll_id": pollID, "options": []string{"a", "b"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("单选投票 prepare 两个选项状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestConfirmTokenReleasedOnFailure(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "confirm_vote": true,
+		"option_capacity": map[string]int{"a": 1},
+	})
+	first, second := prepareVote(t, pollID, "a"), prepareVote(t, pollID, "a")
+	if rec := confirmVote(t, first); rec.Code != http.StatusOK {
+		t.Fatalf("confirm 失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	// 计票失败时令牌没有被用掉，再次确认得到同样的错误而不是“已使用”
+	for i := 0; i < 2; i++ {
+		if rec := confirmVote(t, second); rec.Code != http.StatusConflict {
+			t.Errorf("第 %d 次确认名额已满的选票（%d）: %s，期望 409", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
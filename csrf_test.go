@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// csrfCookie 打开页面并取出下发的 CSRF 令牌
+func csrfCookie(t *testing.T, path string) string {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, path, nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			if c.HttpOnly {
+				t.Error("CSRF Cookie 不能是 HttpOnly，前端脚本需要读取")
+			}
+			return c.Value
+		}
+	}
+	t.Fatalf("%s 没有下发 %s Cookie", path, csrfCookieName)
+	return ""
+}
+
+func TestCSRFOnVoteAndCreate(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	token := csrfCookie(t, "/poll/"+pollID)
+	if createToken := csrfCookie(t, "/create"); len(createToken) != 32 {
+		t.Errorf("创建页的令牌 = %q", createToken)
+	}
+
+	vote := map[string]interface{}{"poll_id": pollID, "options": []string{"a"}}
+	create := map[string]interface{}{"title": "t", "options": []string{"a", "b"}}
+	cookie := csrfCookieName + "=" + token
+
+	for _, tc := range []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"缺少令牌", []string{"Cookie", cookie}, http.StatusForbidden},
+		{"令牌错误", []string{"Cookie", cookie, csrfHeaderName, "0123456789abcdef0123456789abcdef"}, http.StatusForbidden},
+		{"有其他 Cookie 但没有 CSRF Cookie", []string{"Cookie", "voter_id=x", csrfHeaderName, token}, http.StatusForbidden},
+		{"令牌正确", []string{"Cookie", cookie, csrfHeaderName, token}, http.StatusOK},
+		{"不带 Cookie 的 API 客户端", nil, http.StatusOK},
+	} {
+		if rec := doRequest(t, http.MethodPost, "/api/vote", vote, tc.headers...); rec.Code != tc.want {
+			t.Errorf("投票 %s: 状态码 = %d，期望 %d", tc.name, rec.Code, tc.want)
+		}
+		if rec := doRequest(t, http.MethodPost, "/api/create-poll", create, tc.headers...); rec.Code != tc.want {
+			t.Errorf("创建 %s: 状态码 = %d，期望 %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	// 携带管理令牌的请求不需要 CSRF 令牌
+	headers := append([]string{"Cookie", cookie}, adminHeader...)
+	if rec := doRequest(t, http.MethodPost, "/api/create-poll", create, headers...); rec.Code != http.StatusOK {
+		t.Errorf("管理令牌创建: 状态码 = %d，期望 200", rec.Code)
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var errPollLocked = errors.New("poll is locked: options and choice limits cannot change after voting has started")
+
+// 重命名和调整选项顺序的错误
+var (
+	errOptionNotFound = errors.New("option not found")
+	errOptionExists   = errors.New("an option with the new name already exists")
+	errOptionsChanged = errors.New("options were changed concurrently, please retry")
+	errNotReordering  = errors.New("options must contain exactly the current options")
+)
+
+// PollEdit 修改投票的请求，未提供的字段保持不变
+type PollEdit struct {
+	Title       *string   `json:"title"`
+	Options     *[]string `json:"options"`
+	MultiSelect *bool     `json:"multi_select"`
+	MinChoices  *int      `json:"min_choices"`
+	MaxChoices  *int      `json:"max_choices"`
+}
+
+// lockedCondition 投票已锁定的 SQL 条件（polls 表）：有投票人，或留有任何一轮的投票记录。
+// 开始新一轮会把投票人数清零，但往期的投票记录和存档仍按原选项统计
+const lockedCondition = `(voter_count > 0 OR EXISTS (SELECT 1 FROM vote_events WHERE vote_events.poll_id = polls.id))`
+
+// Locked 投票开始后（有投票人或任何一轮的投票记录）选项和选择数量限制不能再修改
+func (ps *PollStore) Locked(id string) (bool, error) {
+	var locked bool
+	err := ps.db.QueryRow(`SELECT `+lockedCondition+` FROM polls WHERE id = ?`, id).Scan(&locked)
+	return locked, err
+}
+
+// changesConfig 修改是否涉及锁定的配置（选项、单选/多选和选择数量）
+func (e *PollEdit) changesConfig(poll *Poll) bool {
+	return (e.Options != nil && !reflect.DeepEqual(*e.Options, poll.Options)) ||
+		(e.MultiSelect != nil && *e.MultiSelect != poll.MultiSelect) ||
+		(e.MinChoices != nil && *e.MinChoices != poll.MinChoices) ||
+		(e.MaxChoices != nil && *e.MaxChoices != poll.MaxChoices)
+}
+
+// Update 修改投票。已锁定的投票只能修改标题
+func (ps *PollStore) Update(id string, edit PollEdit) (*Poll, error) {
+	poll, err := ps.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if edit.changesConfig(poll) {
+		locked, err := ps.Locked(id)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return nil, errPollLocked
+		}
+	}
+
+	req := templateConfigFromPoll(poll)
+	if edit.Title != nil {
+		req.Title = *edit.Title
+	}
+	if edit.Options != nil {
+		req.Options = *edit.Options
+	}
+	if edit.MultiSelect != nil {
+		req.MultiSelect = *edit.MultiSelect
+	}
+	if edit.MinChoices != nil {
+		req.MinChoices = edit.MinChoices
+	}
+	if edit.MaxChoices != nil {
+		req.MaxChoices = edit.MaxChoices
+	}
+	// 删除的选项不再保留颜色和名额
+	colors := make(map[string]string)
+	for _, opt := range req.Options {
+		if c, ok := poll.OptionColors[opt]; ok {
+			colors[opt] = c
+		}
+	}
+	req.OptionColors = colors
+	capacity := make(map[string]int)
+	for _, opt := range req.Options {
+		if c, ok := poll.OptionCapacity[opt]; ok {
+			capacity[opt] = c
+		}
+	}
+	req.OptionCapacity = capacity
+	req.HiddenOptions = slices.DeleteFunc(slices.Clone(poll.HiddenOptions), func(opt string) bool {
+		return !slices.Contains(req.Options, opt)
+	})
+	if errs := req.Validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	minChoices, maxChoices := req.choiceLimits()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 在事务内再次确认没有新投票，避免与并发投票竞争
+	result, err := tx.Exec(`
+		UPDATE polls SET title = ?, options = ?, multi_select = ?, min_choices = ?, max_choices = ?
+		WHERE id = ? AND (NOT `+lockedCondition+` OR ?)
+	`, req.Title, strings.Join(req.Options, "|||"), req.MultiSelect, minChoices, maxChoices, id, !edit.changesConfig(poll))
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, errPollLocked
+	}
+
+	if edit.Options != nil && !reflect.DeepEqual(*edit.Options, poll.Options) {
+		if _, err := tx.Exec(`DELETE FROM votes WHERE poll_id = ?`, id); err != nil {
+			return nil, err
+		}
+		for _, opt := range req.Options {
+			if _, err := tx.Exec(`
+				INSERT INTO votes (poll_id, option_name, vote_count, color, capacity, hidden)
+				VALUES (?, ?, 0, ?, ?, ?)
+			`, id, opt, colors[opt], capacity[opt], slices.Contains(req.HiddenOptions, opt)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(id)
+}
+
+// RenameOption 修改选项名并保留票数，已锁定的投票也可以修改（用于改正错别字）。
+// 选项列表、票数行、投票记录、往期轮次存档和问卷的显示条件在同一事务中更新，新名称与已有选项相同时返回 errOptionExists
+func (ps *PollStore) RenameOption(pollID, oldName, newName string) (*Poll, error) {
+	poll, err := ps.Get(pollID)
+	if err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return poll, nil
+	}
+	idx := slices.Index(poll.Options, oldName)
+	if idx < 0 {
+		return nil, errOptionNotFound
+	}
+	if slices.Contains(poll.Options, newName) {
+		return nil, errOptionExists
+	}
+
+	req := templateConfigFromPoll(poll)
+	req.Options = slices.Clone(poll.Options)
+	req.Options[idx] = newName
+	renameOptionSettings(&req, oldName, newName)
+	if errs := req.Validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := replaceOptionsTx(tx, poll, req.Options); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE votes SET option_name = ? WHERE poll_id = ? AND option_name = ?`, newName, pollID, oldName); err != nil {
+		return nil, err
+	}
+	// 重新计票依据投票记录，记录中的旧名称也要改掉
+	if err := renameInVoteEventsTx(tx, pollID, oldName, newName); err != nil {
+		return nil, err
+	}
+	// 问卷中依据该选项显示的问题，条件里的选项名同步修改，否则条件再也不会成立
+	if _, err := tx.Exec(`UPDATE survey_questions SET show_if_option = ? WHERE show_if_poll = ? AND show_if_option = ?`, newName, pollID, oldName); err != nil {
+		return nil, err
+	}
+	if err := renameInRoundsTx(tx, pollID, oldName, newName); err != nil {
+		return nil, err
+	}
+	if err := logAudit(tx, pollID, "rename_option", fmt.Sprintf("%q -> %q", oldName, newName)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(pollID)
+}
+
+// renameOptionSettings 把按选项名设置的颜色、名额和隐藏标记转到新名称下，供重命名前的校验使用
+func renameOptionSettings(req *CreatePollRequest, oldName, newName string) {
+	if c, ok := req.OptionColors[oldName]; ok {
+		colors := maps.Clone(req.OptionColors)
+		delete(colors, oldName)
+		colors[newName] = c
+		req.OptionColors = colors
+	}
+	if c, ok := req.OptionCapacity[oldName]; ok {
+		capacity := maps.Clone(req.OptionCapacity)
+		delete(capacity, oldName)
+		capacity[newName] = c
+		req.OptionCapacity = capacity
+	}
+	if i := slices.Index(req.HiddenOptions, oldName); i >= 0 {
+		req.HiddenOptions = slices.Clone(req.HiddenOptions)
+		req.HiddenOptions[i] = newName
+	}
+}
+
+// ReorderOptions 调整选项的显示顺序，票数不变。options 必须恰好是现有选项的一个排列
+func (ps *PollStore) ReorderOptions(pollID string, options []string) (*Poll, error) {
+	poll, err := ps.Get(pollID)
+	if err != nil {
+		return nil, err
+	}
+	if len(options) != len(poll.Options) {
+		return nil, errNotReordering
+	}
+	sorted, current := slices.Clone(options), slices.Clone(poll.Options)
+	slices.Sort(sorted)
+	slices.Sort(current)
+	if !slices.Equal(sorted, current) {
+		return nil, errNotReordering
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := replaceOptionsTx(tx, poll, options); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(pollID)
+}
+
+// replaceOptionsTx 写入新的选项列表。只有选项仍与读取时相同才更新，否则返回 errOptionsChanged
+func replaceOptionsTx(tx *timedTx, poll *Poll, options []string) error {
+	result, err := tx.Exec(`UPDATE polls SET options = ? WHERE id = ? AND options = ?`,
+		strings.Join(options, "|||"), poll.ID, strings.Join(poll.Options, "|||"))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errOptionsChanged
+	}
+	return nil
+}
+
+// renameInVoteEventsTx 把投票记录中所选的旧选项名替换为新名称
+func renameInVoteEventsTx(tx *timedTx, pollID, oldName, newName string) error {
+	rows, err := tx.Query(`SELECT id, options FROM vote_events WHERE poll_id = ?`, pollID)
+	if err != nil {
+		return err
+	}
+	updates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var options string
+		if err := rows.Scan(&id, &options); err != nil {
+			rows.Close()
+			return err
+		}
+		if options == "" {
+			continue
+		}
+		selected := strings.Split(options, "|||")
+		if i := slices.Index(selected, oldName); i >= 0 {
+			selected[i] = newName
+			updates[id] = strings.Join(selected, "|||")
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, options := range updates {
+		if _, err := tx.Exec(`UPDATE vote_events SET options = ? WHERE id = ?`, options, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiRenameOptionHandler 管理员修改选项名，请求体 {"old_name": "Piza", "new_name": "Pizza"}
+func apiRenameOptionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		OldName string `json:"old_name"`
+		NewName string `json:"new_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err := store.RenameOption(r.PathValue("id"), req.OldName, strings.TrimSpace(req.NewName))
+	writeOptionEditResult(w, poll, err)
+}
+
+// apiReorderOptionsHandler 管理员调整选项顺序，请求体 {"options": [...]}，票数不变
+func apiReorderOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		Options []string `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err := store.ReorderOptions(r.PathValue("id"), req.Options)
+	writeOptionEditResult(w, poll, err)
+}
+
+func writeOptionEditResult(w http.ResponseWriter, poll *Poll, err error) {
+	if err == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"poll":    poll,
+		})
+		return
+	}
+
+	var errs ValidationErrors
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &errs):
+		writeCreateError(w, errs)
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		status, err = http.StatusNotFound, errors.New("poll not found")
+	case errors.Is(err, errOptionNotFound), errors.Is(err, errWriteInNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errNotReordering), errors.Is(err, errWriteInSeparator):
+		status = http.StatusBadRequest
+	case errors.Is(err, errOptionExists), errors.Is(err, errOptionsChanged):
+		status = http.StatusConflict
+	default:
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+func apiEditPollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var edit PollEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err := store.Update(r.PathValue("id"), edit)
+	if err != nil {
+		var errs ValidationErrors
+		switch {
+		case errors.As(err, &errs):
+			writeCreateError(w, errs)
+		case errors.Is(err, errPollLocked):
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"error":   "poll not found",
+			})
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"poll":    poll,
+	})
+}
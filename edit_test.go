@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// editPoll 以管理员身份修改投票
+func editPoll(t *testing.T, pollID string, edit map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/edit", edit, adminHeader...)
+}
+
+func TestEditLockedAfterFirstVote(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	// 投票前可以修改选项
+	if rec := editPoll(t, pollID, map[string]interface{}{"options": []string{"a", "b", "c"}, "multi_select": true, "max_choices": 2}); rec.Code != http.StatusOK {
+		t.Fatalf("投票前修改失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if poll := mustGet(t, pollID); !reflect.DeepEqual(poll.Options, []string{"a", "b", "c"}) || poll.MaxChoices != 2 {
+		t.Fatalf("修改没有生效: %+v", poll)
+	}
+
+	mustVote(t, pollID, "a")
+	for _, edit := range []map[string]interface{}{
+		{"options": []string{"a", "b"}},
+		{"multi_select": false},
+		{"min_choices": 2},
+		{"max_choices": 3},
+	} {
+		if rec := editPoll(t, pollID, edit); rec.Code != http.StatusConflict {
+			t.Errorf("投票后修改 %v 状态码 = %d，期望 409", edit, rec.Code)
+		}
+	}
+
+	// 标题仍可修改，原样提交的选项不算修改
+	rec := editPoll(t, pollID, map[string]interface{}{"title": "新标题", "options": []string{"a", "b", "c"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("投票后修改标题失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if poll := mustGet(t, pollID); poll.Title != "新标题" || len(poll.Options) != 3 || poll.Votes["a"] != 1 {
+		t.Errorf("poll = %+v", poll)
+	}
+}
+
+// renameOption 以管理员身份修改选项名
+func TestEditStaysLockedAfterNewRound(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, pollID, "a")
+	if _, err := store.NewRound(pollID); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 0 {
+		t.Fatalf("新一轮 voter_count = %d，期望 0", got)
+	}
+	if rec := editPoll(t, pollID, map[string]interface{}{"options": []string{"x", "y"}}); rec.Code != http.StatusConflict {
+		t.Errorf("新一轮后修改选项状态码 = %d，期望 409", rec.Code)
+	}
+}
+
+func renameOption(t *testing.T, pollID, oldName, newName string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/options/rename",
+		map[string]interface{}{"old_name": oldName, "new_name": newName}, headers...)
+}
+
+func TestRenameOptionKeepsCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "午饭", "options": []string{"Piza", "Salad"}})
+	mustVote(t, pollID, "Piza")
+	mustVote(t, pollID, "Piza")
+	mustVote(t, pollID, "Salad")
+	if _, err := store.NewRound(pollID); err != nil {
+		t.Fatal(err)
+	}
+	mustVote(t, pollID, "Piza")
+
+	if rec := renameOption(t, pollID, "Piza", "Pizza"); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("非管理员重命名状态码 = %d，期望 401/403", rec.Code)
+	}
+
+	rec := renameOption(t, pollID, "Piza", "Pizza", adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("重命名失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	poll := mustGet(t, pollID)
+	if !reflect.DeepEqual(poll.Options, []string{"Pizza", "Salad"}) {
+		t.Errorf("options = %v", poll.Options)
+	}
+	if poll.Votes["Pizza"] != 1 || poll.Votes["Salad"] != 0 {
+		t.Errorf("重命名后票数 = %v，期望 Pizza:1", poll.Votes)
+	}
+	if _, ok := poll.Votes["Piza"]; ok {
+		t.Errorf("旧选项名仍在票数中: %v", poll.Votes)
+	}
+
+	// 已存档的轮次也用新名称
+	rounds, err := store.ListRounds(poll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 1 || rounds[0].Votes["Pizza"] != 2 || rounds[0].Votes["Salad"] != 1 {
+		t.Errorf("存档轮次 = %+v，期望 Pizza:2 Salad:1", rounds)
+	}
+
+	// 投票记录也已改名，重新计票结果不变
+	var stale int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM vote_events WHERE poll_id = ? AND options LIKE '%Piza%' AND options NOT LIKE '%Pizza%'`, pollID).Scan(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if stale != 0 {
+		t.Errorf("还有 %d 条投票记录使用旧选项名", stale)
+	}
+	recounted, err := store.Recount(pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recounted.Votes["Pizza"] != 1 {
+		t.Errorf("重新计票 = %v，期望 Pizza:1", recounted.Votes)
+	}
+}
+
+func TestRenameOptionUpdatesSurveyBranches(t *testing.T) {
+	setupTest(t)
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title": "问卷",
+		"questions": []map[string]interface{}{
+			{"title": "q1", "options": []string{"yse", "no"}},
+			{"title": "q2", "options": []string{"x", "y"}},
+		},
+		"branches": []map[string]interface{}{{"question": 1, "if_question": 0, "if_option": "yse"}},
+	})
+	if rec := renameOption(t, q[0], "yse", "yes", adminHeader...); rec.Code != http.StatusOK {
+		t.Fatalf("重命名失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	// 显示条件跟着改名，选了新名称时 q2 照常显示
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/survey/"+surveyID, nil))
+	showIf := body["survey"].(map[string]interface{})["show_if"].(map[string]interface{})
+	if cond := showIf[q[1]].(map[string]interface{}); cond["option"] != "yes" {
+		t.Errorf("q2 的显示条件 = %v，期望 yes", cond)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("漏答显示的 q2 状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}, q[1]: {"x"}}); rec.Code != http.StatusOK {
+		t.Errorf("提交失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRenameOptionRejectsCollision(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "午饭", "options": []string{"Piza", "Salad"}})
+	mustVote(t, pollID, "Piza")
+
+	for _, tc := range []struct {
+		oldName, newName string
+		want             int
+	}{
+		{"Piza", "Salad", http.StatusConflict},
+		{"Burger", "Pizza", http.StatusNotFound},
+		{"Piza", "  ", http.StatusBadRequest},
+		{"Piza", "A|||B", http.StatusBadRequest},
+	} {
+		if rec := renameOption(t, pollID, tc.oldName, tc.newName, adminHeader...); rec.Code != tc.want {
+			t.Errorf("%q -> %q 状态码 = %d，期望 %d: %s", tc.oldName, tc.newName, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+	poll := mustGet(t, pollID)
+	if !reflect.DeepEqual(poll.Options, []string{"Piza", "Salad"}) || poll.Votes["Piza"] != 1 || poll.Votes["Salad"] != 0 {
+		t.Errorf("被拒绝的重命名改动了投票: options=%v votes=%v", poll.Options, poll.Votes)
+	}
+}
+
+func TestReorderOptionsKeepsCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}})
+	mustVote(t, pollID, "b")
+
+	reorder := func(options ...string) *httptest.ResponseRecorder {
+		return doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/options/order",
+			map[string]interface{}{"options": options}, adminHeader...)
+	}
+	if rec := reorder("c", "a", "b"); rec.Code != http.StatusOK {
+		t.Fatalf("调整顺序失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	poll := mustGet(t, pollID)
+	if !reflect.DeepEqual(poll.Options, []string{"c", "a", "b"}) || poll.Votes["b"] != 1 {
+		t.Errorf("options=%v votes=%v", poll.Options, poll.Votes)
+	}
+
+	// 不是现有选项的排列时拒绝
+	for _, options := range [][]string{{"c", "a"}, {"c", "a", "d"}, {"a", "a", "b"}} {
+		if rec := reorder(options...); rec.Code != http.StatusBadRequest {
+			t.Errorf("顺序 %v 状态码 = %d，期望 400", options, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VoteEvent 投票事件日志中的一条记录
+type VoteEvent struct {
+	ID      int64
+	VotedAt time.Time
+	Round   int
+	Options []string
+	Voter   string // 实名投票记录的投票人，匿名投票为空
+}
+
+// eventLogHeader 投票事件 CSV 的表头
+var eventLogHeader = []string{"event_id", "voted_at", "round", "options", "voter"}
+
+// EachVoteEvent 按时间顺序逐条读取投票的全部事件，每次查询 exportPageSize 条，按 (voted_at, id) 翻页
+func (ps *PollStore) EachVoteEvent(pollID string, fn func(VoteEvent) error) error {
+	var lastID int64
+	for {
+		query := `SELECT id, voted_at, round, options, voter FROM vote_events WHERE poll_id = ?`
+		args := []interface{}{pollID}
+		if lastID > 0 {
+			query += ` AND (voted_at, id) > (SELECT voted_at, id FROM vote_events WHERE id = ?)`
+			args = append(args, lastID)
+		}
+		query += ` ORDER BY voted_at, id LIMIT ?`
+		args = append(args, exportPageSize)
+
+		rows, err := ps.db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		var page []VoteEvent
+		for rows.Next() {
+			var e VoteEvent
+			var options string
+			if err := rows.Scan(&e.ID, &e.VotedAt, &e.Round, &options, &e.Voter); err != nil {
+				rows.Close()
+				return err
+			}
+			if options != "" {
+				e.Options = strings.Split(options, "|||")
+			}
+			page = append(page, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		// 读完一页再回调，单连接（内存数据库）时回调中不能占用查询
+		for _, e := range page {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		lastID = page[len(page)-1].ID
+	}
+}
+
+// voterRef 投票人的匿名标识：同一投票人在同一投票中相同，但不能还原出姓名或令牌
+func voterRef(pollID, voter string) string {
+	if voter == "" {
+		return ""
+	}
+	return signString("voter:" + pollID + ":" + voter)[:16]
+}
+
+// apiEventLogHandler 管理接口：GET /api/poll/{id}/events.csv 按时间顺序流式导出全部投票事件。
+// 实名投票的投票人以匿名标识代替，选项之间用 "; " 分隔
+func apiEventLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+poll.ID+`-events.csv"`)
+	flusher, _ := w.(http.Flusher)
+	cw := csv.NewWriter(w)
+	cw.Write(eventLogHeader)
+
+	written := 0
+	err = store.EachVoteEvent(poll.ID, func(e VoteEvent) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.VotedAt.UTC().Format(time.RFC3339Nano),
+			strconv.Itoa(e.Round),
+			strings.Join(e.Options, "; "),
+			voterRef(poll.ID, e.Voter),
+		})
+		written++
+		if written%exportPageSize == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		// 表头已经发出，只能中断连接，让客户端发现导出不完整
+		log.Printf("投票事件导出中断（已输出 %d 条）: %v", written, err)
+		panic(http.ErrAbortHandler)
+	}
+}
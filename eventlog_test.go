@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getEventLog 以管理员身份下载投票事件 CSV
+func getEventLog(t *testing.T, pollID string) [][]string {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/events.csv", nil, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("导出事件状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV: %v", err)
+	}
+	if len(rows) == 0 || !reflect.DeepEqual(rows[0], eventLogHeader) {
+		t.Fatalf("表头 = %v", rows)
+	}
+	return rows[1:]
+}
+
+func TestEventLogCSV(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b", "c"}, "multi_select": true, "max_choices": 2, "anonymous": false,
+	})
+	for _, v := range []struct {
+		name    string
+		options []string
+	}{
+		{"张三", []string{"a"}},
+		{"李四", []string{"b", "c"}},
+		{"张三", []string{"c"}},
+	} {
+		if rec := voteAs(t, pollID, v.name, v.options...); rec.Code != http.StatusOK {
+			t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/events.csv", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌状态码 = %d，期望 401", rec.Code)
+	}
+
+	rows := getEventLog(t, pollID)
+	if len(rows) != 3 {
+		t.Fatalf("导出 %d 行，期望每个事件一行共 3 行: %v", len(rows), rows)
+	}
+	var prev time.Time
+	for i, want := range []string{"a", "b; c", "c"} {
+		row := rows[i]
+		if row[3] != want || row[2] != "1" {
+			t.Errorf("第 %d 行 = %v，期望选项 %q、第 1 轮", i, row, want)
+		}
+		votedAt, err := time.Parse(time.RFC3339Nano, row[1])
+		if err != nil || votedAt.Before(prev) {
+			t.Errorf("第 %d 行时间 %q 不是按时间顺序", i, row[1])
+		}
+		prev = votedAt
+	}
+
+	// 投票人以匿名标识代替，同一投票人相同
+	if rows[0][4] == "" || rows[0][4] != rows[2][4] || rows[0][4] == rows[1][4] {
+		t.Errorf("投票人标识 = %q %q %q", rows[0][4], rows[1][4], rows[2][4])
+	}
+	for _, row := range rows {
+		if strings.Contains(strings.Join(row, ","), "张三") || strings.Contains(strings.Join(row, ","), "李四") {
+			t.Errorf("导出包含投票人姓名: %v", row)
+		}
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/no-such-poll/events.csv", nil, adminHeader...); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestEventLogOrderAcrossPages(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	// 插入顺序与时间顺序相反，且多个事件时间相同，跨越多页
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	total := exportPageSize*2 + 7
+	for i := total - 1; i >= 0; i-- {
+		votedAt := base.Add(time.Duration(i/3) * time.Second)
+		if _, err := store.db.Exec(`INSERT INTO vote_events (poll_id, options, voted_at, voter, round) VALUES (?, ?, ?, '', 1)`,
+			pollID, "a", votedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := getEventLog(t, pollID)
+	if len(rows) != total {
+		t.Fatalf("导出 %d 行，期望 %d", len(rows), total)
+	}
+	seen := make(map[string]bool)
+	var prevTime time.Time
+	var prevID int64
+	for i, row := range rows {
+		votedAt, _ := time.Parse(time.RFC3339Nano, row[1])
+		id, _ := strconv.ParseInt(row[0], 10, 64)
+		if votedAt.Before(prevTime) || (votedAt.Equal(prevTime) && id <= prevID) {
+			t.Fatalf("第 %d 行 (%s, %d) 排在 (%s, %d) 之后", i, row[1], id, prevTime, prevID)
+		}
+		if seen[row[0]] {
+			t.Fatalf("事件 %s 重复导出", row[0])
+		}
+		seen[row[0]] = true
+		prevTime, prevID = votedAt, id
+	}
+}
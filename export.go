@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportPageSize 流式导出时每次从数据库读取的投票数
+const exportPageSize = 100
+
+// ndjsonContentType 每行一个 JSON 对象
+const ndjsonContentType = "application/x-ndjson"
+
+// EachPoll 按 ID 顺序分页读取全部未删除的投票（含票数），逐个交给 fn。
+// 每页读完后再加载票数和调用 fn，内存中最多同时保留一页投票
+func (ps *PollStore) EachPoll(fn func(*Poll) error) error {
+	after := ""
+	for {
+		rows, err := ps.db.Query(`SELECT `+pollColumns+` FROM polls WHERE deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?`, after, exportPageSize)
+		if err != nil {
+			return err
+		}
+		var polls []*Poll
+		for rows.Next() {
+			poll, err := scanPoll(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			polls = append(polls, poll)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(polls) == 0 {
+			return nil
+		}
+
+		if err := ps.loadVotesBatch(polls); err != nil {
+			return err
+		}
+		for _, poll := range polls {
+			if err := fn(poll); err != nil {
+				return err
+			}
+		}
+		if len(polls) < exportPageSize {
+			return nil
+		}
+		after = polls[len(polls)-1].ID
+	}
+}
+
+// wantsNDJSON 请求 ?format=ndjson 或 Accept: application/x-ndjson 时使用流式导出
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// apiExportHandler 导出全部投票的完整配置和票数。默认返回一个 JSON 文档；
+// NDJSON 格式每行一个投票，边读边写，适合投票很多时逐行处理
+func apiExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if !wantsNDJSON(r) {
+		polls, err := store.GetAll()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if polls == nil {
+			polls = []*Poll{}
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="polls-export.json"`)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success":     true,
+			"exported_at": time.Now().UTC(),
+			"polls":       polls,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="polls-export.ndjson"`)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	written := 0
+	err := store.EachPoll(func(poll *Poll) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(poll); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+	if written == 0 {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 已经输出了部分内容，只能中断连接，让客户端发现导出不完整
+	log.Printf("流式导出中断（已输出 %d 个投票）: %v", written, err)
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportNDJSON(t *testing.T) {
+	setupTest(t)
+	// 超过一页，验证分页读取不重复、不遗漏
+	want := map[string]bool{}
+	for i := 0; i < exportPageSize+5; i++ {
+		poll, err := store.Create(fmt.Sprintf("poll %d", i), []string{"a", "b"}, false, 0, 0, PollSettings{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[poll.ID] = true
+	}
+	voted, _ := createTestPoll(t, map[string]interface{}{"title": "voted", "options": []string{"a", "b"}})
+	mustVote(t, voted, "b")
+	want[voted] = true
+	deleted, deletedToken := createTestPoll(t, map[string]interface{}{"title": "deleted", "options": []string{"a", "b"}})
+	doRequest(t, http.MethodPost, "/api/delete-poll/"+deleted, nil, manageTokenHeader, deletedToken)
+
+	if rec := doRequest(t, http.MethodGet, "/api/export?format=ndjson", nil); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("没有管理令牌时状态码 = %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		headers []string
+	}{
+		{"/api/export?format=ndjson", adminHeader},
+		{"/api/export", append([]string{"Accept", ndjsonContentType}, adminHeader...)},
+	} {
+		path := tc.path
+		rec := doRequest(t, http.MethodGet, path, nil, tc.headers...)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ndjsonContentType {
+			t.Fatalf("%s: 状态码 = %d，Content-Type = %q", path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+
+		seen := map[string]bool{}
+		scanner := bufio.NewScanner(rec.Body)
+		for line := 1; scanner.Scan(); line++ {
+			var poll Poll
+			if err := json.Unmarshal(scanner.Bytes(), &poll); err != nil {
+				t.Fatalf("第 %d 行不是投票 JSON: %v\n%s", line, err, scanner.Text())
+			}
+			if poll.ID == "" || len(poll.Options) != 2 || seen[poll.ID] {
+				t.Errorf("第 %d 行 = %+v", line, poll)
+			}
+			seen[poll.ID] = true
+			if poll.ID == voted && poll.Votes["b"] != 1 {
+				t.Errorf("导出的票数 = %v", poll.Votes)
+			}
+		}
+		if len(seen) != len(want) || seen[deleted] {
+			t.Errorf("%s: 导出了 %d 个投票，期望 %d 个（不含已删除的）", path, len(seen), len(want))
+		}
+	}
+
+	// 默认仍是单个 JSON 文档
+	rec := doRequest(t, http.MethodGet, "/api/export", nil, adminHeader...)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") || len(decodeBody(t, rec)["polls"].([]interface{})) != len(want) {
+		t.Errorf("JSON 导出（%s）", rec.Header().Get("Content-Type"))
+	}
+}
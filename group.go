@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ChoiceLimits 多选投票的选择数量限制，0 表示无限制
+type ChoiceLimits struct {
+	MinChoices int `json:"min_choices"`
+	MaxChoices int `json:"max_choices"`
+}
+
+// groupNamePattern 投票人分组名，会写入邀请令牌，因此不能包含点号
+var groupNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// errChoiceCount 选择的选项数量不符合限制，接口返回 400
+var errChoiceCount = errors.New("wrong number of options")
+
+// check 检查选择的选项数量
+func (l ChoiceLimits) check(n int) error {
+	if l.MinChoices > 0 && n < l.MinChoices {
+		return fmt.Errorf("%w: at least %d options must be selected", errChoiceCount, l.MinChoices)
+	}
+	if l.MaxChoices > 0 && n > l.MaxChoices {
+		return fmt.Errorf("%w: at most %d options can be selected", errChoiceCount, l.MaxChoices)
+	}
+	return nil
+}
+
+// choiceLimitsFor 分组有单独限制时使用分组的限制，否则使用投票本身的限制
+func choiceLimitsFor(poll ChoiceLimits, groups map[string]ChoiceLimits, group string) ChoiceLimits {
+	if l, ok := groups[group]; ok && group != "" {
+		return l
+	}
+	return poll
+}
+
+// ChoiceLimits 某个分组的投票人适用的选择数量限制
+func (p *Poll) ChoiceLimits(group string) ChoiceLimits {
+	return choiceLimitsFor(ChoiceLimits{MinChoices: p.MinChoices, MaxChoices: p.MaxChoices}, p.GroupLimits, group)
+}
+
+// inviteGroup 从邀请令牌中取出投票人分组。只信任签名有效的令牌，普通名单令牌和旧格式的邀请令牌没有分组
+func inviteGroup(pollID, token string) string {
+	if !strings.HasPrefix(token, invitePrefix) || verifyInviteToken(pollID, token) != nil {
+		return ""
+	}
+	// inv.随机数.分组.过期时间.签名
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return ""
+	}
+	return parts[2]
+}
+
+// encodeGroupLimits 分组限制以 JSON 保存在 polls.group_limits 中，没有分组时保存空串
+func encodeGroupLimits(groups map[string]ChoiceLimits) (string, error) {
+	if len(groups) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(groups)
+	return string(data), err
+}
+
+func decodeGroupLimits(s string) (map[string]ChoiceLimits, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var groups map[string]ChoiceLimits
+	if err := json.Unmarshal([]byte(s), &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// validateGroupLimits 校验分组限制，规则与投票本身的 min_choices/max_choices 相同。
+// 分组写在邀请令牌中，只有名单投票能签发邀请，因此要求 access_mode 为 allowlist
+func (req *CreatePollRequest) validateGroupLimits(errs *ValidationErrors) {
+	if len(req.GroupLimits) == 0 {
+		return
+	}
+	if !req.MultiSelect {
+		errs.Add("group_limits", "group_limits requires multi_select")
+		return
+	}
+	if req.AccessMode != AccessAllowlist {
+		errs.Add("group_limits", "group_limits requires access_mode %q", AccessAllowlist)
+		return
+	}
+
+	names := make([]string, 0, len(req.GroupLimits))
+	for name := range req.GroupLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := req.GroupLimits[name]
+		field := "group_limits." + name
+		if !groupNamePattern.MatchString(name) {
+			errs.Add(field, "group name must be 1-32 characters of a-z, 0-9, _ or -")
+			continue
+		}
+		if l.MinChoices < 0 || l.MaxChoices < 0 {
+			errs.Add(field, "limits must not be negative")
+			continue
+		}
+		if l.MaxChoices > 0 && l.MinChoices > l.MaxChoices {
+			errs.Add(field, "min_choices must not exceed max_choices")
+		}
+		if l.MinChoices > len(req.Options) || l.MaxChoices > len(req.Options) {
+			errs.Add(field, "limits must not exceed the number of options")
+		}
+	}
+}
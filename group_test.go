@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGroupChoiceLimits(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":        "t",
+		"options":      []string{"a", "b", "c", "d", "e"},
+		"multi_select": true,
+		"min_choices":  1,
+		"max_choices":  4,
+		"access_mode":  AccessAllowlist,
+		"group_limits": map[string]ChoiceLimits{
+			"member": {MinChoices: 3, MaxChoices: 3},
+			"guest":  {MinChoices: 1, MaxChoices: 1},
+		},
+	})
+	invite := func(group string) string {
+		t.Helper()
+		rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/invite?ttl=1h&group="+group, nil, adminHeader...)
+		token, _ := decodeBody(t, rec)["token"].(string)
+		if rec.Code != http.StatusOK || token == "" {
+			t.Fatalf("签发 %q 分组的邀请失败（%d）: %s", group, rec.Code, rec.Body.String())
+		}
+		return token
+	}
+
+	for _, tc := range []struct {
+		group    string
+		rejected []string
+		accepted []string
+	}{
+		{"member", []string{"a"}, []string{"a", "b", "c"}},
+		{"guest", []string{"a", "b"}, []string{"a"}},
+		{"", []string{"a", "b", "c", "d", "e"}, []string{"a", "b", "c", "d"}},
+	} {
+		token := invite(tc.group)
+		if rec := voteWithToken(t, pollID, token, tc.rejected...); rec.Code != http.StatusBadRequest {
+			t.Errorf("分组 %q 选 %d 项状态码 = %d，期望 400", tc.group, len(tc.rejected), rec.Code)
+		}
+		// 被拒绝的选票不占用邀请
+		if rec := voteWithToken(t, pollID, token, tc.accepted...); rec.Code != http.StatusOK {
+			t.Errorf("分组 %q 选 %d 项（%d）: %s", tc.group, len(tc.accepted), rec.Code, rec.Body.String())
+		}
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 3 {
+		t.Errorf("voter_count = %d，期望 3", got)
+	}
+
+	// 分组写在签名中，改成其他分组后令牌失效
+	forged := strings.Replace(invite("guest"), ".guest.", ".member.", 1)
+	if rec := voteWithToken(t, pollID, forged, "a", "b", "c"); rec.Code != http.StatusForbidden {
+		t.Errorf("篡改分组的状态码 = %d，期望 403", rec.Code)
+	}
+	if body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/invite?ttl=1h&group=staff", nil, adminHeader...)); body["success"] != false {
+		t.Errorf("为没有限制的分组签发了邀请: %v", body)
+	}
+
+	// 投票 schema 按分组返回限制
+	schema := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/vote-schema?token="+invite("member"), nil))
+	if c := schema["constraints"].(map[string]interface{}); c["group"] != "member" || c["min_choices"] != float64(3) || c["max_choices"] != float64(3) {
+		t.Errorf("member 分组的限制 = %v", c)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// errBotSuspected 投票请求缺少页面脚本计算的校验头或填写了隐藏字段，多为不执行脚本的机器人
+var errBotSuspected = errors.New("vote rejected: please vote from the poll page with JavaScript enabled")
+
+// humanCheckSeed 投票页嵌入的种子，按投票 ID 签名生成，无需保存状态
+func humanCheckSeed(pollID string) string {
+	return signString("human-check:" + pollID)[:24]
+}
+
+// humanCheckAnswer 页面脚本根据种子计算的 X-Vote-Check 值：种子逆序。
+// 只用来挡住直接提交表单、不执行脚本的机器人，并不能防止专门针对本站编写的脚本
+func humanCheckAnswer(seed string) string {
+	b := []byte(seed)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// verifyHoneypot 开启 -vote-honeypot 时校验 X-Vote-Check 头，并要求隐藏的 website 字段为空
+// （页面上不可见，只有自动填表的机器人会填写）
+func verifyHoneypot(pollID, check, website string) error {
+	if strings.TrimSpace(website) != "" {
+		return errBotSuspected
+	}
+	if check == "" || check != humanCheckAnswer(humanCheckSeed(pollID)) {
+		return errBotSuspected
+	}
+	return nil
+}
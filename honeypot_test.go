@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// voteWithCheck 带 X-Vote-Check 头投票，check 为空时不带
+func voteWithCheck(t *testing.T, pollID, check string, extra map[string]interface{}, options ...string) int {
+	t.Helper()
+	req := map[string]interface{}{"poll_id": pollID, "options": options}
+	for k, v := range extra {
+		req[k] = v
+	}
+	var headers []string
+	if check != "" {
+		headers = []string{"X-Vote-Check", check}
+	}
+	return doRequest(t, http.MethodPost, "/api/vote", req, headers...).Code
+}
+
+func TestHoneypotDisabledByDefault(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if code := voteWithCheck(t, pollID, "", nil, "a"); code != http.StatusOK {
+		t.Errorf("未开启 -vote-honeypot 时不带校验头投票状态码 = %d，期望 200", code)
+	}
+	if rec := doRequest(t, http.MethodGet, "/poll/"+pollID, nil); strings.Contains(rec.Body.String(), `id="website"`) {
+		t.Error("未开启时投票页不应包含诱饵字段")
+	}
+}
+
+func TestHoneypotVote(t *testing.T) {
+	setupTest(t)
+	cfg.VoteHoneypot = true
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	otherID, _ := createTestPoll(t, map[string]interface{}{"title": "t2", "options": []string{"a", "b"}})
+
+	// 投票页嵌入种子和诱饵字段
+	seed := humanCheckSeed(pollID)
+	page := doRequest(t, http.MethodGet, "/poll/"+pollID, nil).Body.String()
+	if !strings.Contains(page, "'"+seed+"'") || !strings.Contains(page, `id="website"`) {
+		t.Fatal("投票页没有嵌入校验种子或诱饵字段")
+	}
+	answer := humanCheckAnswer(seed)
+
+	for name, tc := range map[string]struct {
+		check string
+		extra map[string]interface{}
+	}{
+		"缺少校验头":    {"", nil},
+		"校验值错误":    {"wrong", nil},
+		"未逆序的种子":   {seed, nil},
+		"其他投票的校验值": {humanCheckAnswer(humanCheckSeed(otherID)), nil},
+		"填写了诱饵字段":  {answer, map[string]interface{}{"website": "http://spam.example"}},
+	} {
+		if code := voteWithCheck(t, pollID, tc.check, tc.extra, "a"); code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400", name, code)
+		}
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 0 {
+		t.Fatalf("被拒绝的投票计入了 %d 票", got)
+	}
+
+	if code := voteWithCheck(t, pollID, answer, nil, "a"); code != http.StatusOK {
+		t.Fatalf("带正确校验头投票状态码 = %d，期望 200", code)
+	}
+	if got := mustGet(t, pollID).Votes["a"]; got != 1 {
+		t.Errorf("a = %d，期望 1", got)
+	}
+}
+
+func TestHoneypotSurvey(t *testing.T) {
+	setupTest(t)
+	cfg.VoteHoneypot = true
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title":     "问卷",
+		"questions": []map[string]interface{}{{"title": "q1", "options": []string{"a", "b"}}},
+	})
+	answers := map[string]interface{}{"survey_id": surveyID, "answers": map[string][]string{q[0]: {"a"}}}
+
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/survey/"+surveyID, nil))
+	seed, _ := body["survey"].(map[string]interface{})["human_check"].(string)
+	if seed != humanCheckSeed(surveyID) {
+		t.Fatalf("human_check = %q，期望按问卷 ID 生成的种子", seed)
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/survey-vote", answers); rec.Code != http.StatusBadRequest {
+		t.Errorf("缺少校验头提交问卷状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := doRequest(t, http.MethodPost, "/api/survey-vote", answers, "X-Vote-Check", humanCheckAnswer(seed)); rec.Code != http.StatusOK {
+		t.Errorf("带正确校验头提交问卷失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// 数据一致性问题的类型
+const (
+	IntegrityTooManyVotes   = "too_many_votes"   // 票数之和超过 投票人数 × 选项数，不可能出现
+	IntegrityOrphanedVotes  = "orphaned_votes"   // votes 中的行对应的投票不存在
+	IntegrityUnknownOption  = "unknown_option"   // votes 中的选项不在投票的选项列表中
+	IntegrityMissingVoteRow = "missing_vote_row" // 选项列表中的选项在 votes 中没有对应的行
+)
+
+// IntegrityProblem 一个数据一致性问题
+type IntegrityProblem struct {
+	PollID string `json:"poll_id"`
+	Kind   string `json:"kind"`
+	Option string `json:"option,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// VerifyIntegrity 检查 polls 与 votes 是否一致，返回发现的问题：孤立的 votes 行在前，其余按投票 ID 排列。只读，不做修复；
+// 已删除的投票仍保留票数，一并检查
+func (ps *PollStore) VerifyIntegrity() ([]IntegrityProblem, error) {
+	problems := []IntegrityProblem{}
+
+	rows, err := ps.db.Query(`
+		SELECT v.poll_id, v.option_name, v.vote_count
+		FROM votes v LEFT JOIN polls p ON p.id = v.poll_id
+		WHERE p.id IS NULL
+		ORDER BY v.poll_id, v.option_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p IntegrityProblem
+		var count int
+		if err := rows.Scan(&p.PollID, &p.Option, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Kind = IntegrityOrphanedVotes
+		p.Detail = fmt.Sprintf("votes row with %d votes belongs to a poll that does not exist", count)
+		problems = append(problems, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 按投票顺序读出其 votes 行，逐个投票比较
+	rows, err = ps.db.Query(`
+		SELECT p.id, p.options, p.voter_count, v.option_name, v.vote_count
+		FROM polls p LEFT JOIN votes v ON v.poll_id = p.id
+		ORDER BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pollID, options string
+	var voterCount int
+	counts := make(map[string]int)
+	check := func() {
+		if pollID == "" {
+			return
+		}
+		problems = append(problems, checkPollVotes(pollID, strings.Split(options, "|||"), voterCount, counts)...)
+	}
+	for rows.Next() {
+		var id, opts string
+		var voters int
+		var option *string
+		var count *int
+		if err := rows.Scan(&id, &opts, &voters, &option, &count); err != nil {
+			return nil, err
+		}
+		if id != pollID {
+			check()
+			pollID, options, voterCount = id, opts, voters
+			counts = make(map[string]int)
+		}
+		if option != nil {
+			counts[*option] = *count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	check()
+	return problems, nil
+}
+
+// checkPollVotes 比较单个投票的选项列表、投票人数和 votes 中的票数
+func checkPollVotes(pollID string, options []string, voterCount int, counts map[string]int) []IntegrityProblem {
+	var problems []IntegrityProblem
+	listed := make(map[string]bool, len(options))
+	for _, opt := range options {
+		listed[opt] = true
+		if _, ok := counts[opt]; !ok {
+			problems = append(problems, IntegrityProblem{
+				PollID: pollID,
+				Kind:   IntegrityMissingVoteRow,
+				Option: opt,
+				Detail: "option has no votes row",
+			})
+		}
+	}
+
+	total := 0
+	for _, opt := range slices.Sorted(maps.Keys(counts)) {
+		total += counts[opt]
+		if !listed[opt] {
+			problems = append(problems, IntegrityProblem{
+				PollID: pollID,
+				Kind:   IntegrityUnknownOption,
+				Option: opt,
+				Detail: fmt.Sprintf("votes row with %d votes is not one of the poll's options", counts[opt]),
+			})
+		}
+	}
+	if limit := voterCount * len(options); total > limit {
+		problems = append(problems, IntegrityProblem{
+			PollID: pollID,
+			Kind:   IntegrityTooManyVotes,
+			Detail: fmt.Sprintf("%d votes in total, but %d voters × %d options allow at most %d", total, voterCount, len(options), limit),
+		})
+	}
+	return problems
+}
+
+// apiAdminVerifyHandler 管理接口：GET /api/admin/verify 检查全部投票的数据一致性
+func apiAdminVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	problems, err := store.VerifyIntegrity()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"ok":       len(problems) == 0,
+		"problems": problems,
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// getVerify 调用 /api/admin/verify，返回 ok 与问题列表
+func getVerify(t *testing.T) (bool, []map[string]interface{}) {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/admin/verify", nil, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify 返回 %d: %s", rec.Code, rec.Body.String())
+	}
+	body := decodeBody(t, rec)
+	var problems []map[string]interface{}
+	for _, p := range body["problems"].([]interface{}) {
+		problems = append(problems, p.(map[string]interface{}))
+	}
+	return body["ok"] == true, problems
+}
+
+// findProblem 在问题列表中查找指定投票、类型和选项的问题
+func findProblem(problems []map[string]interface{}, pollID, kind, option string) bool {
+	for _, p := range problems {
+		opt, _ := p["option"].(string)
+		if p["poll_id"] == pollID && p["kind"] == kind && opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func TestVerifyIntegrityCleanData(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "午饭",
+		"options": []string{"面", "饭"},
+	})
+	mustVote(t, pollID, "面")
+
+	if rec := doRequest(t, http.MethodGet, "/api/admin/verify", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未带管理令牌返回 %d，期望 401", rec.Code)
+	}
+	ok, problems := getVerify(t)
+	if !ok || len(problems) != 0 {
+		t.Errorf("正常数据不应报告问题: ok=%v %v", ok, problems)
+	}
+}
+
+func TestVerifyIntegrityReportsCorruption(t *testing.T) {
+	setupTest(t)
+	tooMany, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "票数过多",
+		"options": []string{"a", "b"},
+	})
+	mustVote(t, tooMany, "a")
+	missing, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "缺少行",
+		"options": []string{"x", "y"},
+	})
+	unknown, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "多余行",
+		"options": []string{"p", "q"},
+	})
+
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		// 1 人投票、2 个选项，最多 2 票
+		{`UPDATE votes SET vote_count = 5 WHERE poll_id = ? AND option_name = 'a'`, []interface{}{tooMany}},
+		{`INSERT INTO votes (poll_id, option_name, vote_count) VALUES ('no-such-poll', 'ghost', 3)`, nil},
+		{`DELETE FROM votes WHERE poll_id = ? AND option_name = 'y'`, []interface{}{missing}},
+		{`INSERT INTO votes (poll_id, option_name, vote_count) VALUES (?, 'r', 0)`, []interface{}{unknown}},
+	} {
+		if _, err := store.db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("构造损坏数据: %v", err)
+		}
+	}
+
+	problems, err := store.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyIntegrity: %v", err)
+	}
+	if len(problems) != 4 {
+		t.Errorf("期望 4 个问题，得到 %d: %+v", len(problems), problems)
+	}
+	if len(problems) > 0 && problems[0].Kind != IntegrityOrphanedVotes {
+		t.Errorf("孤立的 votes 行应排在最前: %+v", problems[0])
+	}
+
+	ok, reported := getVerify(t)
+	if ok {
+		t.Error("存在问题时 ok 应为 false")
+	}
+	for _, want := range []struct{ pollID, kind, option string }{
+		{tooMany, IntegrityTooManyVotes, ""},
+		{"no-such-poll", IntegrityOrphanedVotes, "ghost"},
+		{missing, IntegrityMissingVoteRow, "y"},
+		{unknown, IntegrityUnknownOption, "r"},
+	} {
+		if !findProblem(reported, want.pollID, want.kind, want.option) {
+			t.Errorf("未报告 %s（投票 %s，选项 %q）: %v", want.kind, want.pollID, want.option, reported)
+		}
+	}
+
+	// 只读检查，不修改数据
+	if got := mustGet(t, tooMany).Votes["a"]; got != 5 {
+		t.Errorf("检查不应修复票数，a = %d", got)
+	}
+}
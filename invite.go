@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// invitePrefix 邀请令牌前缀，用来与普通名单令牌（邮箱等）区分
+const invitePrefix = "inv."
+
+// defaultInviteTTL 邀请链接默认有效期
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// newInviteToken 生成 inv.随机数.过期时间.签名 形式的邀请令牌，签名覆盖投票 ID。
+// 指定投票人分组时为 inv.随机数.分组.过期时间.签名，分组同样受签名保护
+func newInviteToken(pollID, group string, expires time.Time) string {
+	payload := invitePrefix + randomHex(16)
+	if group != "" {
+		payload += "." + group
+	}
+	payload += fmt.Sprintf(".%d", expires.Unix())
+	return payload + "." + signString(pollID+"."+payload)
+}
+
+// verifyInviteToken 校验邀请令牌的签名和有效期
+func verifyInviteToken(pollID, token string) error {
+	idx := strings.LastIndex(token, ".")
+	if idx < 0 {
+		return fmt.Errorf("invalid invite")
+	}
+	payload, sig := token[:idx], token[idx+1:]
+	if !verifyString(pollID+"."+payload, sig) {
+		return fmt.Errorf("invalid invite")
+	}
+
+	parts := strings.Split(payload, ".")
+	expires, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid invite")
+	}
+	if time.Now().After(time.Unix(expires, 0)) {
+		return fmt.Errorf("invite expired")
+	}
+	return nil
+}
+
+// CreateInvite 为名单投票签发一个一次性邀请令牌，并把它加入允许名单。group 非空时必须是投票设置了限制的分组
+func (ps *PollStore) CreateInvite(pollID string, ttl time.Duration, group string) (string, time.Time, error) {
+	poll, err := ps.Get(pollID)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("poll not found")
+	}
+	if poll.AccessMode != AccessAllowlist {
+		return "", time.Time{}, fmt.Errorf("poll is not allowlist")
+	}
+	if _, ok := poll.GroupLimits[group]; group != "" && !ok {
+		return "", time.Time{}, fmt.Errorf("unknown group %q", group)
+	}
+
+	expires := time.Now().Add(ttl)
+	token := newInviteToken(pollID, group, expires)
+	if err := ps.AddAllowedVoters(pollID, []string{token}); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+func apiInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	ttl := defaultInviteTTL
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid ttl",
+			})
+			return
+		}
+		ttl = d
+	}
+
+	pollID := r.PathValue("id")
+	group := r.URL.Query().Get("group")
+	token, expires, err := store.CreateInvite(pollID, ttl, group)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"token":      token,
+		"link":       fmt.Sprintf("%s/poll/%s?token=%s", cfg.BaseURL, pollID, url.QueryEscape(token)),
+		"expires_at": expires,
+		"group":      group,
+	})
+}
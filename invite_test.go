@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyInviteToken(t *testing.T) {
+	setupTest(t)
+	token := newInviteToken("poll-1", "", time.Now().Add(time.Hour))
+	if err := verifyInviteToken("poll-1", token); err != nil {
+		t.Fatalf("有效的邀请被拒绝: %v", err)
+	}
+	if err := verifyInviteToken("poll-2", token); err == nil {
+		t.Error("其他投票的邀请通过了校验")
+	}
+	tampered := strings.Replace(token, invitePrefix, invitePrefix+"0", 1)
+	if err := verifyInviteToken("poll-1", tampered); err == nil {
+		t.Error("被篡改的邀请通过了校验")
+	}
+	expired := newInviteToken("poll-1", "", time.Now().Add(-time.Minute))
+	if err := verifyInviteToken("poll-1", expired); err == nil || err.Error() != "invite expired" {
+		t.Errorf("过期邀请 err = %v，期望 invite expired", err)
+	}
+}
+
+func TestInviteSingleUse(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":       "t",
+		"options":     []string{"a", "b"},
+		"access_mode": AccessAllowlist,
+	})
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/invite?ttl=1h", nil, adminHeader...))
+	token, _ := body["token"].(string)
+	if !strings.HasPrefix(token, invitePrefix) {
+		t.Fatalf("invite = %v", body)
+	}
+
+	if rec := voteWithToken(t, pollID, token, "a"); rec.Code != http.StatusOK {
+		t.Fatalf("邀请投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if rec := voteWithToken(t, pollID, token, "b"); rec.Code != http.StatusForbidden {
+		t.Errorf("重复使用邀请的状态码 = %d，期望 403", rec.Code)
+	}
+	forged := token[:strings.LastIndex(token, ".")+1] + strings.Repeat("0", 64)
+	if rec := voteWithToken(t, pollID, forged, "b"); rec.Code != http.StatusForbidden {
+		t.Errorf("伪造签名的状态码 = %d，期望 403", rec.Code)
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// joinCodeAlphabet 投票代码使用的字符，去掉了容易读错的 0/O、1/I/L
+const joinCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// 投票代码长度的取值范围，0 表示不生成
+const (
+	minJoinCodeLength = 4
+	maxJoinCodeLength = 12
+)
+
+// maxJoinCodeAttempts 代码与已有投票冲突时最多重新生成的次数
+const maxJoinCodeAttempts = 10
+
+var errJoinCodeExhausted = errors.New("could not generate a unique join code, try a longer -join-code-length")
+
+// newJoinCode 生成 n 位随机投票代码
+func newJoinCode(n int) string {
+	size := big.NewInt(int64(len(joinCodeAlphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = joinCodeAlphabet[k.Int64()]
+	}
+	return string(b)
+}
+
+// normalizeJoinCode 把口头或手写的代码转成标准形式：大写，去掉空格和中划线
+func normalizeJoinCode(s string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s)))
+}
+
+// joinCodeTaken 检查代码是否已被使用，已删除投票的代码仍被占用
+func joinCodeTaken(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, code string) (bool, error) {
+	var n int
+	err := q.QueryRow(`SELECT COUNT(*) FROM polls WHERE join_code = ?`, code).Scan(&n)
+	return n > 0, err
+}
+
+// uniqueJoinCode 生成未被使用的投票代码，冲突时重试；generate 为代码生成函数
+func uniqueJoinCode(tx *timedTx, generate func() string) (string, error) {
+	for range maxJoinCodeAttempts {
+		code := generate()
+		taken, err := joinCodeTaken(tx, code)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", errJoinCodeExhausted
+}
+
+// resolveJoinCode 按投票代码查找投票 ID
+func (ps *PollStore) resolveJoinCode(code string) (string, error) {
+	code = normalizeJoinCode(code)
+	if code == "" {
+		return "", sql.ErrNoRows
+	}
+	var id string
+	err := ps.db.QueryRow(`SELECT id FROM polls WHERE join_code = ? AND deleted_at IS NULL`, code).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewJoinCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 1000 {
+		code := newJoinCode(6)
+		if len(code) != 6 || strings.Trim(code, joinCodeAlphabet) != "" {
+			t.Fatalf("代码 %q 长度不对或含有字母表以外的字符", code)
+		}
+		seen[code] = true
+	}
+	// 31^6 种组合，1000 个代码几乎不会重复
+	if len(seen) < 995 {
+		t.Errorf("1000 个代码只有 %d 个不同", len(seen))
+	}
+	if got := normalizeJoinCode(" abc-d23 "); got != "ABCD23" {
+		t.Errorf("normalizeJoinCode = %q，期望 ABCD23", got)
+	}
+}
+
+func TestUniqueJoinCodeRetriesOnCollision(t *testing.T) {
+	setupTest(t)
+	cfg.JoinCodeLength = 6
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	taken := mustGet(t, pollID).JoinCode
+	if taken == "" {
+		t.Fatal("没有生成投票代码")
+	}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// 前两次生成的代码已被占用，第三次成功
+	codes := []string{taken, taken, "FRESH2"}
+	calls := 0
+	code, err := uniqueJoinCode(tx, func() string {
+		calls++
+		return codes[calls-1]
+	})
+	if err != nil || code != "FRESH2" || calls != 3 {
+		t.Errorf("code=%q err=%v calls=%d，期望第三次得到 FRESH2", code, err, calls)
+	}
+
+	// 一直冲突时重试有限次后放弃
+	calls = 0
+	_, err = uniqueJoinCode(tx, func() string {
+		calls++
+		return taken
+	})
+	if !errors.Is(err, errJoinCodeExhausted) || calls != maxJoinCodeAttempts {
+		t.Errorf("err=%v calls=%d，期望重试 %d 次后返回 errJoinCodeExhausted", err, calls, maxJoinCodeAttempts)
+	}
+}
+
+func TestResolveByJoinCode(t *testing.T) {
+	setupTest(t)
+	plain, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if code := mustGet(t, plain).JoinCode; code != "" {
+		t.Errorf("未开启 -join-code-length 时生成了代码 %q", code)
+	}
+
+	cfg.JoinCodeLength = 6
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "口头分享", "options": []string{"a", "b"}})
+	body := decodeBody(t, rec)
+	code, _ := body["join_code"].(string)
+	pollID, _ := body["poll_id"].(string)
+	if len(code) != 6 {
+		t.Fatalf("创建响应的 join_code = %v", body["join_code"])
+	}
+
+	// 口头告知时可能是小写、带空格或中划线
+	for _, ref := range []string{code, strings.ToLower(code), code[:3] + "-" + code[3:]} {
+		rec := doRequest(t, http.MethodGet, "/poll/"+ref, nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "口头分享") {
+			t.Errorf("按代码 %q 打开投票页状态码 = %d", ref, rec.Code)
+		}
+		if poll, err := store.Resolve(ref); err != nil || poll.ID != pollID {
+			t.Errorf("Resolve(%q) = %v, %v", ref, poll, err)
+		}
+	}
+	if rec := doRequest(t, http.MethodGet, "/poll/ZZZZZZ", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的代码状态码 = %d，期望 404", rec.Code)
+	}
+}
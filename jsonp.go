@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// callbackPattern JSONP 回调名：JavaScript 标识符，可用点号访问属性（如 jQuery.cb_1），最长 64 个字符
+var callbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+const maxCallbackLength = 64
+
+// validCallback 检查 JSONP 回调名，防止在返回的脚本中注入代码
+func validCallback(name string) bool {
+	return len(name) <= maxCallbackLength && callbackPattern.MatchString(name)
+}
+
+// jsonpRecorder 暂存 JSON 响应，写完后再包装为 JSONP
+type jsonpRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *jsonpRecorder) Header() http.Header         { return rec.header }
+func (rec *jsonpRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *jsonpRecorder) WriteHeader(status int)      { rec.status = status }
+
+// jsonpHandler 开启 -jsonp 时，带 ?callback= 的请求返回 application/javascript 的 callback(JSON)，
+// 供不能使用 CORS 的旧式嵌入页面读取结果。任何网站都能以访问者的身份加载脚本，
+// 因此按匿名请求处理：去掉 Cookie 和管理令牌，只返回公开的数据
+func jsonpHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		callback := r.URL.Query().Get("callback")
+		if !cfg.JSONP || callback == "" {
+			next(w, r)
+			return
+		}
+		if !validCallback(callback) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid callback name",
+			})
+			return
+		}
+
+		anon := r.Clone(r.Context())
+		anon.Header.Del("Cookie")
+		anon.Header.Del("Authorization")
+		anon.Header.Del("X-Admin-Token")
+		anon.Header.Del(manageTokenHeader)
+		rec := &jsonpRecorder{header: make(http.Header), status: http.StatusOK}
+		next(rec, anon)
+
+		for key, values := range rec.header {
+			if key != "Content-Type" && key != "Content-Length" {
+				w.Header()[key] = values
+			}
+		}
+		// 不是 JSON 的响应（如 405）原样返回，脚本加载失败即可
+		if !strings.HasPrefix(rec.header.Get("Content-Type"), "application/json") {
+			w.Header().Set("Content-Type", rec.header.Get("Content-Type"))
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		// 脚本读不到状态码，始终返回 200 让回调执行，错误在 JSON 的 success/error 中；
+		// 开头的注释防止响应被当作其他格式解析（如 Flash 的 Rosetta 攻击）
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Write([]byte("/**/" + callback + "("))
+		w.Write(bytes.TrimSpace(rec.body.Bytes()))
+		w.Write([]byte(");"))
+	}
+}
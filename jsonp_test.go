@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidCallback(t *testing.T) {
+	for _, name := range []string{"cb", "_cb1", "$", "jQuery.cb_1", "a.b.c"} {
+		if !validCallback(name) {
+			t.Errorf("%q 应为合法的回调名", name)
+		}
+	}
+	for _, name := range []string{"", "1cb", "alert(1)", "cb;alert(1)", "a..b", "a.", ".a", "cb//", "a b", "回调", strings.Repeat("a", maxCallbackLength+1)} {
+		if validCallback(name) {
+			t.Errorf("%q 不应为合法的回调名", name)
+		}
+	}
+}
+
+func TestJSONPWrapsCounts(t *testing.T) {
+	setupTest(t)
+	cfg.JSONP = true
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "午饭",
+		"options": []string{"面", "饭"},
+	})
+	mustVote(t, pollID, "面")
+
+	plain := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil)
+	if plain.Code != http.StatusOK || !strings.HasPrefix(plain.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("不带 callback 时应返回 JSON（%d，%s）", plain.Code, plain.Header().Get("Content-Type"))
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts?callback=jQuery.cb_1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSONP 返回 %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Errorf("Content-Type = %q，期望 application/javascript", ct)
+	}
+	script := rec.Body.String()
+	const prefix, suffix = "/**/jQuery.cb_1(", ");"
+	if !strings.HasPrefix(script, prefix) || !strings.HasSuffix(script, suffix) {
+		t.Fatalf("响应未包装为回调调用: %s", script)
+	}
+	var wrapped, want interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(script, prefix), suffix)), &wrapped); err != nil {
+		t.Fatalf("回调参数不是 JSON: %v\n%s", err, script)
+	}
+	json.Unmarshal(plain.Body.Bytes(), &want)
+	if got, _ := json.Marshal(wrapped); string(got) != mustMarshal(t, want) {
+		t.Errorf("JSONP 内容与 JSON 接口不一致:\n%s\n%s", got, plain.Body.String())
+	}
+
+	// 不是 JSON 的错误响应原样返回，不包装
+	rec = doRequest(t, http.MethodGet, "/api/poll/no-such-poll/counts?callback=cb", nil)
+	if rec.Code != http.StatusNotFound || strings.HasPrefix(rec.Body.String(), "/**/") {
+		t.Errorf("不存在的投票应原样返回 404（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJSONPRejectsInvalidCallback(t *testing.T) {
+	setupTest(t)
+	cfg.JSONP = true
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "午饭",
+		"options": []string{"面", "饭"},
+	})
+
+	for _, name := range []string{"alert(document.cookie)", "cb;x=1", "</script>", "1cb"} {
+		rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts?callback="+url.QueryEscape(name), nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("回调名 %q 返回 %d，期望 400", name, rec.Code)
+			continue
+		}
+		if strings.Contains(rec.Body.String(), name) {
+			t.Errorf("错误响应不应回显回调名 %q: %s", name, rec.Body.String())
+		}
+		if body := decodeBody(t, rec); body["error"] != "invalid callback name" {
+			t.Errorf("回调名 %q 的错误为 %v", name, body["error"])
+		}
+	}
+}
+
+func TestJSONPDisabledByDefault(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":   "午饭",
+		"options": []string{"面", "饭"},
+	})
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts?callback=cb", nil)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("未开启 -jsonp 时应返回 JSON，Content-Type = %q", ct)
+	}
+	if strings.HasPrefix(rec.Body.String(), "/**/") {
+		t.Errorf("未开启 -jsonp 时不应包装: %s", rec.Body.String())
+	}
+}
+
+func TestJSONPIgnoresCredentials(t *testing.T) {
+	setupTest(t)
+	cfg.JSONP = true
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":             "午饭",
+		"options":           []string{"面", "饭"},
+		"result_visibility": "voters",
+	})
+	rec := castVote(t, pollID, "面")
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == voterCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("投票后未设置投票人 Cookie")
+	}
+
+	path := "/api/poll/" + pollID + "/counts"
+	withCookie := doRequest(t, http.MethodGet, path, nil, "Cookie", cookie.String())
+	if withCookie.Body.String() == doRequest(t, http.MethodGet, path, nil).Body.String() {
+		t.Fatal("前提不成立：投票人与匿名访问者看到的计数相同")
+	}
+
+	// 其他网站以访问者身份加载脚本时，只能拿到匿名访问者可见的数据
+	anon := doRequest(t, http.MethodGet, path+"?callback=cb", nil).Body.String()
+	for _, headers := range [][]string{
+		{"Cookie", cookie.String()},
+		adminHeader,
+	} {
+		if got := doRequest(t, http.MethodGet, path+"?callback=cb", nil, headers...).Body.String(); got != anon {
+			t.Errorf("带 %s 的 JSONP 响应与匿名访问不同:\n%s\n%s", headers[0], got, anon)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(b)
+}
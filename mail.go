@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer 发送一封纯文本邮件
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// smtpMailer 通过 -smtp-addr 配置的 SMTP 服务器发信，配置了用户名时使用 PLAIN 认证
+// （net/smtp 只在 TLS 连接或本机地址上允许 PLAIN 认证）
+type smtpMailer struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, _ := net.SplitHostPort(m.addr)
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+	msg, err := buildMessage(m.from, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{to}, msg)
+}
+
+// buildMessage 组装 UTF-8 纯文本邮件，主题按 RFC 2047 编码，正文使用 quoted-printable
+func buildMessage(from, to, subject, body string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", singleLine(subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// singleLine 去掉换行，防止标题等用户输入注入邮件头
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isEmailAddress 是否为不带显示名的单个邮箱地址
+func isEmailAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+type mailJob struct {
+	to, subject, body string
+}
+
+// MailDispatcher 在后台 goroutine 中发信，队列有界，发送失败只记录日志
+type MailDispatcher struct {
+	mailer Mailer
+	queue  chan mailJob
+}
+
+func NewMailDispatcher(mailer Mailer, queueSize int) *MailDispatcher {
+	return &MailDispatcher{mailer: mailer, queue: make(chan mailJob, queueSize)}
+}
+
+func (d *MailDispatcher) Start() {
+	go func() {
+		for job := range d.queue {
+			if err := d.mailer.Send(job.to, job.subject, job.body); err != nil {
+				log.Printf("邮件发送失败 (%s): %v", job.to, err)
+			}
+		}
+	}()
+}
+
+// Enqueue 将邮件放入队列，队列已满时丢弃并返回 false，不阻塞请求
+func (d *MailDispatcher) Enqueue(to, subject, body string) bool {
+	select {
+	case d.queue <- mailJob{to: to, subject: subject, body: body}:
+		return true
+	default:
+		log.Printf("邮件队列已满，丢弃发往 %s 的邮件", to)
+		return false
+	}
+}
+
+// mailer 在 main 中按 -smtp-addr 初始化，为 nil 时不发邮件
+var mailer *MailDispatcher
+
+// closedSummary 投票结束通知的主题和正文，列出最终票数和结果页地址
+func closedSummary(poll *Poll) (subject, body string) {
+	subject = "投票已结束：" + singleLine(poll.Title)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "投票「%s」已结束。\n\n", singleLine(poll.Title))
+	if poll.ClosedAt != nil {
+		fmt.Fprintf(&b, "结束时间：%s\n", poll.ClosedAt.Format("2006-01-02 15:04:05 -0700"))
+	}
+	fmt.Fprintf(&b, "投票人数：%d\n\n最终结果：\n", poll.VoterCount)
+	for _, opt := range poll.Options {
+		fmt.Fprintf(&b, "  %s：%d 票（%.1f%%）\n", opt, poll.Votes[opt], poll.OptionPercent(opt))
+	}
+	if poll.ClosingMessage != "" {
+		fmt.Fprintf(&b, "\n结束语：%s\n", poll.ClosingMessage)
+	}
+	fmt.Fprintf(&b, "\n查看结果：%s/api/results/%s\n", strings.TrimRight(cfg.BaseURL, "/"), poll.ID)
+	return subject, b.String()
+}
+
+// notifyClosedByEmail 投票设置了 notify_email 时把结果摘要发给创建者
+func notifyClosedByEmail(poll *Poll) {
+	if mailer == nil || poll.NotifyEmail == "" {
+		return
+	}
+	subject, body := closedSummary(poll)
+	mailer.Enqueue(poll.NotifyEmail, subject, body)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockMailer 记录发出的邮件，fail 为 true 时发送失败
+type mockMailer struct {
+	sent chan mailJob
+	fail bool
+}
+
+func (m *mockMailer) Send(to, subject, body string) error {
+	m.sent <- mailJob{to: to, subject: subject, body: body}
+	if m.fail {
+		return errors.New("smtp unavailable")
+	}
+	return nil
+}
+
+// useMailDispatcher 替换全局 mailer，不启动发送 goroutine，便于直接检查队列
+func useMailDispatcher(t *testing.T, queueSize int) *MailDispatcher {
+	t.Helper()
+	prev := mailer
+	mailer = NewMailDispatcher(&mockMailer{sent: make(chan mailJob, queueSize)}, queueSize)
+	t.Cleanup(func() { mailer = prev })
+	return mailer
+}
+
+func TestCloseQueuesSummaryEmail(t *testing.T) {
+	setupTest(t)
+	d := useMailDispatcher(t, 4)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{
+		"title":        "团建\n地点",
+		"options":      []string{"爬山", "唱歌"},
+		"notify_email": "owner@example.com",
+	})
+	mustVote(t, pollID, "爬山")
+	mustVote(t, pollID, "爬山")
+	mustVote(t, pollID, "唱歌")
+
+	// 邮箱不随投票列表公开
+	if rec := doRequest(t, http.MethodGet, "/api/polls", nil); strings.Contains(rec.Body.String(), "owner@example.com") {
+		t.Errorf("投票列表泄露了 notify_email: %s", rec.Body.String())
+	}
+	if len(d.queue) != 0 {
+		t.Fatalf("投票未结束就发了 %d 封邮件", len(d.queue))
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, map[string]interface{}{"closing_message": "周六见"}, manageTokenHeader, manageToken)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("结束投票失败: %s", rec.Body.String())
+	}
+	if len(d.queue) != 1 {
+		t.Fatalf("队列中有 %d 封邮件，期望 1", len(d.queue))
+	}
+	job := <-d.queue
+	if job.to != "owner@example.com" {
+		t.Errorf("收件人 = %q", job.to)
+	}
+	if job.subject != "投票已结束：团建 地点" {
+		t.Errorf("主题 = %q", job.subject)
+	}
+	for _, want := range []string{
+		"投票「团建 地点」已结束",
+		"投票人数：3",
+		"爬山：2 票（66.7%）",
+		"唱歌：1 票（33.3%）",
+		"结束语：周六见",
+		"/api/results/" + pollID,
+	} {
+		if !strings.Contains(job.body, want) {
+			t.Errorf("正文缺少 %q:\n%s", want, job.body)
+		}
+	}
+}
+
+func TestCloseWithoutNotifyEmailSendsNothing(t *testing.T) {
+	setupTest(t)
+	d := useMailDispatcher(t, 4)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, manageTokenHeader, manageToken); decodeBody(t, rec)["success"] != true {
+		t.Fatalf("结束投票失败: %s", rec.Body.String())
+	}
+	if len(d.queue) != 0 {
+		t.Errorf("没有设置 notify_email 时仍发了 %d 封邮件", len(d.queue))
+	}
+}
+
+func TestNotifyEmailValidation(t *testing.T) {
+	setupTest(t)
+	for _, email := range []string{"not-an-email", "Owner <owner@example.com>", "a@example.com, b@example.com"} {
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+			"title": "t", "options": []string{"a", "b"}, "notify_email": email,
+		})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("notify_email %q 状态码 = %d，期望 400", email, rec.Code)
+		}
+	}
+}
+
+func TestMailDispatcherDoesNotBlock(t *testing.T) {
+	m := &mockMailer{sent: make(chan mailJob, 4), fail: true}
+	d := NewMailDispatcher(m, 1)
+
+	// 队列满时立即丢弃
+	if !d.Enqueue("a@example.com", "s", "b") {
+		t.Fatal("第一封邮件没有入队")
+	}
+	done := make(chan bool)
+	go func() { done <- d.Enqueue("b@example.com", "s", "b") }()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("队列已满时仍返回 true")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("队列已满时 Enqueue 阻塞")
+	}
+
+	// 发送失败只记录日志，后续邮件照常发送
+	d.Start()
+	waitSent := func(want string) {
+		t.Helper()
+		select {
+		case job := <-m.sent:
+			if job.to != want {
+				t.Errorf("收件人 = %q，期望 %q", job.to, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("没有发送发往 %s 的邮件", want)
+		}
+	}
+	waitSent("a@example.com")
+	if !d.Enqueue("c@example.com", "s", "b") {
+		t.Fatal("发送失败后新邮件没有入队")
+	}
+	waitSent("c@example.com")
+}
+
+func TestBuildMessageEncodesHeaders(t *testing.T) {
+	msg, err := buildMessage("noreply@example.com", "owner@example.com", "投票已结束\r\nBcc: evil@example.com", "第一行\n第二行")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body, ok := strings.Cut(string(msg), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("邮件没有头部和正文的分隔: %q", msg)
+	}
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("主题中的换行注入了邮件头:\n%s", header)
+	}
+	if !strings.Contains(header, "Subject: =?utf-8?q?") {
+		t.Errorf("主题没有按 RFC 2047 编码:\n%s", header)
+	}
+	if !strings.Contains(header, "Content-Transfer-Encoding: quoted-printable") {
+		t.Errorf("缺少 quoted-printable 头:\n%s", header)
+	}
+	if !strings.Contains(body, "\r\n") {
+		t.Errorf("正文换行没有转成 CRLF: %q", body)
+	}
+}
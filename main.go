@@ -1,14 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"embed"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	qrcode "github.com/skip2/go-qrcode"
@@ -25,25 +41,272 @@ type Poll struct {
 	MaxChoices  int            `json:"max_choices"` // 最多选择数量，0表示无限制
 	Votes       map[string]int `json:"votes"`       // option -> count
 	VoterCount  int            `json:"voter_count"` // 投票人数
+	Views       int            `json:"views"`       // 投票页浏览次数（同一访问者在 -view-window 内只计一次）
 	CreatedAt   time.Time      `json:"created_at"`
+	ClosedAt    *time.Time     `json:"closed_at,omitempty"` // 结束时间，nil 表示进行中
+	WebhookURL  string         `json:"-"`                   // 事件回调地址，不对外公开
+	AccessMode  string         `json:"access_mode"`         // public 或 allowlist
+	HideResults bool           `json:"hide_results"`        // 结束前不公开结果
+
+	CloseAfterFirstVote int        `json:"close_after_first_vote_seconds,omitempty"` // 首票后多少秒自动结束，0 表示不限
+	FirstVoteAt         *time.Time `json:"first_vote_at,omitempty"`
+
+	Slug           string `json:"slug,omitempty"`            // 自定义短链接，可代替 ID 访问
+	JoinCode       string `json:"join_code,omitempty"`       // 便于口头告知的投票代码，可代替 ID 访问
+	Round          int    `json:"round"`                     // 当前轮次，从 1 开始，开始新一轮时加一
+	ClosingMessage string `json:"closing_message,omitempty"` // 结束语，投票结束后才公开
+
+	OptionColors   map[string]string `json:"option_colors,omitempty"`   // option -> #rrggbb，图表统一配色
+	OptionCapacity map[string]int    `json:"option_capacity,omitempty"` // option -> 名额上限，未设置表示不限
+	HiddenOptions  []string          `json:"hidden_options,omitempty"`  // 照常计票但不在公开结果中显示的选项（如对照选项），管理员可见
+	hiddenWithheld bool              // 本次响应是否已去掉隐藏选项的票数
+
+	HasVoted   *bool  `json:"has_voted,omitempty"` // 当前访问者是否已投票，未知时为 nil
+	PageToken  string `json:"-"`                   // 投票页令牌，开启最短停留时间时随投票提交
+	HumanCheck string `json:"-"`                   // 开启 -vote-honeypot 时投票页脚本据此计算 X-Vote-Check 头
+
+	Anonymous bool `json:"anonymous"` // 为 false 时记录投票人身份，管理员可查看谁投了票（不含选择）
+
+	AllowAbstain  bool `json:"allow_abstain"`   // 允许不选任何选项提交（弃权），计入投票人数
+	AllowWriteIns bool `json:"allow_write_ins"` // 允许投票人填写选项以外的答案，需管理员审核
+	IPLimit       bool `json:"ip_limit"`        // 每个 IP 只能投一票（比名单令牌弱，同一出口 IP 的用户会互相影响）
+
+	RedirectURL string `json:"redirect_url,omitempty"` // 投票成功后跳转的地址，只允许 http(s)
+	OptionOrder string `json:"option_order"`           // 投票页选项顺序：fixed 或 votes
+
+	HideVoterCount     bool               `json:"hide_voter_count"`      // 不公开投票人数，只公开百分比
+	Percentages        map[string]float64 `json:"percentages,omitempty"` // 隐藏投票人数时代替其公开的各选项百分比
+	voterCountWithheld bool               // 本次响应是否隐去投票人数
+
+	Visualization string `json:"visualization"` // 建议的结果展示方式：list、pie 或 bar，仅供参考
+	ConfirmVote   bool   `json:"confirm_vote"`  // 投票需两步提交：prepare 取得确认令牌，confirm 后才计票
+
+	CreatorID string `json:"creator_id,omitempty"` // 创建者标识，由接入方提供（如其用户 ID），可由投票的管理者转移
+
+	ResultsVisibleAt *time.Time `json:"results_visible_at,omitempty"` // 在此时间之前不公开结果，与是否结束无关
+
+	CreatorIP string `json:"-"` // 创建者 IP 的哈希，用于限制每人的投票数量
+
+	ExpectedVoters    int      `json:"expected_voters,omitempty"`    // 应到人数，0 表示未设置
+	ParticipationRate *float64 `json:"participation_rate,omitempty"` // 投票人数 / 应到人数（百分比），可能超过 100
+
+	GroupLimits map[string]ChoiceLimits `json:"group_limits,omitempty"` // 投票人分组 -> 该分组的选择数量限制
+
+	Kind string `json:"kind"` // poll 或 schedule（约时间，选项为时间段）
+
+	NotifyEmail string `json:"-"` // 投票结束时接收结果摘要的邮箱，不对外公开
+
+	PercentMode string `json:"percent_mode"` // 百分比的分母：of_voters（投票人数）或 of_selections（选择总数）
+
+	ResultVisibility string `json:"result_visibility"` // 谁能看到结果：public、voters（已投票的人）或 owner（创建者和管理员）
+
+	ManageToken     string `json:"-"` // 投票管理令牌，只在创建时有值，由创建接口返回一次
+	manageTokenHash string // 管理令牌的哈希，见 isPollOwner
+}
+
+// IsClosed 投票是否已结束
+func (p *Poll) IsClosed() bool {
+	if p.ClosedAt != nil {
+		return true
+	}
+	if p.CloseAfterFirstVote > 0 && p.FirstVoteAt != nil {
+		return time.Now().After(p.FirstVoteAt.Add(time.Duration(p.CloseAfterFirstVote) * time.Second))
+	}
+	return false
+}
+
+// PollSettings 创建投票时的可选设置
+type PollSettings struct {
+	WebhookURL          string
+	AccessMode          string
+	HideResults         bool
+	CloseAfterFirstVote int
+	Slug                string
+	ClosingMessage      string
+	OptionColors        map[string]string
+	OptionCapacity      map[string]int
+	HiddenOptions       []string
+
+	// 迁移已有统计时的初始票数和投票人数
+	InitialVotes      map[string]int
+	InitialVoterCount int
+
+	Anonymous      *bool // nil 表示匿名（默认）
+	AllowAbstain   bool
+	AllowWriteIns  bool
+	IPLimit        bool
+	RedirectURL    string
+	OptionOrder    string
+	HideVoterCount bool
+	ConfirmVote    bool
+	CreatorID      string
+
+	ResultsVisibleAt *time.Time
+
+	// 创建者 IP 的哈希；MaxPolls 大于 0 时同一创建者（creator_id 或 IP）未删除的投票不能超过该数量
+	CreatorIP string
+	MaxPolls  int
+
+	ExpectedVoters int
+	GroupLimits    map[string]ChoiceLimits
+	Kind           string
+	NotifyEmail    string
+	PercentMode    string
+
+	ResultVisibility string
+
+	// 投票管理令牌，为空时自动生成；问卷的各问题共用一个
+	ManageToken string
+}
+
+// 投票访问模式
+const (
+	AccessPublic    = "public"
+	AccessAllowlist = "allowlist"
+)
+
+// 结果百分比的计算方式。多选投票按投票人数计算时各选项之和可能超过 100%，按选择总数计算时之和为 100%
+const (
+	PercentOfVoters     = "of_voters"
+	PercentOfSelections = "of_selections"
+)
+
+// 结果的可见范围，与结束前隐藏、定时公布同时生效。管理员和持投票管理令牌的创建者始终可以查看
+const (
+	ResultVisibilityPublic = "public"
+	ResultVisibilityVoters = "voters" // 只有已投过票的人
+	ResultVisibilityOwner  = "owner"  // 只有持投票管理令牌或管理令牌的请求
+)
+
+// 投票页的选项顺序。默认按创建顺序，避免排在前面的选项获得位置优势
+const (
+	OptionOrderFixed = "fixed"
+	OptionOrderVotes = "votes"
+)
+
+// CreatePollRequest 创建投票请求，字段变化时同步更新 openapi.json
+type CreatePollRequest struct {
+	Title       string   `json:"title"`
+	Options     []string `json:"options"`
+	OptionsText string   `json:"options_text,omitempty"` // 每行一个选项，可代替 options
+	MultiSelect bool     `json:"multi_select"`
+	MinChoices  *int     `json:"min_choices,omitempty"` // 未提供时多选投票使用 -default-min-choices
+	MaxChoices  *int     `json:"max_choices,omitempty"` // 未提供时多选投票使用 -default-max-choices
+	WebhookURL  string   `json:"webhook_url"`
+	AccessMode  string   `json:"access_mode"`
+	HideResults bool     `json:"hide_results"`
+
+	CloseAfterFirstVote int                     `json:"close_after_first_vote_seconds"`
+	Slug                string                  `json:"slug"`
+	ClosingMessage      string                  `json:"closing_message"`
+	OptionColors        map[string]string       `json:"option_colors"`
+	OptionCapacity      map[string]int          `json:"option_capacity"`          // 选项 -> 名额上限
+	HiddenOptions       []string                `json:"hidden_options,omitempty"` // 计票但不在公开结果中显示的选项
+	InitialVotes        map[string]int          `json:"initial_votes"`
+	InitialVoterCount   int                     `json:"initial_voter_count"`
+	Anonymous           *bool                   `json:"anonymous,omitempty"` // 默认 true
+	AllowAbstain        bool                    `json:"allow_abstain"`
+	AllowWriteIns       bool                    `json:"allow_write_ins"`
+	IPLimit             bool                    `json:"ip_limit"`
+	RedirectURL         string                  `json:"redirect_url"`
+	OptionOrder         string                  `json:"option_order"` // fixed（默认）或 votes
+	HideVoterCount      bool                    `json:"hide_voter_count"`
+	ConfirmVote         bool                    `json:"confirm_vote"`
+	CreatorID           string                  `json:"creator_id"`
+	ResultsVisibleAt    *time.Time              `json:"results_visible_at,omitempty"` // 定时公布结果
+	ExpectedVoters      int                     `json:"expected_voters"`              // 应到人数，用于计算参与率
+	GroupLimits         map[string]ChoiceLimits `json:"group_limits,omitempty"`       // 按邀请令牌中的分组覆盖选择数量限制
+	Kind                string                  `json:"kind,omitempty"`               // poll（默认）或 schedule
+	NotifyEmail         string                  `json:"notify_email,omitempty"`       // 投票结束时把结果摘要发到该邮箱，需配置 -smtp-addr
+	PercentMode         string                  `json:"percent_mode,omitempty"`       // of_voters（默认）或 of_selections
+	ResultVisibility    string                  `json:"result_visibility,omitempty"`  // public（默认）、voters 或 owner
+
+	// 由创建接口根据请求填写，不从请求体读取
+	creatorIP string
+	maxPolls  int
 }
 
-// VoteRequest 投票请求
+// VoteRequest 投票请求，字段变化时同步更新 openapi.json
 type VoteRequest struct {
 	PollID  string   `json:"poll_id"`
 	Options []string `json:"options"`
+	Token   string   `json:"token,omitempty"`    // 名单投票的投票人令牌
+	Name    string   `json:"name,omitempty"`     // 实名投票的投票人姓名
+	WriteIn string   `json:"write_in,omitempty"` // 自填答案，仅允许自填的投票
+
+	PageToken string `json:"page_token,omitempty"` // 打开投票页时签发的令牌
+	Website   string `json:"website,omitempty"`    // 投票页上隐藏的诱饵字段，正常用户不会填写
+}
+
+// Voter 投票人信息，用于访问控制
+type Voter struct {
+	Token string
+	ID    string // 浏览器的 voter_id Cookie，为空表示未知
+	Name  string // 实名投票时填写的姓名
+	IP    string // 客户端 IP，用于每 IP 一票的限制
+}
+
+// Identity 实名投票记录的投票人身份：优先使用姓名，其次使用名单令牌的哈希（令牌可用于投票，不保存原文）
+func (v Voter) Identity() string {
+	if name := strings.TrimSpace(v.Name); name != "" {
+		return name
+	}
+	if v.Token == "" {
+		return ""
+	}
+	return hashVoterKey(v.Token)
+}
+
+// 名单投票的访问错误，接口返回 403
+var (
+	errVoterNotAllowed = errors.New("voter not allowed")
+	errTokenUsed       = errors.New("token already used")
+)
+
+// errIdentityRequired 实名投票未提供投票人身份，接口返回 400
+var errIdentityRequired = errors.New("name is required for non-anonymous polls")
+
+// errIPAlreadyVoted 限制每 IP 一票的投票中，该 IP 已经投过票
+var errIPAlreadyVoted = errors.New("this IP address has already voted")
+
+// OptionsFullError 所选的部分选项名额已满，整张选票不会被记录
+type OptionsFullError struct {
+	Options []string
 }
 
+func (e *OptionsFullError) Error() string {
+	return "options are full: " + strings.Join(e.Options, ", ")
+}
+
+// errEmptyVote 未选择任何选项且投票不允许弃权
+var errEmptyVote = errors.New("at least one option must be selected")
+
+// errNoValidOption 所选选项都不是该投票的选项
+var errNoValidOption = errors.New("none of the selected options exist in this poll")
+
 // PollStore 投票存储
 type PollStore struct {
-	db *sql.DB
+	db *timedDB
+
+	// writeMu 投票和创建持读锁，可以并发；备份持写锁，期间暂停这些写入
+	writeMu sync.RWMutex
 }
 
 func NewPollStore(dbPath string) (*PollStore, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	dsn := dbPath
+	if dbPath != ":memory:" {
+		// 并发投票各自开启写事务：事务开始时就获取写锁（immediate），拿不到时等待而不是立即返回 SQLITE_BUSY
+		dsn += "?_pragma=busy_timeout(5000)&_txlock=immediate"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
+	if dbPath == ":memory:" {
+		// 内存数据库每个连接都是独立的库，只能使用同一个连接
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+	}
 
 	// 创建表
 	_, err = db.Exec(`
@@ -65,19 +328,275 @@ func NewPollStore(dbPath string) (*PollStore, error) {
 			PRIMARY KEY (poll_id, option_name),
 			FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
 		);
+
+		CREATE TABLE IF NOT EXISTS vote_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			poll_id TEXT NOT NULL,
+			options TEXT NOT NULL,
+			voted_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_vote_events_poll ON vote_events (poll_id, voted_at);
+
+		CREATE TABLE IF NOT EXISTS allowed_voters (
+			poll_id TEXT NOT NULL,
+			voter_hash TEXT NOT NULL,
+			PRIMARY KEY (poll_id, voter_hash)
+		);
+
+		CREATE TABLE IF NOT EXISTS poll_templates (
+			name TEXT PRIMARY KEY,
+			config TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS voters (
+			poll_id TEXT NOT NULL,
+			voter_hash TEXT NOT NULL,
+			voted_at DATETIME NOT NULL,
+			PRIMARY KEY (poll_id, voter_hash)
+		);
+
+		CREATE TABLE IF NOT EXISTS write_ins (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			poll_id TEXT NOT NULL,
+			text TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_write_ins_poll ON write_ins (poll_id);
+
+		CREATE TABLE IF NOT EXISTS surveys (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS survey_questions (
+			survey_id TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			poll_id TEXT NOT NULL,
+			PRIMARY KEY (survey_id, position)
+		);
+		CREATE INDEX IF NOT EXISTS idx_survey_questions_poll ON survey_questions (poll_id);
+
+		CREATE TABLE IF NOT EXISTS voter_ips (
+			poll_id TEXT NOT NULL,
+			ip_hash TEXT NOT NULL,
+			voted_at DATETIME NOT NULL,
+			PRIMARY KEY (poll_id, ip_hash)
+		);
+
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			poll_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_log_poll ON audit_log (poll_id, created_at);
+
+		CREATE TABLE IF NOT EXISTS result_snapshots (
+			id TEXT PRIMARY KEY,
+			poll_id TEXT NOT NULL,
+			data TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_result_snapshots_poll ON result_snapshots (poll_id);
+
+		CREATE TABLE IF NOT EXISTS poll_rounds (
+			poll_id TEXT NOT NULL,
+			round INTEGER NOT NULL,
+			votes TEXT NOT NULL,
+			write_ins TEXT NOT NULL,
+			voter_count INTEGER NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME NOT NULL,
+			PRIMARY KEY (poll_id, round)
+		);
 	`)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PollStore{db: db}, nil
+	if err = migrate(db); err != nil {
+		return nil, err
+	}
+
+	return &PollStore{db: &timedDB{DB: db}}, nil
+}
+
+// columnMigrations 旧数据库需要补充的列，新增字段只在这里追加
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"polls", "closed_at", "DATETIME"},
+	{"polls", "webhook_url", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "access_mode", "TEXT NOT NULL DEFAULT 'public'"},
+	{"allowed_voters", "used_at", "DATETIME"},
+	{"polls", "hide_results", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "close_after_first_vote", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "first_vote_at", "DATETIME"},
+	{"polls", "slug", "TEXT"},
+	{"polls", "closing_message", "TEXT NOT NULL DEFAULT ''"},
+	{"votes", "color", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "deleted_at", "DATETIME"},
+	{"polls", "initial_voter_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"votes", "initial_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "anonymous", "INTEGER NOT NULL DEFAULT 1"},
+	{"polls", "allow_abstain", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "allow_write_ins", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "ip_limit", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "redirect_url", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "option_order", "TEXT NOT NULL DEFAULT 'fixed'"},
+	{"polls", "views", "INTEGER NOT NULL DEFAULT 0"},
+	{"votes", "capacity", "INTEGER NOT NULL DEFAULT 0"},
+	{"vote_events", "voter", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "manage_token_hash", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "hide_voter_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "confirm_vote", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "creator_id", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "results_visible_at", "DATETIME"},
+	{"polls", "creator_ip", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "expected_voters", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "group_limits", "TEXT NOT NULL DEFAULT ''"},
+	{"survey_questions", "show_if_poll", "TEXT NOT NULL DEFAULT ''"},
+	{"survey_questions", "show_if_option", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "kind", "TEXT NOT NULL DEFAULT 'poll'"},
+	{"polls", "notify_email", "TEXT NOT NULL DEFAULT ''"},
+	{"polls", "percent_mode", "TEXT NOT NULL DEFAULT 'of_voters'"},
+	{"votes", "hidden", "INTEGER NOT NULL DEFAULT 0"},
+	{"polls", "join_code", "TEXT"},
+	{"polls", "round", "INTEGER NOT NULL DEFAULT 1"},
+	{"vote_events", "round", "INTEGER NOT NULL DEFAULT 1"},
+	{"polls", "result_visibility", "TEXT NOT NULL DEFAULT 'public'"},
+}
+
+// indexMigrations 依赖新增列的索引，在补充列之后创建
+var indexMigrations = []string{
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_polls_slug ON polls (slug)`,
+	`CREATE INDEX IF NOT EXISTS idx_polls_creator ON polls (creator_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_polls_creator_ip ON polls (creator_ip)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_polls_join_code ON polls (join_code)`,
+}
+
+// migrate 为缺少新列的表执行 ALTER TABLE
+func migrate(db *sql.DB) error {
+	existing := make(map[string]map[string]bool)
+	for _, m := range columnMigrations {
+		if existing[m.table] == nil {
+			cols, err := tableColumns(db, m.table)
+			if err != nil {
+				return err
+			}
+			existing[m.table] = cols
+		}
+		if existing[m.table][m.column] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return err
+		}
+		existing[m.table][m.column] = true
+	}
+
+	for _, stmt := range indexMigrations {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
 }
 
 func (ps *PollStore) Close() error {
 	return ps.db.Close()
 }
 
-func (ps *PollStore) Create(title string, options []string, multiSelect bool, minChoices, maxChoices int) (*Poll, error) {
+func (ps *PollStore) Create(title string, options []string, multiSelect bool, minChoices, maxChoices int, settings PollSettings) (*Poll, error) {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	poll, err := createPollTx(tx, title, options, multiSelect, minChoices, maxChoices, settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// createPollTx 在调用方的事务中创建投票，供需要同时创建多个投票的场景（问卷）使用
+func createPollTx(tx *timedTx, title string, options []string, multiSelect bool, minChoices, maxChoices int, settings PollSettings) (*Poll, error) {
+	if settings.AccessMode == "" {
+		settings.AccessMode = AccessPublic
+	}
+	if settings.AccessMode != AccessPublic && settings.AccessMode != AccessAllowlist {
+		return nil, fmt.Errorf("invalid access_mode")
+	}
+	if settings.OptionOrder == "" {
+		settings.OptionOrder = OptionOrderFixed
+	}
+	if settings.Kind == "" {
+		settings.Kind = KindPoll
+	}
+	if settings.PercentMode == "" {
+		settings.PercentMode = PercentOfVoters
+	}
+	if settings.ResultVisibility == "" {
+		settings.ResultVisibility = ResultVisibilityPublic
+	}
+	if settings.CloseAfterFirstVote < 0 {
+		return nil, fmt.Errorf("close_after_first_vote_seconds must not be negative")
+	}
+	if settings.Slug != "" {
+		if err := validateSlug(settings.Slug); err != nil {
+			return nil, err
+		}
+	}
+	colors, err := normalizeOptionColors(options, settings.OptionColors)
+	if err != nil {
+		return nil, err
+	}
+	groupLimits, err := encodeGroupLimits(settings.GroupLimits)
+	if err != nil {
+		return nil, err
+	}
+	initialVoters, err := checkInitialVotes(options, multiSelect, settings.InitialVotes, settings.InitialVoterCount)
+	if err != nil {
+		return nil, err
+	}
+
 	poll := &Poll{
 		ID:          uuid.New().String(),
 		Title:       title,
@@ -86,16 +605,70 @@ func (ps *PollStore) Create(title string, options []string, multiSelect bool, mi
 		MinChoices:  minChoices,
 		MaxChoices:  maxChoices,
 		Votes:       make(map[string]int),
-		VoterCount:  0,
+		VoterCount:  initialVoters,
 		CreatedAt:   time.Now(),
+		WebhookURL:  settings.WebhookURL,
+		AccessMode:  settings.AccessMode,
+		HideResults: settings.HideResults,
+
+		CloseAfterFirstVote: settings.CloseAfterFirstVote,
+		Slug:                settings.Slug,
+		ClosingMessage:      settings.ClosingMessage,
+		OptionColors:        colors,
+		OptionCapacity:      settings.OptionCapacity,
+		HiddenOptions:       settings.HiddenOptions,
+		Anonymous:           settings.Anonymous == nil || *settings.Anonymous,
+		AllowAbstain:        settings.AllowAbstain,
+		AllowWriteIns:       settings.AllowWriteIns,
+		IPLimit:             settings.IPLimit,
+		RedirectURL:         settings.RedirectURL,
+		OptionOrder:         settings.OptionOrder,
+		HideVoterCount:      settings.HideVoterCount,
+		ConfirmVote:         settings.ConfirmVote,
+		CreatorID:           settings.CreatorID,
+		ResultsVisibleAt:    settings.ResultsVisibleAt,
+		CreatorIP:           settings.CreatorIP,
+		ExpectedVoters:      settings.ExpectedVoters,
+		GroupLimits:         settings.GroupLimits,
+		Kind:                settings.Kind,
+		NotifyEmail:         settings.NotifyEmail,
+		PercentMode:         settings.PercentMode,
+		ResultVisibility:    settings.ResultVisibility,
+		Visualization:       chartHint(len(options), multiSelect),
+		ManageToken:         settings.ManageToken,
+	}
+	if poll.ManageToken == "" {
+		poll.ManageToken = newManageToken()
 	}
+	poll.manageTokenHash = hashManageToken(poll.ManageToken)
 
-	// 开始事务
-	tx, err := ps.db.Begin()
-	if err != nil {
-		return nil, err
+	if settings.MaxPolls > 0 {
+		if err := checkPollLimit(tx, poll.CreatorID, poll.CreatorIP, settings.MaxPolls); err != nil {
+			return nil, err
+		}
+	}
+
+	if poll.Slug != "" {
+		taken, err := slugTaken(tx, poll.Slug, poll.ID)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, errSlugTaken
+		}
+	}
+	var slug interface{}
+	if poll.Slug != "" {
+		slug = poll.Slug
+	}
+	var joinCode interface{}
+	if cfg.JoinCodeLength > 0 {
+		poll.JoinCode, err = uniqueJoinCode(tx, func() string { return newJoinCode(cfg.JoinCodeLength) })
+		if err != nil {
+			return nil, err
+		}
+		joinCode = poll.JoinCode
 	}
-	defer tx.Rollback()
 
 	// 插入投票
 	multiSelectInt := 0
@@ -103,43 +676,46 @@ func (ps *PollStore) Create(title string, options []string, multiSelect bool, mi
 		multiSelectInt = 1
 	}
 	_, err = tx.Exec(`
-		INSERT INTO polls (id, title, options, multi_select, min_choices, max_choices, voter_count, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, poll.ID, poll.Title, strings.Join(options, "|||"), multiSelectInt, minChoices, maxChoices, 0, poll.CreatedAt)
+		INSERT INTO polls (id, title, options, multi_select, min_choices, max_choices, voter_count, initial_voter_count, created_at, webhook_url, access_mode, hide_results, close_after_first_vote, slug, closing_message, anonymous, allow_abstain, allow_write_ins, ip_limit, redirect_url, option_order, hide_voter_count, confirm_vote, creator_id, results_visible_at, creator_ip, expected_voters, group_limits, kind, notify_email, percent_mode, join_code, result_visibility, manage_token_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, poll.ID, poll.Title, strings.Join(options, "|||"), multiSelectInt, minChoices, maxChoices, initialVoters, initialVoters, poll.CreatedAt, poll.WebhookURL, poll.AccessMode, poll.HideResults, poll.CloseAfterFirstVote, slug, poll.ClosingMessage, poll.Anonymous, poll.AllowAbstain, poll.AllowWriteIns, poll.IPLimit, poll.RedirectURL, poll.OptionOrder, poll.HideVoterCount, poll.ConfirmVote, poll.CreatorID, poll.ResultsVisibleAt, poll.CreatorIP, poll.ExpectedVoters, groupLimits, poll.Kind, poll.NotifyEmail, poll.PercentMode, joinCode, poll.ResultVisibility, poll.manageTokenHash)
 	if err != nil {
 		return nil, err
 	}
 
-	// 初始化投票选项
+	// 初始化投票选项，initial_count 记录迁移进来的票数，重新统计时作为基数
 	for _, opt := range options {
+		count := settings.InitialVotes[opt]
 		_, err = tx.Exec(`
-			INSERT INTO votes (poll_id, option_name, vote_count)
-			VALUES (?, ?, 0)
-		`, poll.ID, opt)
+			INSERT INTO votes (poll_id, option_name, vote_count, initial_count, color, capacity, hidden)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, poll.ID, opt, count, count, colors[opt], settings.OptionCapacity[opt], slices.Contains(settings.HiddenOptions, opt))
 		if err != nil {
 			return nil, err
 		}
-		poll.Votes[opt] = 0
-	}
-
-	if err = tx.Commit(); err != nil {
-		return nil, err
+		poll.Votes[opt] = count
 	}
 
 	return poll, nil
 }
 
-func (ps *PollStore) Get(id string) (*Poll, error) {
+// pollColumns 查询 polls 表时统一使用的列，顺序与 scanPoll 对应
+const pollColumns = `id, title, options, multi_select, min_choices, max_choices, voter_count, created_at, closed_at, webhook_url, access_mode, hide_results, close_after_first_vote, first_vote_at, slug, closing_message, anonymous, allow_abstain, allow_write_ins, ip_limit, redirect_url, option_order, views, hide_voter_count, confirm_vote, creator_id, results_visible_at, expected_voters, group_limits, kind, notify_email, percent_mode, join_code, round, result_visibility, manage_token_hash`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPoll(row rowScanner) (*Poll, error) {
 	var poll Poll
 	var optionsStr string
 	var multiSelectInt int
 	var createdAtStr string
+	var closedAt, firstVoteAt, resultsVisibleAt sql.NullTime
+	var slug, joinCode sql.NullString
+	var groupLimits string
 
-	err := ps.db.QueryRow(`
-		SELECT id, title, options, multi_select, min_choices, max_choices, voter_count, created_at
-		FROM polls
-		WHERE id = ?
-	`, id).Scan(&poll.ID, &poll.Title, &optionsStr, &multiSelectInt, &poll.MinChoices, &poll.MaxChoices, &poll.VoterCount, &createdAtStr)
+	err := row.Scan(&poll.ID, &poll.Title, &optionsStr, &multiSelectInt, &poll.MinChoices, &poll.MaxChoices, &poll.VoterCount, &createdAtStr, &closedAt, &poll.WebhookURL, &poll.AccessMode, &poll.HideResults, &poll.CloseAfterFirstVote, &firstVoteAt, &slug, &poll.ClosingMessage, &poll.Anonymous, &poll.AllowAbstain, &poll.AllowWriteIns, &poll.IPLimit, &poll.RedirectURL, &poll.OptionOrder, &poll.Views, &poll.HideVoterCount, &poll.ConfirmVote, &poll.CreatorID, &resultsVisibleAt, &poll.ExpectedVoters, &groupLimits, &poll.Kind, &poll.NotifyEmail, &poll.PercentMode, &joinCode, &poll.Round, &poll.ResultVisibility, &poll.manageTokenHash)
 	if err != nil {
 		return nil, err
 	}
@@ -147,225 +723,899 @@ func (ps *PollStore) Get(id string) (*Poll, error) {
 	poll.MultiSelect = multiSelectInt == 1
 	poll.Options = strings.Split(optionsStr, "|||")
 	poll.CreatedAt, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", createdAtStr)
+	if closedAt.Valid {
+		poll.ClosedAt = &closedAt.Time
+	}
+	if firstVoteAt.Valid {
+		poll.FirstVoteAt = &firstVoteAt.Time
+	}
+	if resultsVisibleAt.Valid {
+		poll.ResultsVisibleAt = &resultsVisibleAt.Time
+	}
+	poll.Slug = slug.String
+	poll.JoinCode = joinCode.String
+	if poll.GroupLimits, err = decodeGroupLimits(groupLimits); err != nil {
+		return nil, err
+	}
+	poll.Visualization = chartHint(len(poll.Options), poll.MultiSelect)
+	poll.ParticipationRate = participationRate(poll.VoterCount, poll.ExpectedVoters)
+	return &poll, nil
+}
 
-	// 获取投票数据
-	poll.Votes = make(map[string]int)
-	rows, err := ps.db.Query(`
-		SELECT option_name, vote_count
-		FROM votes
-		WHERE poll_id = ?
-	`, id)
+func (ps *PollStore) Get(id string) (*Poll, error) {
+	poll, err := scanPoll(ps.db.QueryRow(`SELECT `+pollColumns+` FROM polls WHERE id = ? AND deleted_at IS NULL`, id))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var optionName string
-		var voteCount int
-		if err := rows.Scan(&optionName, &voteCount); err != nil {
-			return nil, err
-		}
-		poll.Votes[optionName] = voteCount
+	if err := ps.loadVotes(poll); err != nil {
+		return nil, err
 	}
 
-	return &poll, nil
+	return poll, nil
 }
 
-func (ps *PollStore) GetAll() ([]*Poll, error) {
+// loadVotes 读取投票各选项的票数、颜色、名额和是否隐藏
+func (ps *PollStore) loadVotes(poll *Poll) error {
+	poll.Votes = make(map[string]int)
+	poll.HiddenOptions = nil
 	rows, err := ps.db.Query(`
-		SELECT id, title, options, multi_select, min_choices, max_choices, voter_count, created_at
-		FROM polls
-		ORDER BY created_at DESC
-	`)
+		SELECT option_name, vote_count, color, capacity, hidden
+		FROM votes
+		WHERE poll_id = ?
+	`, poll.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var polls []*Poll
 	for rows.Next() {
-		var poll Poll
-		var optionsStr string
-		var multiSelectInt int
-		var createdAtStr string
-
-		err := rows.Scan(&poll.ID, &poll.Title, &optionsStr, &multiSelectInt, &poll.MinChoices, &poll.MaxChoices, &poll.VoterCount, &createdAtStr)
-		if err != nil {
-			return nil, err
+		var optionName, color string
+		var voteCount, capacity int
+		var hidden bool
+		if err := rows.Scan(&optionName, &voteCount, &color, &capacity, &hidden); err != nil {
+			return err
 		}
+		poll.addOptionRow(optionName, voteCount, color, capacity, hidden)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	poll.orderHiddenOptions()
+	return nil
+}
 
-		poll.MultiSelect = multiSelectInt == 1
-		poll.Options = strings.Split(optionsStr, "|||")
-		poll.CreatedAt, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", createdAtStr)
-
-		// 获取投票数据
+// loadVotesBatch 用一次查询加载多个投票的票数，代替逐个调用 loadVotes
+func (ps *PollStore) loadVotesBatch(polls []*Poll) error {
+	if len(polls) == 0 {
+		return nil
+	}
+	byID := make(map[string]*Poll, len(polls))
+	args := make([]interface{}, 0, len(polls))
+	for _, poll := range polls {
 		poll.Votes = make(map[string]int)
-		voteRows, err := ps.db.Query(`
-			SELECT option_name, vote_count
-			FROM votes
-			WHERE poll_id = ?
-		`, poll.ID)
-		if err != nil {
-			return nil, err
-		}
-
-		for voteRows.Next() {
-			var optionName string
-			var voteCount int
-			if err := voteRows.Scan(&optionName, &voteCount); err != nil {
-				voteRows.Close()
-				return nil, err
-			}
-			poll.Votes[optionName] = voteCount
-		}
-		voteRows.Close()
-
-		polls = append(polls, &poll)
+		poll.HiddenOptions = nil
+		byID[poll.ID] = poll
+		args = append(args, poll.ID)
 	}
 
-	return polls, nil
-}
-
-func (ps *PollStore) Delete(id string) error {
-	result, err := ps.db.Exec(`DELETE FROM polls WHERE id = ?`, id)
+	rows, err := ps.db.Query(`
+		SELECT poll_id, option_name, vote_count, color, capacity, hidden
+		FROM votes
+		WHERE poll_id IN (?`+strings.Repeat(", ?", len(polls)-1)+`)
+	`, args...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	for rows.Next() {
+		var pollID, optionName, color string
+		var voteCount, capacity int
+		var hidden bool
+		if err := rows.Scan(&pollID, &optionName, &voteCount, &color, &capacity, &hidden); err != nil {
+			return err
+		}
+		if poll := byID[pollID]; poll != nil {
+			poll.addOptionRow(optionName, voteCount, color, capacity, hidden)
+		}
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("poll not found")
+	for _, poll := range polls {
+		poll.orderHiddenOptions()
 	}
-
 	return nil
 }
 
-func (ps *PollStore) AddVote(pollID string, options []string) error {
-	tx, err := ps.db.Begin()
-	if err != nil {
+// addOptionRow 把 votes 表的一行写入投票的票数、名额、配色和是否隐藏
+func (p *Poll) addOptionRow(optionName string, voteCount int, color string, capacity int, hidden bool) {
+	p.Votes[optionName] = voteCount
+	if hidden {
+		p.HiddenOptions = append(p.HiddenOptions, optionName)
+	}
+	if capacity > 0 {
+		if p.OptionCapacity == nil {
+			p.OptionCapacity = make(map[string]int)
+		}
+		p.OptionCapacity[optionName] = capacity
+	}
+	if color != "" {
+		if p.OptionColors == nil {
+			p.OptionColors = make(map[string]string)
+		}
+		p.OptionColors[optionName] = color
+	}
+}
+
+func (ps *PollStore) GetAll() ([]*Poll, error) {
+	rows, err := ps.db.Query(`SELECT ` + pollColumns + ` FROM polls WHERE deleted_at IS NULL ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var polls []*Poll
+	for rows.Next() {
+		poll, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// 先读完投票列表再查询票数，单连接（内存数据库）时不能嵌套查询
+	if err := ps.loadVotesBatch(polls); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
+
+// pollDataTables 删除投票时一并删除的关联数据表（未启用外键约束，需要手动删除）
+var pollDataTables = []string{"votes", "vote_events", "allowed_voters", "voters", "write_ins", "voter_ips", "poll_rounds"}
+
+func (ps *PollStore) Delete(id string) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// 检查投票是否存在
-	var exists int
-	err = tx.QueryRow(`SELECT COUNT(*) FROM polls WHERE id = ?`, pollID).Scan(&exists)
+	result, err := tx.Exec(`DELETE FROM polls WHERE id = ?`, id)
 	if err != nil {
 		return err
 	}
-	if exists == 0 {
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
 		return fmt.Errorf("poll not found")
 	}
 
-	// 增加投票人数
-	_, err = tx.Exec(`UPDATE polls SET voter_count = voter_count + 1 WHERE id = ?`, pollID)
+	for _, table := range pollDataTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE poll_id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeletePreview 删除投票时将被删除的数据
+type DeletePreview struct {
+	PollID     string         `json:"poll_id"`
+	Title      string         `json:"title"`
+	VoterCount int            `json:"voter_count"`
+	Votes      map[string]int `json:"votes"`
+	Rows       map[string]int `json:"rows"` // 各关联表将被删除的行数
+}
+
+// PreviewDelete 统计删除投票会删除哪些数据，不做任何修改
+func (ps *PollStore) PreviewDelete(id string) (*DeletePreview, error) {
+	var preview DeletePreview
+	err := ps.db.QueryRow(`SELECT id, title, voter_count FROM polls WHERE id = ?`, id).Scan(&preview.PollID, &preview.Title, &preview.VoterCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("poll not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &Poll{ID: id}
+	if err := ps.loadVotes(poll); err != nil {
+		return nil, err
+	}
+	preview.Votes = poll.Votes
+
+	preview.Rows = make(map[string]int, len(pollDataTables))
+	for _, table := range pollDataTables {
+		var n int
+		if err := ps.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE poll_id = ?`, id).Scan(&n); err != nil {
+			return nil, err
+		}
+		preview.Rows[table] = n
+	}
+	return &preview, nil
+}
+
+// ClosePoll 结束投票，已结束的投票不能再次结束；closingMessage 非空时覆盖结束语
+func (ps *PollStore) ClosePoll(id, closingMessage string) (*Poll, error) {
+	result, err := ps.db.Exec(`
+		UPDATE polls
+		SET closed_at = ?, closing_message = CASE WHEN ? != '' THEN ? ELSE closing_message END
+		WHERE id = ? AND closed_at IS NULL AND deleted_at IS NULL
+	`, time.Now(), closingMessage, closingMessage, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		poll, err := ps.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("poll not found")
+		}
+		if poll.IsClosed() {
+			return nil, fmt.Errorf("poll already closed")
+		}
+	}
+
+	return ps.Get(id)
+}
+
+// AddAllowedVoters 为名单投票添加允许投票的令牌（邮箱等），只保存哈希
+func (ps *PollStore) AddAllowedVoters(pollID string, tokens []string) error {
+	tx, err := ps.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// 增加每个选项的票数
-	for _, opt := range options {
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM polls WHERE id = ? AND deleted_at IS NULL`, pollID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("poll not found")
+	}
+
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
 		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO allowed_voters (poll_id, voter_hash)
+			VALUES (?, ?)
+		`, pollID, hashVoterKey(token))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AddVote 记录一张选票，返回实际计入的选项：不存在的选项被忽略
+func (ps *PollStore) AddVote(pollID string, options []string, writeIn string, voter Voter) ([]string, error) {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	applied, err := addVoteTx(tx, pollID, options, writeIn, voter, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// addVoteTx 在调用方的事务中记录一张选票，返回实际计入的选项，供需要同时投多个投票的场景（问卷）使用。
+// surveyID 为提交的问卷，单独投票时传空串；问卷中的问题只接受随所属问卷提交的选票
+func addVoteTx(tx *timedTx, pollID string, options []string, writeIn string, voter Voter, surveyID string) ([]string, error) {
+	// 检查投票是否存在且未结束
+	var closedAt, firstVoteAt sql.NullTime
+	var accessMode string
+	var closeAfter int
+	var anonymous, allowAbstain, allowWriteIns, multiSelect, ipLimit bool
+	var limits ChoiceLimits
+	var groupLimitsStr string
+	var round int
+	err := tx.QueryRow(`
+		SELECT closed_at, access_mode, close_after_first_vote, first_vote_at, anonymous, allow_abstain, allow_write_ins, multi_select, ip_limit, min_choices, max_choices, group_limits, round
+		FROM polls WHERE id = ? AND deleted_at IS NULL
+	`, pollID).Scan(&closedAt, &accessMode, &closeAfter, &firstVoteAt, &anonymous, &allowAbstain, &allowWriteIns, &multiSelect, &ipLimit, &limits.MinChoices, &limits.MaxChoices, &groupLimitsStr, &round)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("poll not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		return nil, fmt.Errorf("poll is closed")
+	}
+
+	// 单独投问卷中的问题会绕过问卷的整体提交
+	var ownerSurvey string
+	err = tx.QueryRow(`SELECT survey_id FROM survey_questions WHERE poll_id = ?`, pollID).Scan(&ownerSurvey)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if ownerSurvey != surveyID {
+		return nil, errSurveyQuestion
+	}
+
+	// 自填答案：单选投票只能在选项和自填之间二选一
+	writeIn = strings.TrimSpace(writeIn)
+	if writeIn != "" {
+		if !allowWriteIns {
+			return nil, errWriteInsDisabled
+		}
+		if utf8.RuneCountInString(writeIn) > maxWriteInLength {
+			return nil, errWriteInTooLong
+		}
+		if blocklist.Contains(writeIn) {
+			return nil, errWriteInBlocked
+		}
+		if !multiSelect && len(options) > 0 {
+			return nil, errWriteInWithOption
+		}
+	}
+
+	// 空选票只在允许弃权时计入投票人数
+	if len(options) == 0 && writeIn == "" && !allowAbstain {
+		return nil, errEmptyVote
+	}
+
+	// 多选投票的选择数量：邀请令牌带有分组且该分组有单独限制时按分组限制，否则按投票本身的限制
+	if multiSelect && len(options) > 0 {
+		groupLimits, err := decodeGroupLimits(groupLimitsStr)
+		if err != nil {
+			return nil, err
+		}
+		limits = choiceLimitsFor(limits, groupLimits, inviteGroup(pollID, voter.Token))
+		if err := limits.check(len(options)); err != nil {
+			return nil, err
+		}
+	}
+
+	// 实名投票必须能识别投票人，匿名投票不记录身份
+	identity := ""
+	if !anonymous {
+		identity = voter.Identity()
+		if identity == "" {
+			return nil, errIdentityRequired
+		}
+	}
+
+	// 限时投票：首票开始计时，超时后不再接受投票
+	now := time.Now()
+	if closeAfter > 0 {
+		if !firstVoteAt.Valid {
+			_, err = tx.Exec(`UPDATE polls SET first_vote_at = ? WHERE id = ?`, now, pollID)
+			if err != nil {
+				return nil, err
+			}
+		} else if now.After(firstVoteAt.Time.Add(time.Duration(closeAfter) * time.Second)) {
+			return nil, fmt.Errorf("poll is closed")
+		}
+	}
+
+	// 名单投票只接受名单内的令牌，每个令牌只能投一次
+	if accessMode == AccessAllowlist {
+		if voter.Token == "" {
+			return nil, errVoterNotAllowed
+		}
+		var usedAt sql.NullTime
+		err = tx.QueryRow(`
+			SELECT used_at FROM allowed_voters WHERE poll_id = ? AND voter_hash = ?
+		`, pollID, hashVoterKey(voter.Token)).Scan(&usedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errVoterNotAllowed
+		}
+		if err != nil {
+			return nil, err
+		}
+		if usedAt.Valid {
+			return nil, errTokenUsed
+		}
+		_, err = tx.Exec(`
+			UPDATE allowed_voters SET used_at = ? WHERE poll_id = ? AND voter_hash = ?
+		`, time.Now(), pollID, hashVoterKey(voter.Token))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 每 IP 一票：只保存 IP 的哈希，插入失败说明该 IP 已投过票
+	if ipLimit {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO voter_ips (poll_id, ip_hash, voted_at) VALUES (?, ?, ?)
+		`, pollID, hashVoterKey(voter.IP), time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 0 {
+			return nil, errIPAlreadyVoted
+		}
+	}
+
+	// 增加每个选项的票数。名额已满的选项不会被更新，条件写在同一条 UPDATE 中，并发投票也不会超出名额。
+	// 不存在的选项被忽略，只有实际计入的选项记入 applied
+	var full, applied []string
+	for _, opt := range options {
+		result, err := tx.Exec(`
 			UPDATE votes
 			SET vote_count = vote_count + 1
-			WHERE poll_id = ? AND option_name = ?
+			WHERE poll_id = ? AND option_name = ? AND (capacity = 0 OR vote_count < capacity)
 		`, pollID, opt)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			applied = append(applied, opt)
+		} else {
+			var capacity int
+			err := tx.QueryRow(`SELECT capacity FROM votes WHERE poll_id = ? AND option_name = ?`, pollID, opt).Scan(&capacity)
+			if err == nil && capacity > 0 {
+				full = append(full, opt)
+			} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
 		}
 	}
+	if len(full) > 0 {
+		return nil, &OptionsFullError{Options: full}
+	}
 
-	return tx.Commit()
+	// 确认计入的选项满足要求后才增加投票人数：选了选项却一个都不存在时整张选票不计入，
+	// 多选投票去掉不存在的选项后也必须满足最少选择数量。返回错误时调用方回滚事务，前面的更新一并撤销
+	if len(options) > 0 && len(applied) == 0 {
+		return nil, errNoValidOption
+	}
+	if multiSelect && len(applied) > 0 {
+		if err := limits.check(len(applied)); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`UPDATE polls SET voter_count = voter_count + 1 WHERE id = ?`, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录投票事件（只含计入的选项），用于时间线和异常检测
+	_, err = tx.Exec(`
+		INSERT INTO vote_events (poll_id, options, voted_at, voter, round)
+		VALUES (?, ?, ?, ?, ?)
+	`, pollID, strings.Join(applied, "|||"), time.Now().UTC(), identity, round)
+	if err != nil {
+		return nil, err
+	}
+
+	if writeIn != "" {
+		_, err = tx.Exec(`
+			INSERT INTO write_ins (poll_id, text, created_at) VALUES (?, ?, ?)
+		`, pollID, writeIn, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 记录投票人，用于判断访问者是否已投票
+	if voter.ID != "" {
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO voters (poll_id, voter_hash, voted_at)
+			VALUES (?, ?, ?)
+		`, pollID, hashVoterKey(voter.ID), time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return applied, nil
 }
 
 var store *PollStore
 var templates *template.Template
+var webhooks *WebhookDispatcher
+
+// templateFuncs 页面模板中可用的函数
+var templateFuncs = template.FuncMap{
+	"multiply": func(a, b interface{}) float64 {
+		var af, bf float64
+		switch v := a.(type) {
+		case int:
+			af = float64(v)
+		case float64:
+			af = v
+		}
+		switch v := b.(type) {
+		case int:
+			bf = float64(v)
+		case float64:
+			bf = v
+		}
+		return af * bf
+	},
+	"divide": func(a, b interface{}) float64 {
+		var af, bf float64
+		switch v := a.(type) {
+		case int:
+			af = float64(v)
+		case float64:
+			af = v
+		}
+		switch v := b.(type) {
+		case int:
+			bf = float64(v)
+		case float64:
+			bf = v
+		}
+		if bf == 0 {
+			return 0
+		}
+		return af / bf
+	},
+}
 
 func init() {
 	// 加载所有模板文件
-	funcMap := template.FuncMap{
-		"multiply": func(a, b interface{}) float64 {
-			var af, bf float64
-			switch v := a.(type) {
-			case int:
-				af = float64(v)
-			case float64:
-				af = v
-			}
-			switch v := b.(type) {
-			case int:
-				bf = float64(v)
-			case float64:
-				bf = v
-			}
-			return af * bf
-		},
-		"divide": func(a, b interface{}) float64 {
-			var af, bf float64
-			switch v := a.(type) {
-			case int:
-				af = float64(v)
-			case float64:
-				af = v
-			}
-			switch v := b.(type) {
-			case int:
-				bf = float64(v)
-			case float64:
-				bf = v
-			}
-			if bf == 0 {
-				return 0
-			}
-			return af / bf
-		},
+	templates = loadTemplates(template.New("").Funcs(templateFuncs))
+}
+
+// embeddedTemplates 编译进二进制的页面模板，磁盘上没有 templates 目录时使用
+//
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+// loadTemplates 优先从磁盘的 templates 目录加载（修改页面无需重新编译），
+// 目录不存在或为空时使用内置模板。解析失败时给出明确的错误并退出
+func loadTemplates(t *template.Template) *template.Template {
+	const pattern = "templates/*.html"
+	if files, _ := filepath.Glob(pattern); len(files) > 0 {
+		parsed, err := t.ParseFiles(files...)
+		if err != nil {
+			log.Fatalf("解析模板目录 templates 失败: %v", err)
+		}
+		return parsed
+	}
+
+	parsed, err := t.ParseFS(embeddedTemplates, pattern)
+	if err != nil {
+		log.Fatalf("解析内置模板失败: %v", err)
 	}
-	templates = template.Must(template.New("").Funcs(funcMap).ParseGlob("templates/*.html"))
+	return parsed
 }
 
 func main() {
-	var err error
-	store, err = NewPollStore("data/toupiao.db")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML 或 JSON 配置文件路径，键与命令行参数同名")
+	flag.StringVar(&cfg.BaseURL, "base-url", envOr("BASE_URL", "https://tp.starpix.cn"), "对外访问地址，用于二维码和分享链接")
+	flag.StringVar(&cfg.WebhookSecret, "webhook-secret", os.Getenv("WEBHOOK_SECRET"), "webhook 请求签名密钥")
+	flag.BoolVar(&cfg.WebhookAllowPrivate, "webhook-allow-private", false, "允许 webhook 投递到本机和内网地址（默认拒绝，防止借回调地址访问内部服务）")
+	flag.StringVar(&cfg.AdminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "管理接口令牌，为空时禁用管理接口")
+	flag.DurationVar(&cfg.AnomalyWindow, "anomaly-window", time.Minute, "异常检测的时间窗口")
+	flag.IntVar(&cfg.AnomalyMaxVotes, "anomaly-max-votes", 60, "时间窗口内超过该票数视为异常")
+	flag.StringVar(&cfg.SecretKey, "secret-key", os.Getenv("SECRET_KEY"), "签发令牌的密钥，为空时启动时随机生成")
+	flag.StringVar(&cfg.HashSalt, "hash-salt", os.Getenv("HASH_SALT"), "投票人标识、名单令牌和 IP 哈希时使用的密钥，至少 16 个字符；为空时使用 -hash-salt-file 中的密钥。设置后不要更改，否则无法识别更改前的重复投票")
+	flag.StringVar(&cfg.HashSaltFile, "hash-salt-file", "data/hash_salt", "未设置 -hash-salt 时保存哈希密钥的文件，首次启动时随机生成")
+	flag.IntVar(&cfg.PoWDifficulty, "pow-difficulty", 0, "投票前工作量证明的前导零比特数，0 表示关闭")
+	flag.DurationVar(&cfg.SlowQueryThreshold, "slow-query-threshold", 0, "耗时超过该值的数据库查询记录到日志（如 100ms），0 表示不记录")
+	flag.DurationVar(&cfg.RequestTimeout, "request-timeout", 10*time.Second, "单个请求的处理时限，超时返回 503，0 表示不限制（导出和备份接口不受限制）")
+	flag.BoolVar(&cfg.JSONP, "jsonp", false, "结果类接口（counts、ranks、rounds）支持 ?callback= 返回 JSONP，供不能使用 CORS 的旧式嵌入页面")
+	flag.IntVar(&cfg.GzipMinSize, "gzip-min-size", 1024, "响应体超过该字节数时启用 gzip 压缩")
+	flag.IntVar(&cfg.RenderConcurrency, "render-concurrency", 0, "同时渲染页面的最大数量，0 表示不限制")
+	flag.DurationVar(&cfg.RenderQueueTimeout, "render-queue-timeout", time.Second, "渲染名额已满时最多排队等待的时间，超时返回 503")
+	flag.StringVar(&cfg.PDFFont, "pdf-font", os.Getenv("PDF_FONT"), "PDF 导出使用的 TTF 字体路径，导出中文需指定支持中文的字体")
+	trustedProxies := flag.String("trusted-proxies", os.Getenv("TRUSTED_PROXIES"), "可信反向代理的 IP 或 CIDR，逗号分隔，如 127.0.0.1,10.0.0.0/8")
+	flag.BoolVar(&cfg.VoteHoneypot, "vote-honeypot", false, "投票必须带有投票页脚本计算的 X-Vote-Check 头且隐藏字段为空，阻挡不执行脚本的机器人（直接调用接口投票也会被拒绝）")
+	flag.DurationVar(&cfg.MinVoteDelay, "min-vote-delay", 0, "打开投票页后至少经过多久才能投票（如 2s），0 表示不限制")
+	flag.IntVar(&cfg.WriteInDistance, "write-in-distance", 2, "自填答案归并建议的最大编辑距离，0 表示只按大小写和空格归并")
+	flag.DurationVar(&cfg.ViewWindow, "view-window", 30*time.Minute, "同一访问者在该时间内重复打开投票页只计一次浏览")
+	flag.StringVar(&cfg.BackupDir, "backup-dir", os.Getenv("BACKUP_DIR"), "数据库备份目录，为空时禁用 /api/backup")
+	flag.IntVar(&cfg.DefaultMinChoices, "default-min-choices", 0, "多选投票未指定 min_choices 时的最少选择数量，0 表示不限制")
+	flag.IntVar(&cfg.DefaultMaxChoices, "default-max-choices", 0, "多选投票未指定 max_choices 时的最多选择数量，0 表示不限制（超过选项数时按选项数）")
+	flag.BoolVar(&cfg.StrictOptions, "strict-options", false, "选项重复检查忽略大小写和多余空白（如 \"Yes\" 和 \"yes\" 视为重复）")
+	flag.Float64Var(&cfg.DecisiveMargin, "decisive-margin", 10, "第一名领先第二名达到该百分比时结果中的 margin.decisive 为 true")
+	flag.IntVar(&cfg.JoinCodeLength, "join-code-length", 0, "为新投票生成该长度的投票代码（如 6），可代替投票 ID 访问，0 表示不生成")
+	flag.IntVar(&cfg.ChartMinOptions, "chart-min-options", 3, "选项少于该数量时建议只列出票数而不画图表")
+	flag.IntVar(&cfg.MaxPollsPerCreator, "max-polls-per-creator", 0, "同一创建者（creator_id 或 IP）最多保留的投票数，0 表示不限制，管理员不受限制")
+	flag.StringVar(&cfg.SPADir, "spa", os.Getenv("SPA_DIR"), "单页应用的构建目录，设置后首页和未知的非 API 路径返回其中的 index.html")
+	flag.StringVar(&cfg.BlocklistFile, "blocklist-file", os.Getenv("BLOCKLIST_FILE"), "屏蔽词文件（每行一个词），包含屏蔽词的标题、选项和自填答案会被拒绝")
+	flag.IntVar(&cfg.QRCacheSize, "qr-cache-size", 1024, "缓存的二维码数量上限，0 表示不缓存")
+	flag.DurationVar(&cfg.QRCacheTTL, "qr-cache-ttl", time.Hour, "二维码缓存的有效期")
+	flag.StringVar(&cfg.PprofAddr, "pprof", "", "在该地址（如 127.0.0.1:6060）单独监听 /debug/pprof/ 性能分析接口，为空时关闭")
+	flag.StringVar(&cfg.CSP, "csp", envOr("CSP", defaultCSP), "HTML 页面的 Content-Security-Policy，设为 - 时不发送")
+	flag.DurationVar(&cfg.HSTSMaxAge, "hsts-max-age", 365*24*time.Hour, "通过 HTTPS 访问时 Strict-Transport-Security 的 max-age，0 表示不发送")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", os.Getenv("TLS_CERT"), "HTTPS 证书文件（PEM），与 -tls-key 一起设置后改为提供 HTTPS")
+	flag.StringVar(&cfg.TLSKey, "tls-key", os.Getenv("TLS_KEY"), "HTTPS 私钥文件（PEM）")
+	autocertDomains := flag.String("autocert-domains", os.Getenv("AUTOCERT_DOMAINS"), "自动向 Let's Encrypt 申请证书的域名，逗号分隔，设置后改为提供 HTTPS")
+	flag.StringVar(&cfg.AutocertCacheDir, "autocert-cache", "data/autocert", "自动证书的缓存目录")
+	flag.StringVar(&cfg.AutocertEmail, "autocert-email", os.Getenv("AUTOCERT_EMAIL"), "Let's Encrypt 账号邮箱，用于证书到期提醒")
+	flag.StringVar(&cfg.TLSAddr, "tls-addr", ":443", "启用 HTTPS 时的监听地址")
+	flag.StringVar(&cfg.HTTPRedirectAddr, "http-redirect-addr", ":80", "启用 HTTPS 时把 HTTP 重定向到 HTTPS 的监听地址，为空时不监听（自动证书的 HTTP-01 验证也走这里）")
+	flag.StringVar(&cfg.SMTPAddr, "smtp-addr", os.Getenv("SMTP_ADDR"), "发送通知邮件的 SMTP 服务器（host:port），为空时不发邮件")
+	flag.StringVar(&cfg.SMTPFrom, "smtp-from", os.Getenv("SMTP_FROM"), "通知邮件的发件人地址")
+	flag.StringVar(&cfg.SMTPUser, "smtp-user", os.Getenv("SMTP_USER"), "SMTP 认证用户名，为空时不认证")
+	flag.StringVar(&cfg.SMTPPassword, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP 认证密码")
+	flag.BoolVar(&cfg.Memory, "memory", false, "使用内存数据库（演示/测试用，重启后数据丢失）")
+	flag.Parse()
+
+	if *configPath != "" {
+		if err := loadConfigFile(flag.CommandLine, *configPath); err != nil {
+			log.Fatal("读取配置文件失败: ", err)
+		}
+	}
+
+	proxies, err := parseCIDRs(*trustedProxies)
+	if err != nil {
+		log.Fatal("-trusted-proxies 配置错误: ", err)
+	}
+	cfg.TrustedProxies = proxies
+
+	for _, d := range strings.Split(*autocertDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			cfg.AutocertDomains = append(cfg.AutocertDomains, d)
+		}
+	}
+	if err := cfg.validateTLS(); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.BlocklistFile != "" {
+		if blocklist, err = loadBlocklistFile(cfg.BlocklistFile); err != nil {
+			log.Fatal("读取屏蔽词文件失败: ", err)
+		}
+	}
+
+	if cfg.DefaultMinChoices < 0 || cfg.DefaultMaxChoices < 0 ||
+		(cfg.DefaultMaxChoices > 0 && cfg.DefaultMinChoices > cfg.DefaultMaxChoices) {
+		log.Fatal("-default-min-choices 和 -default-max-choices 不能为负数，且最少数量不能超过最多数量")
+	}
+
+	if cfg.DecisiveMargin < 0 || cfg.DecisiveMargin > 100 {
+		log.Fatal("-decisive-margin 必须在 0-100 之间")
+	}
+	if cfg.JoinCodeLength != 0 && (cfg.JoinCodeLength < minJoinCodeLength || cfg.JoinCodeLength > maxJoinCodeLength) {
+		log.Fatalf("-join-code-length 必须为 0 或 %d-%d", minJoinCodeLength, maxJoinCodeLength)
+	}
+
+	if cfg.SMTPAddr != "" && !isEmailAddress(cfg.SMTPFrom) {
+		log.Fatal("设置 -smtp-addr 时 -smtp-from 必须是邮箱地址")
+	}
+
+	if cfg.CSP == "-" {
+		cfg.CSP = ""
+	}
+
+	if cfg.SPADir != "" {
+		if _, err := os.Stat(filepath.Join(cfg.SPADir, "index.html")); err != nil {
+			log.Fatal("-spa 目录中没有 index.html: ", err)
+		}
+	}
+
+	dbPath := "data/toupiao.db"
+	if cfg.Memory {
+		dbPath = ":memory:"
+	}
+	if err := cfg.setupHashSalt(); err != nil {
+		log.Fatal("准备哈希密钥失败: ", err)
+	}
+
+	if cfg.RenderConcurrency > 0 {
+		renderSlots = make(chan struct{}, cfg.RenderConcurrency)
+	}
+	qrCache = newQRCodeCache(cfg.QRCacheSize, cfg.QRCacheTTL)
+
+	store, err = NewPollStore(dbPath)
 	if err != nil {
 		log.Fatal("初始化数据库失败:", err)
 	}
 	defer store.Close()
 
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/create", createHandler)
-	http.HandleFunc("/api/polls", apiPollsHandler)
-	http.HandleFunc("/api/create-poll", apiCreatePollHandler)
-	http.HandleFunc("/api/delete-poll/", apiDeletePollHandler)
-	http.HandleFunc("/poll/", pollHandler)
-	http.HandleFunc("/api/vote", apiVoteHandler)
-	http.HandleFunc("/api/results/", apiResultsHandler)
-	http.HandleFunc("/qrcode/", qrcodeHandler)
+	webhooks = NewWebhookDispatcher(cfg.WebhookSecret, 256)
+	webhooks.allowPrivate = cfg.WebhookAllowPrivate
+	webhooks.Start()
+
+	if cfg.SMTPAddr != "" {
+		mailer = NewMailDispatcher(&smtpMailer{addr: cfg.SMTPAddr, from: cfg.SMTPFrom, username: cfg.SMTPUser, password: cfg.SMTPPassword}, 256)
+		mailer.Start()
+	}
+
+	if cfg.PprofAddr != "" {
+		go servePprof(cfg.PprofAddr)
+	}
+
+	handler := securityHeadersMiddleware(gzipMiddleware(timeoutMiddleware(routes(), cfg.RequestTimeout), cfg.GzipMinSize), cfg.CSP, cfg.HSTSMaxAge)
+	if cfg.tlsEnabled() {
+		log.Fatal(serveTLS(handler))
+	}
 
 	port := ":8888"
 	fmt.Printf("服务器启动在 http://localhost%s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, handler))
+}
+
+// routes 注册全部页面和接口路由，不含中间件
+func routes() *http.ServeMux {
+	// 不使用 http.DefaultServeMux：net/http/pprof 会在其上注册调试接口，不能暴露在对外端口
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", indexHandler)
+	mux.HandleFunc("/create", createHandler)
+	mux.HandleFunc("/api/polls", apiPollsHandler)
+	mux.HandleFunc("/api/create-poll", apiCreatePollHandler)
+	mux.HandleFunc("/api/create-polls", apiBulkCreatePollsHandler)
+	mux.HandleFunc("/api/create-from-template-link", apiCreateFromTemplateLinkHandler)
+	mux.HandleFunc("/api/delete-poll/", apiDeletePollHandler)
+	mux.HandleFunc("/api/close-poll/", apiClosePollHandler)
+	mux.HandleFunc("/poll/", pollHandler)
+	mux.HandleFunc("/present/{id}", presentHandler)
+	mux.HandleFunc("/api/vote", apiVoteHandler)
+	mux.HandleFunc("/api/vote/prepare", apiVotePrepareHandler)
+	mux.HandleFunc("/api/vote/confirm", apiVoteConfirmHandler)
+	mux.HandleFunc("/api/vote/validate", apiVoteValidateHandler)
+	mux.HandleFunc("/api/vote-challenge/", apiVoteChallengeHandler)
+	mux.HandleFunc("/api/results/", apiResultsHandler)
+	mux.HandleFunc("/api/results/batch", apiBatchResultsHandler)
+	mux.HandleFunc("/api/results/{id}/snapshot", apiCreateSnapshotHandler)
+	mux.HandleFunc("/api/snapshot/{sid}", apiSnapshotHandler)
+	mux.HandleFunc("/qrcode/", qrcodeHandler)
+	mux.HandleFunc("/api/admin/anomalies", apiAdminAnomaliesHandler)
+	mux.HandleFunc("/api/admin/stats", apiAdminStatsHandler)
+	mux.HandleFunc("/api/admin/verify", apiAdminVerifyHandler)
+	mux.HandleFunc("/api/recount/{id}", apiRecountHandler)
+	mux.HandleFunc("/api/poll-templates", apiPollTemplatesHandler)
+	mux.HandleFunc("/api/poll-from-template/{name}", apiPollFromTemplateHandler)
+	mux.HandleFunc("/api/poll/{id}/allowed-voters", apiAllowedVotersHandler)
+	mux.HandleFunc("/api/poll/{id}/invite", apiInviteHandler)
+	mux.HandleFunc("/api/openapi.json", apiOpenAPIHandler)
+	mux.HandleFunc("/api/poll/{id}/counts", jsonpHandler(apiCountsHandler))
+	mux.HandleFunc("/api/poll/{id}/slug", apiSlugHandler)
+	mux.HandleFunc("/api/poll/available", apiSlugAvailableHandler)
+	mux.HandleFunc("/api/options/suggest", apiOptionSuggestHandler)
+	mux.HandleFunc("/api/poll/{id}/merge", apiMergeHandler)
+	mux.HandleFunc("/api/poll/{id}/ranks", jsonpHandler(apiRanksHandler))
+	mux.HandleFunc("/api/poll/{id}/activity", apiActivityHandler)
+	mux.HandleFunc("/api/poll/{id}/hour-histogram", apiHourHistogramHandler)
+	mux.HandleFunc("/api/poll/{id}/availability", apiAvailabilityHandler)
+	mux.HandleFunc("/api/poll/{id}/vote-schema", apiVoteSchemaHandler)
+	mux.HandleFunc("/api/poll/{id}/raw", apiRawPollHandler)
+	mux.HandleFunc("/api/poll/{id}/new-round", apiNewRoundHandler)
+	mux.HandleFunc("/api/poll/{id}/rounds", jsonpHandler(apiRoundsHandler))
+	mux.HandleFunc("/api/poll/{id}/events.csv", apiEventLogHandler)
+	mux.HandleFunc("/api/poll/{id}/as-template", apiTemplateLinkHandler)
+	mux.HandleFunc("/api/poll/{id}/edit", apiEditPollHandler)
+	mux.HandleFunc("/api/poll/{id}/options/rename", apiRenameOptionHandler)
+	mux.HandleFunc("/api/poll/{id}/options/order", apiReorderOptionsHandler)
+	mux.HandleFunc("/api/poll/{id}/voters", apiVotersHandler)
+	mux.HandleFunc("/api/poll/{id}/transfer", apiTransferHandler)
+	mux.HandleFunc("/api/export", apiExportHandler)
+	mux.HandleFunc("/api/export/creator/{id}", apiExportCreatorHandler)
+	mux.HandleFunc("/api/poll/{id}/share.png", apiShareImageHandler)
+	mux.HandleFunc("/api/qrcodes.zip", apiQRCodesZipHandler)
+	mux.HandleFunc("/api/backup", apiBackupHandler)
+	mux.HandleFunc("/api/create-survey", apiCreateSurveyHandler)
+	mux.HandleFunc("/api/survey/{id}", apiSurveyHandler)
+	mux.HandleFunc("/api/survey-vote", apiSurveyVoteHandler)
+	mux.HandleFunc("/api/poll/{id}/write-ins", apiWriteInsHandler)
+	mux.HandleFunc("/api/poll/{id}/write-ins/merge", apiMergeWriteInsHandler)
+	mux.HandleFunc("/api/poll/{id}/write-ins/promote", apiPromoteWriteInHandler)
+	return mux
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.SPADir != "" {
+		spaHandler(w, r)
+		return
+	}
 	// 只有根路径才显示首页，其他路径返回404
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := templates.ExecuteTemplate(w, "index.html", nil); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	setCSRFCookie(w, r)
+	renderTemplate(w, "index.html", nil)
+}
+
+func apiPollsHandler(w http.ResponseWriter, r *http.Request) {
+	var polls []*Poll
+	var err error
+	if creator := r.URL.Query().Get("creator_id"); creator != "" {
+		polls, err = store.GetByCreator(creator)
+	} else {
+		polls, err = store.GetAll()
+	}
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 未结束的投票不在列表中公开隐藏的票数和结束语
+	if !isAdmin(r) {
+		for _, poll := range polls {
+			redactForPublic(poll)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"polls":   polls,
+	})
+}
+
+func apiDeletePollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pollID := r.URL.Path[len("/api/delete-poll/"):]
+	if pollID == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Poll ID is required",
+		})
+		return
+	}
+
+	poll, err := store.Get(pollID)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if !requireManage(w, r, poll) {
+		return
 	}
-}
 
-func apiPollsHandler(w http.ResponseWriter, r *http.Request) {
-	polls, err := store.GetAll()
-	if err != nil {
+	// ?dry_run=1 只返回将被删除的数据，不执行删除
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		preview, err := store.PreviewDelete(pollID)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"dry_run": true,
+			"delete":  preview,
+		})
+		return
+	}
+
+	if err := store.Delete(pollID); err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
@@ -376,17 +1626,17 @@ func apiPollsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"polls":   polls,
+		"message": "Poll deleted successfully",
 	})
 }
 
-func apiDeletePollHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+func apiClosePollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	pollID := r.URL.Path[len("/api/delete-poll/"):]
+	pollID := r.URL.Path[len("/api/close-poll/"):]
 	if pollID == "" {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -395,7 +1645,32 @@ func apiDeletePollHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := store.Delete(pollID); err != nil {
+	poll, err := store.Get(pollID)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if !requireManage(w, r, poll) {
+		return
+	}
+
+	// 请求体可选，可携带结束语
+	var req struct {
+		ClosingMessage string `json:"closing_message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err = store.ClosePoll(pollID, req.ClosingMessage)
+	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
@@ -403,18 +1678,22 @@ func apiDeletePollHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	notifyWebhook(poll, EventPollClosed, map[string]interface{}{
+		"votes":       poll.Votes,
+		"voter_count": poll.VoterCount,
+	})
+	notifyClosedByEmail(poll)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Poll deleted successfully",
+		"message": "Poll closed successfully",
 	})
 }
 
 func createHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := templates.ExecuteTemplate(w, "create.html", nil); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	setCSRFCookie(w, r)
+	renderTemplate(w, "create.html", nil)
 }
 
 func apiCreatePollHandler(w http.ResponseWriter, r *http.Request) {
@@ -423,50 +1702,181 @@ func apiCreatePollHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Title       string   `json:"title"`
-		Options     []string `json:"options"`
-		MultiSelect bool     `json:"multi_select"`
-		MinChoices  int      `json:"min_choices"`
-		MaxChoices  int      `json:"max_choices"`
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request",
-		})
+	var req CreatePollRequest
+
+	if !decodeJSON(w, r, &req) {
 		return
 	}
+	req.creatorIP = hashVoterKey(clientIP(r))
+	if !isAdmin(r) {
+		req.maxPolls = cfg.MaxPollsPerCreator
+	}
 
-	poll, err := store.Create(req.Title, req.Options, req.MultiSelect, req.MinChoices, req.MaxChoices)
+	poll, err := createPoll(&req)
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		writeCreateError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"success":      true,
+		"poll_id":      poll.ID,
+		"manage_token": poll.ManageToken,
+	}
+	if poll.JoinCode != "" {
+		resp["join_code"] = poll.JoinCode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// settings 创建请求中的可选设置
+func (req *CreatePollRequest) settings() PollSettings {
+	return PollSettings{
+		WebhookURL:  req.WebhookURL,
+		AccessMode:  req.AccessMode,
+		HideResults: req.HideResults,
+
+		CloseAfterFirstVote: req.CloseAfterFirstVote,
+		Slug:                req.Slug,
+		ClosingMessage:      req.ClosingMessage,
+		OptionColors:        req.OptionColors,
+		OptionCapacity:      req.OptionCapacity,
+		HiddenOptions:       req.HiddenOptions,
+		InitialVotes:        req.InitialVotes,
+		InitialVoterCount:   req.InitialVoterCount,
+		Anonymous:           req.Anonymous,
+		AllowAbstain:        req.AllowAbstain,
+		AllowWriteIns:       req.AllowWriteIns,
+		IPLimit:             req.IPLimit,
+		RedirectURL:         req.RedirectURL,
+		OptionOrder:         req.OptionOrder,
+		HideVoterCount:      req.HideVoterCount,
+		ConfirmVote:         req.ConfirmVote,
+		CreatorID:           req.CreatorID,
+		ResultsVisibleAt:    req.ResultsVisibleAt,
+		CreatorIP:           req.creatorIP,
+		MaxPolls:            req.maxPolls,
+		ExpectedVoters:      req.ExpectedVoters,
+		GroupLimits:         req.GroupLimits,
+		Kind:                req.Kind,
+		NotifyEmail:         req.NotifyEmail,
+		PercentMode:         req.PercentMode,
+		ResultVisibility:    req.ResultVisibility,
+	}
+}
+
+// choiceLimits 请求的选择数量限制。明确提供的值（包括 0）原样使用；多选投票未提供时使用配置的默认值，
+// 默认值超过选项数时按选项数，默认的最少数量也不超过明确提供的最多数量。单选投票未提供时为 0
+func (req *CreatePollRequest) choiceLimits() (minChoices, maxChoices int) {
+	if req.MaxChoices != nil {
+		maxChoices = *req.MaxChoices
+	} else if req.MultiSelect {
+		maxChoices = min(cfg.DefaultMaxChoices, len(req.Options))
+	}
+	if req.MinChoices != nil {
+		minChoices = *req.MinChoices
+	} else if req.MultiSelect {
+		minChoices = min(cfg.DefaultMinChoices, len(req.Options))
+		if maxChoices > 0 {
+			minChoices = min(minChoices, maxChoices)
+		}
+	}
+	return minChoices, maxChoices
+}
+
+// createPoll 校验请求并创建投票，成功后发送 poll.created 事件。校验失败时返回 ValidationErrors
+func createPoll(req *CreatePollRequest) (*Poll, error) {
+	req.applyOptionsText()
+	if errs := req.Validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	minChoices, maxChoices := req.choiceLimits()
+	poll, err := store.Create(req.Title, req.Options, req.MultiSelect, minChoices, maxChoices, req.settings())
+	if err != nil {
+		return nil, err
+	}
+
+	notifyWebhook(poll, EventPollCreated, map[string]interface{}{
+		"title":        poll.Title,
+		"options":      poll.Options,
+		"multi_select": poll.MultiSelect,
+	})
+	return poll, nil
+}
+
+// writeCreateError 输出创建投票失败的响应，字段校验错误返回 400 和 errors 列表
+func writeCreateError(w http.ResponseWriter, err error) {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   errs.Error(),
+			"errors":  errs,
+		})
+		return
+	}
+	if errors.Is(err, errSlugTaken) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, errTooManyPolls) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"poll_id": poll.ID,
+		"success": false,
+		"error":   err.Error(),
 	})
 }
 
 func pollHandler(w http.ResponseWriter, r *http.Request) {
 	pollID := r.URL.Path[len("/poll/"):]
-	poll, err := store.Get(pollID)
+	poll, err := store.Resolve(pollID)
 	if err != nil {
 		http.Error(w, "Poll not found", http.StatusNotFound)
 		return
 	}
+	if writeHeadOnly(w, r, "text/html; charset=utf-8") {
+		return
+	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := templates.ExecuteTemplate(w, "poll.html", poll); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	setCSRFCookie(w, r)
+	recordView(r, poll.ID, ensureVoterCookie(w, r))
+	poll.HasVoted = viewerHasVoted(r, poll.ID)
+
+	// ?option_order= 可覆盖投票的设置；结果隐藏时按票数排序会泄露结果，始终按创建顺序
+	order := poll.OptionOrder
+	if v := r.URL.Query().Get("option_order"); v == OptionOrderFixed || v == OptionOrderVotes {
+		order = v
+	}
+	if order == OptionOrderVotes && !poll.ResultsHidden() && !resultsRestricted(poll, r) {
+		poll.Options = optionsByVotes(poll.Options, poll.Votes)
 	}
+	if cfg.MinVoteDelay > 0 {
+		poll.PageToken = newPageToken(poll.ID)
+	}
+	if cfg.VoteHoneypot {
+		poll.HumanCheck = humanCheckSeed(poll.ID)
+	}
+	// 邀请链接带有分组时，页面按分组的选择数量限制提示和校验
+	limits := poll.ChoiceLimits(inviteGroup(poll.ID, r.URL.Query().Get("token")))
+	poll.MinChoices, poll.MaxChoices = limits.MinChoices, limits.MaxChoices
+	renderTemplate(w, "poll.html", poll)
 }
 
 func apiVoteHandler(w http.ResponseWriter, r *http.Request) {
@@ -475,56 +1885,470 @@ func apiVoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
 	var req VoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	challenge, ok := checkVoteGuards(w, r, &req)
+	if !ok {
+		return
+	}
+	if poll, err := store.Get(req.PollID); err == nil && poll.ConfirmVote {
+		usedChallenges.Release(challenge)
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"error":   "Invalid request",
+			"error":   errConfirmRequired.Error(),
 		})
 		return
 	}
 
-	if err := store.AddVote(req.PollID, req.Options); err != nil {
+	// 选票被拒绝或出错时释放题目，投票人不必重新求解
+	if !recordVote(w, r, &req) {
+		usedChallenges.Release(challenge)
+	}
+}
+
+// recordVote 计票并写入响应，成功时发送 vote.cast 事件。返回选票是否已计入
+func recordVote(w http.ResponseWriter, r *http.Request, req *VoteRequest) bool {
+	voter := Voter{Token: req.Token, ID: ensureVoterCookie(w, r), Name: req.Name, IP: clientIP(r)}
+	applied, err := store.AddVote(req.PollID, req.Options, req.WriteIn, voter)
+	if err != nil {
+		if errors.Is(err, errVoterNotAllowed) || errors.Is(err, errTokenUsed) || errors.Is(err, errIPAlreadyVoted) {
+			log.Printf("拒绝投票 %s（%s）: %v", req.PollID, clientIP(r), err)
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return false
+		}
+		var fullErr *OptionsFullError
+		if errors.As(err, &fullErr) {
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"success":      false,
+				"error":        err.Error(),
+				"full_options": fullErr.Options,
+			})
+			return false
+		}
+		if errors.Is(err, errIdentityRequired) || errors.Is(err, errEmptyVote) || errors.Is(err, errNoValidOption) || errors.Is(err, errChoiceCount) || errors.Is(err, errSurveyQuestion) || isWriteInError(err) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return false
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
 		})
-		return
+		return false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"success": true,
-	})
+	}
+	if poll, err := store.Get(req.PollID); err == nil {
+		notifyWebhook(poll, EventVoteCast, map[string]interface{}{
+			"options":     applied,
+			"voter_count": poll.VoterCount,
+		})
+		if poll.RedirectURL != "" {
+			resp["redirect_url"] = poll.RedirectURL
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
+
+// checkVoteGuards 计票前的防刷检查：工作量证明、机器人诱饵、投票页停留时间和邀请令牌。未通过时已写入错误响应，
+// 并释放已占用的题目。通过时返回占用的工作量证明题目（未开启时为空），选票最终未计入时由调用方释放
+func checkVoteGuards(w http.ResponseWriter, r *http.Request, req *VoteRequest) (challenge string, ok bool) {
+	defer func() {
+		if !ok {
+			usedChallenges.Release(challenge)
+		}
+	}()
+
+	if cfg.PoWDifficulty > 0 {
+		var err error
+		challenge, err = verifyPow(req.PollID, r.Header.Get("X-PoW"), cfg.PoWDifficulty)
+		if err != nil {
+			log.Printf("拒绝投票 %s（%s）: %v", req.PollID, clientIP(r), err)
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return challenge, false
+		}
+	}
+
+	if cfg.VoteHoneypot {
+		if err := verifyHoneypot(req.PollID, r.Header.Get("X-Vote-Check"), req.Website); err != nil {
+			log.Printf("拒绝投票 %s（%s）: 疑似机器人", req.PollID, clientIP(r))
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return challenge, false
+		}
+	}
+
+	// 打开投票页后至少停留 MinVoteDelay 才能投票，阻挡直接提交的机器人
+	if cfg.MinVoteDelay > 0 {
+		if err := verifyPageToken(req.PollID, req.PageToken, cfg.MinVoteDelay); err != nil {
+			log.Printf("拒绝投票 %s（%s）: %v", req.PollID, clientIP(r), err)
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return challenge, false
+		}
+	}
+
+	// 邀请链接令牌需校验签名和有效期
+	if strings.HasPrefix(req.Token, invitePrefix) {
+		if err := verifyInviteToken(req.PollID, req.Token); err != nil {
+			log.Printf("拒绝投票 %s（%s）: %v", req.PollID, clientIP(r), err)
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return challenge, false
+		}
+	}
+	return challenge, true
 }
 
 func apiResultsHandler(w http.ResponseWriter, r *http.Request) {
 	pollID := r.URL.Path[len("/api/results/"):]
-	poll, err := store.Get(pollID)
+	asPDF := strings.HasSuffix(pollID, ".pdf")
+	pollID = strings.TrimSuffix(pollID, ".pdf")
+	asXLSX := strings.HasSuffix(pollID, ".xlsx")
+	pollID = strings.TrimSuffix(pollID, ".xlsx")
+	poll, err := store.Resolve(pollID)
 	if err != nil {
 		http.Error(w, "Poll not found", http.StatusNotFound)
 		return
 	}
+	if asPDF {
+		writeResultsPDF(w, newResultsView(poll, r))
+		return
+	}
+	if asXLSX {
+		writeResultsXLSX(w, newResultsView(poll, r))
+		return
+	}
+	if writeHeadOnly(w, r, "text/html; charset=utf-8") {
+		return
+	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := templates.ExecuteTemplate(w, "results.html", poll); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	view := newResultsView(poll, r)
+	if poll.Round > 1 {
+		rounds, err := store.ListRounds(poll)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redactRounds(poll, rounds, r)
+		view.Rounds = rounds
+	}
+	renderTemplate(w, "results.html", view)
+}
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+func apiOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// optionsByVotes 按票数从高到低排列选项，票数相同时保持创建顺序。
+// 提交的仍是选项名，不需要映射回原来的位置
+func optionsByVotes(options []string, votes map[string]int) []string {
+	sorted := append([]string(nil), options...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return votes[sorted[i]] > votes[sorted[j]]
+	})
+	return sorted
+}
+
+// pollQRCode 生成投票页面地址的二维码 PNG，结果缓存在 qrCache 中
+func pollQRCode(pollID string) ([]byte, error) {
+	key := qrCacheKey{baseURL: cfg.BaseURL, pollID: pollID, size: 256, level: qrcode.Medium, format: "png"}
+	if data, ok := qrCache.get(key); ok {
+		return data, nil
+	}
+
+	pollURL := fmt.Sprintf("%s/poll/%s", cfg.BaseURL, pollID)
+	data, err := qrcode.Encode(pollURL, key.level, key.size)
+	if err != nil {
+		return nil, err
 	}
+	qrCache.put(key, data)
+	return data, nil
 }
 
 func qrcodeHandler(w http.ResponseWriter, r *http.Request) {
 	pollID := r.URL.Path[len("/qrcode/"):]
+	if exists, err := store.Exists(pollID); err != nil || !exists {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
 
-	// 生成投票页面 URL
-	pollURL := fmt.Sprintf("https://tp.starpix.cn/poll/%s", pollID)
-
-	// 生成二维码
-	qr, err := qrcode.Encode(pollURL, qrcode.Medium, 256)
+	qr, err := pollQRCode(pollID)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(qr)))
+	if r.Method == http.MethodHead {
+		return
+	}
 	w.Write(qr)
 }
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// normalizeOptionColors 校验选项颜色（#rgb 或 #rrggbb），统一转换为小写 #rrggbb
+func normalizeOptionColors(options []string, colors map[string]string) (map[string]string, error) {
+	if len(colors) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]bool, len(options))
+	for _, opt := range options {
+		known[opt] = true
+	}
+
+	result := make(map[string]string, len(colors))
+	for opt, color := range colors {
+		if !known[opt] {
+			return nil, fmt.Errorf("option_colors: unknown option %q", opt)
+		}
+		if !hexColorPattern.MatchString(color) {
+			return nil, fmt.Errorf("option_colors: invalid color %q for option %q", color, opt)
+		}
+		color = strings.ToLower(color)
+		if len(color) == 4 {
+			color = "#" + strings.Repeat(color[1:2], 2) + strings.Repeat(color[2:3], 2) + strings.Repeat(color[3:4], 2)
+		}
+		result[opt] = color
+	}
+	return result, nil
+}
+
+// checkInitialVotes 校验初始票数并返回初始投票人数。单选投票未指定人数时取票数之和，
+// 多选投票无法从票数推算人数，必须显式指定
+func checkInitialVotes(options []string, multiSelect bool, votes map[string]int, voterCount int) (int, error) {
+	if voterCount < 0 {
+		return 0, fmt.Errorf("initial_voter_count must not be negative")
+	}
+	if len(votes) == 0 {
+		return voterCount, nil
+	}
+
+	known := make(map[string]bool, len(options))
+	for _, opt := range options {
+		known[opt] = true
+	}
+
+	sum, max := 0, 0
+	for opt, count := range votes {
+		if !known[opt] {
+			return 0, fmt.Errorf("initial_votes: unknown option %q", opt)
+		}
+		if count < 0 {
+			return 0, fmt.Errorf("initial_votes: count for option %q must not be negative", opt)
+		}
+		sum += count
+		if count > max {
+			max = count
+		}
+	}
+
+	if voterCount == 0 {
+		if multiSelect && sum > 0 {
+			return 0, fmt.Errorf("initial_voter_count is required for multi-select polls")
+		}
+		return sum, nil
+	}
+	if !multiSelect && voterCount != sum {
+		return 0, fmt.Errorf("initial_voter_count must equal the sum of initial_votes for single-select polls")
+	}
+	if voterCount < max {
+		return 0, fmt.Errorf("initial_voter_count must not be less than any option count")
+	}
+	return voterCount, nil
+}
+
+// renderSlots 限制同时渲染的模板数，为 nil 时不限制
+var renderSlots chan struct{}
+
+// acquireRenderSlot 获取渲染名额，最多排队 cfg.RenderQueueTimeout，超时返回 false
+func acquireRenderSlot() bool {
+	if renderSlots == nil {
+		return true
+	}
+	select {
+	case renderSlots <- struct{}{}:
+		return true
+	default:
+	}
+	if cfg.RenderQueueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(cfg.RenderQueueTimeout)
+	defer timer.Stop()
+	select {
+	case renderSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func releaseRenderSlot() {
+	if renderSlots != nil {
+		<-renderSlots
+	}
+}
+
+// renderTemplate 先渲染到缓冲区，成功后再写响应，避免模板出错时输出半截 HTML
+func renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	if !acquireRenderSlot() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy, please retry", http.StatusServiceUnavailable)
+		return
+	}
+	defer releaseRenderSlot()
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("渲染模板 %s 失败: %v", name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// requireJSON 要求请求体为 application/json，否则返回 415；表单提交无法借此跨站调用接口
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType == "application/json" {
+		return true
+	}
+	writeJSON(w, http.StatusUnsupportedMediaType, map[string]interface{}{
+		"success": false,
+		"error":   "Content-Type must be application/json",
+	})
+	return false
+}
+
+// decodeJSON 解析请求体到 v，失败时返回 400 和具体原因：请求体为空、JSON 语法错误（含出错位置）、
+// 字段类型不符或 JSON 之后还有多余内容。返回 false 表示已写入错误响应
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errTrailingJSON
+	}
+	if err == nil {
+		return true
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"success": false,
+		"error":   decodeErrorMessage(err),
+	})
+	return false
+}
+
+var errTrailingJSON = errors.New("unexpected data after JSON value")
+
+// decodeErrorMessage 把 encoding/json 的错误转换为面向客户端的说明
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "malformed JSON: unexpected end of request body"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed JSON at byte %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("request body must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Sprintf("field %q must be %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value)
+	case errors.Is(err, errTrailingJSON):
+		return "malformed JSON: " + err.Error()
+	default:
+		return "invalid request body: " + err.Error()
+	}
+}
+
+// jsonTypeName Go 类型对应的 JSON 类型名，用于错误信息
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+// writeHeadOnly 处理 HEAD 请求：只写响应头，不渲染页面。返回 true 表示已处理
+func writeHeadOnly(w http.ResponseWriter, r *http.Request, contentType string) bool {
+	if r.Method != http.MethodHead {
+		return false
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// writeJSON 以指定状态码输出 JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// isHTTPURL 检查是否为 http/https 绝对地址
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
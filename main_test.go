@@ -0,0 +1,981 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAdminToken 测试中管理接口使用的令牌
+const testAdminToken = "test-admin-token"
+
+// adminHeader 携带管理令牌的请求头，传给 doRequest
+var adminHeader = []string{"X-Admin-Token", testAdminToken}
+
+// setupTest 使用临时目录中的 SQLite 数据库和与命令行默认值相同的配置，测试结束后关闭数据库并恢复全局状态
+func setupTest(t *testing.T) {
+	t.Helper()
+	prevCfg, prevStore := cfg, store
+	cfg = Config{
+		BaseURL:         "http://vote.test",
+		AdminToken:      testAdminToken,
+		HashSalt:        "test-hash-salt-0123456789",
+		AnomalyWindow:   time.Minute,
+		AnomalyMaxVotes: 60,
+		GzipMinSize:     1024,
+		ViewWindow:      30 * time.Minute,
+		ChartMinOptions: 3,
+	}
+	s, err := NewPollStore(filepath.Join(t.TempDir(), "toupiao.db"))
+	if err != nil {
+		t.Fatalf("NewPollStore: %v", err)
+	}
+	store = s
+	t.Cleanup(func() {
+		pendingViews.Wait()
+		s.Close()
+		cfg, store = prevCfg, prevStore
+	})
+}
+
+// doRequest 经完整路由发送请求。body 为 string 时原样发送，其他非 nil 值编码为 JSON；
+// headers 为请求头的名称和值交替排列
+func doRequest(t *testing.T, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("编码请求体: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	routes().ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeBody 把 JSON 响应解码为 map
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("响应不是 JSON（%d）: %v\n%s", rec.Code, err, rec.Body.String())
+	}
+	return body
+}
+
+// createTestPoll 通过创建接口创建投票，返回投票 ID 和管理令牌。req 为创建请求体
+func createTestPoll(t *testing.T, req map[string]interface{}, headers ...string) (string, string) {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", req, headers...)
+	body := decodeBody(t, rec)
+	if body["success"] != true {
+		t.Fatalf("创建投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	return body["poll_id"].(string), body["manage_token"].(string)
+}
+
+// castVote 以新访问者的身份投票
+func castVote(t *testing.T, pollID string, options ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+		"poll_id": pollID,
+		"options": options,
+	})
+}
+
+// mustVote 投票并要求成功
+func mustVote(t *testing.T, pollID string, options ...string) {
+	t.Helper()
+	rec := castVote(t, pollID, options...)
+	if rec.Code != http.StatusOK || decodeBody(t, rec)["success"] != true {
+		t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// mustGet 读取投票
+func mustGet(t *testing.T, pollID string) *Poll {
+	t.Helper()
+	poll, err := store.Get(pollID)
+	if err != nil {
+		t.Fatalf("读取投票 %s: %v", pollID, err)
+	}
+	return poll
+}
+
+func TestCloseAndDeleteRequireManageToken(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{
+		"title":   "t",
+		"options": []string{"a", "b"},
+	})
+	otherID, otherToken := createTestPoll(t, map[string]interface{}{
+		"title":   "t2",
+		"options": []string{"a", "b"},
+	})
+
+	for _, path := range []string{"/api/close-poll/" + pollID, "/api/delete-poll/" + pollID} {
+		for _, headers := range [][]string{nil, {manageTokenHeader, otherToken}, {manageTokenHeader, "wrong"}} {
+			if rec := doRequest(t, http.MethodPost, path, nil, headers...); rec.Code != http.StatusForbidden {
+				t.Errorf("%s 带 %v 状态码 = %d，期望 403", path, headers, rec.Code)
+			}
+		}
+	}
+	if poll := mustGet(t, pollID); poll.IsClosed() {
+		t.Fatal("未授权的请求结束了投票")
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, manageTokenHeader, manageToken)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("带管理令牌结束投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if !mustGet(t, pollID).IsClosed() {
+		t.Error("投票未结束")
+	}
+	// 管理员可以管理任何投票
+	rec = doRequest(t, http.MethodPost, "/api/close-poll/"+otherID, nil, adminHeader...)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("管理员结束投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, http.MethodPost, "/api/delete-poll/"+otherID+"?manage_token="+otherToken, nil)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("用查询参数带管理令牌删除失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := store.Get(otherID); err == nil {
+		t.Error("投票未删除")
+	}
+
+	// 令牌只以哈希保存
+	var hash string
+	if err := store.db.QueryRow(`SELECT manage_token_hash FROM polls WHERE id = ?`, pollID).Scan(&hash); err != nil {
+		t.Fatal(err)
+	}
+	if hash == manageToken || hash != hashManageToken(manageToken) {
+		t.Errorf("manage_token_hash = %q", hash)
+	}
+}
+
+// voteWithToken 以名单令牌投票
+func voteWithToken(t *testing.T, pollID, token string, options ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+		"poll_id": pollID,
+		"options": options,
+		"token":   token,
+	})
+}
+
+func TestAllowlistVoting(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":       "t",
+		"options":     []string{"a", "b"},
+		"access_mode": AccessAllowlist,
+	})
+	rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/allowed-voters", map[string]interface{}{
+		"voters": []string{"alice@example.com", "bob-token"},
+	}, adminHeader...)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("添加名单失败: %s", rec.Body.String())
+	}
+
+	if rec := voteWithToken(t, pollID, "alice@example.com", "a"); rec.Code != http.StatusOK {
+		t.Fatalf("名单内的令牌投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"不在名单中", "mallory@example.com"},
+		{"没有令牌", ""},
+		{"令牌已使用", "alice@example.com"},
+	} {
+		if rec := voteWithToken(t, pollID, tc.token, "a"); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: 状态码 = %d，期望 403", tc.name, rec.Code)
+		}
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
+
+// failingData 在模板执行到一半时报错
+type failingData struct{}
+
+func (failingData) Fail() (string, error) { return "", errors.New("boom") }
+
+func TestRenderTemplateFailureIsClean500(t *testing.T) {
+	setupTest(t)
+	prev := templates
+	t.Cleanup(func() { templates = prev })
+	// 已执行过的模板集不能 Clone，单独解析一个只含出错模板的集合
+	templates = template.Must(template.New("broken.html").Parse(`<html><body><p>partial</p>{{.Fail}}</body></html>`))
+
+	for _, name := range []string{"broken.html", "missing.html"} {
+		rec := httptest.NewRecorder()
+		renderTemplate(rec, name, failingData{})
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: 状态码 = %d，期望 500", name, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "<") {
+			t.Errorf("%s: 响应含有半截 HTML: %q", name, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: Content-Type = %q，不应为 HTML", name, ct)
+		}
+	}
+}
+
+// jsonFieldNames 结构体各字段的 JSON 名称
+func jsonFieldNames(v interface{}) []string {
+	var names []string
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodGet, "/api/openapi.json", nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var spec struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("openapi.json 不是合法的 JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q，期望 3.x", spec.OpenAPI)
+	}
+	if _, ok := spec.Paths["/api/vote"]; !ok {
+		t.Error("缺少 /api/vote")
+	}
+
+	// 请求结构体的字段都要写进文档；诱饵字段 website 有意不公开
+	for schema, v := range map[string]interface{}{"VoteRequest": VoteRequest{}, "CreatePollRequest": CreatePollRequest{}} {
+		props := spec.Components.Schemas[schema].Properties
+		for _, name := range jsonFieldNames(v) {
+			if _, ok := props[name]; !ok && name != "website" {
+				t.Errorf("%s 缺少字段 %s", schema, name)
+			}
+		}
+	}
+}
+
+func TestCloseAfterFirstVote(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":                          "quiz",
+		"options":                        []string{"a", "b"},
+		"close_after_first_vote_seconds": 60,
+	})
+	if poll := mustGet(t, pollID); poll.FirstVoteAt != nil || poll.IsClosed() {
+		t.Fatalf("首票前不应开始计时: first_vote_at = %v", poll.FirstVoteAt)
+	}
+
+	mustVote(t, pollID, "a")
+	poll := mustGet(t, pollID)
+	if poll.FirstVoteAt == nil || time.Since(*poll.FirstVoteAt) > time.Minute {
+		t.Fatalf("首票后 first_vote_at = %v", poll.FirstVoteAt)
+	}
+	mustVote(t, pollID, "b")
+
+	// 把计时起点拨到窗口之前，模拟超时
+	if _, err := store.db.Exec(`UPDATE polls SET first_vote_at = ? WHERE id = ?`, time.Now().Add(-61*time.Second), pollID); err != nil {
+		t.Fatal(err)
+	}
+	if !mustGet(t, pollID).IsClosed() {
+		t.Error("超时后投票应视为已结束")
+	}
+	rec := castVote(t, pollID, "a")
+	if body := decodeBody(t, rec); body["success"] == true || body["error"] != "poll is closed" {
+		t.Errorf("超时后的投票应被拒绝（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 2 {
+		t.Errorf("voter_count = %d，期望 2", got)
+	}
+}
+
+func TestOptionColors(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":         "t",
+		"options":       []string{"a", "b", "c"},
+		"option_colors": map[string]string{"a": "#FF0000", "b": "#0f0"},
+	})
+	want := map[string]string{"a": "#ff0000", "b": "#00ff00"}
+	if got := mustGet(t, pollID).OptionColors; !reflect.DeepEqual(got, want) {
+		t.Errorf("option_colors = %v，期望 %v", got, want)
+	}
+
+	var list struct {
+		Polls []struct {
+			OptionColors map[string]string `json:"option_colors"`
+		} `json:"polls"`
+	}
+	if err := json.Unmarshal(doRequest(t, http.MethodGet, "/api/polls", nil).Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Polls) != 1 || !reflect.DeepEqual(list.Polls[0].OptionColors, want) {
+		t.Errorf("列表中的 option_colors = %+v", list.Polls)
+	}
+	mustVote(t, pollID, "a")
+	if page := doRequest(t, http.MethodGet, "/api/results/"+pollID, nil).Body.String(); !strings.Contains(page, "background: #ff0000") {
+		t.Error("结果页没有使用选项颜色")
+	}
+
+	for _, colors := range []map[string]string{
+		{"a": "red"},
+		{"a": "#12345"},
+		{"a": "#ggg"},
+		{"a": "ff0000"},
+		{"x": "#ff0000"},
+	} {
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+			"title":         "t",
+			"options":       []string{"a", "b"},
+			"option_colors": colors,
+		})
+		body := decodeBody(t, rec)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"option_colors"`) {
+			t.Errorf("%v: 状态码 = %d，响应 %v，期望 400 和 option_colors 字段错误", colors, rec.Code, body)
+		}
+	}
+}
+
+func TestInMemoryStore(t *testing.T) {
+	setupTest(t)
+	s, err := NewPollStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewPollStore(:memory:): %v", err)
+	}
+	defer s.Close()
+
+	poll, err := s.Create("午饭", []string{"面", "饭", "粥"}, true, 1, 2, PollSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddVote(poll.ID, []string{"面", "饭"}, "", Voter{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddVote(poll.ID, []string{"面"}, "", Voter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 连接池只有一个连接，后续查询看到的仍是同一个内存库
+	got, err := s.Get(poll.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "午饭" || got.VoterCount != 2 || got.Votes["面"] != 2 || got.Votes["饭"] != 1 || got.Votes["粥"] != 0 {
+		t.Errorf("poll = %+v", got)
+	}
+	if stats := s.db.Stats(); stats.MaxOpenConnections != 1 {
+		t.Errorf("MaxOpenConnections = %d，期望 1", stats.MaxOpenConnections)
+	}
+}
+
+func TestHeadRequests(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	for path, contentType := range map[string]string{
+		"/poll/" + pollID:        "text/html; charset=utf-8",
+		"/api/results/" + pollID: "text/html; charset=utf-8",
+		"/qrcode/" + pollID:      "image/png",
+	} {
+		rec := doRequest(t, http.MethodHead, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("HEAD %s 状态码 = %d", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != contentType {
+			t.Errorf("HEAD %s Content-Type = %q，期望 %q", path, ct, contentType)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("HEAD %s 返回了 %d 字节的响应体", path, rec.Body.Len())
+		}
+	}
+
+	get := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil)
+	head := doRequest(t, http.MethodHead, "/qrcode/"+pollID, nil)
+	if cl := head.Header().Get("Content-Length"); cl != strconv.Itoa(get.Body.Len()) {
+		t.Errorf("HEAD 二维码 Content-Length = %q，GET 响应体 %d 字节", cl, get.Body.Len())
+	}
+	if views := mustGet(t, pollID).Views; views != 0 {
+		t.Errorf("HEAD 请求计入了浏览次数: %d", views)
+	}
+	if rec := doRequest(t, http.MethodHead, "/poll/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("HEAD 不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestQRCodeRequiresExistingPoll(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "slug": "qr-poll"})
+
+	for _, id := range []string{"no-such-poll", "00000000-0000-0000-0000-000000000000"} {
+		rec := doRequest(t, http.MethodGet, "/qrcode/"+id, nil)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: 状态码 = %d，期望 404", id, rec.Code)
+		}
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "image/") {
+			t.Errorf("%s: 不存在的投票返回了图片", id)
+		}
+	}
+	for _, id := range []string{pollID, "qr-poll"} {
+		rec := doRequest(t, http.MethodGet, "/qrcode/"+id, nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: 状态码 = %d，Content-Type = %q", id, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if !bytes.HasPrefix(rec.Body.Bytes(), []byte("\x89PNG\r\n\x1a\n")) {
+			t.Errorf("%s: 响应不是 PNG", id)
+		}
+	}
+
+	// 删除后不再生成二维码
+	if err := store.Delete(pollID); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("已删除的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestJSONEndpointsRequireContentType(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	poll := map[string]interface{}{"title": "t", "options": []string{"a", "b"}}
+
+	for _, tc := range []struct {
+		path string
+		body interface{}
+	}{
+		{"/api/create-poll", poll},
+		{"/api/create-polls", []interface{}{poll}},
+		{"/api/vote", map[string]interface{}{"poll_id": pollID, "options": []string{"a"}}},
+		{"/api/results/batch", map[string]interface{}{"poll_ids": []string{pollID}}},
+	} {
+		for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+			rec := doRequest(t, http.MethodPost, tc.path, tc.body, "Content-Type", contentType)
+			if rec.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("%s Content-Type %q: 状态码 = %d，期望 415", tc.path, contentType, rec.Code)
+			}
+		}
+		rec := doRequest(t, http.MethodPost, tc.path, tc.body, "Content-Type", "application/json; charset=utf-8")
+		if rec.Code != http.StatusOK || decodeBody(t, rec)["success"] != true {
+			t.Errorf("%s application/json: 状态码 = %d，响应 %s", tc.path, rec.Code, rec.Body.String())
+		}
+	}
+	// 被拒绝的投票不应计入
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
+
+func TestRenderSlotsShedOrQueue(t *testing.T) {
+	setupTest(t)
+	renderSlots = make(chan struct{}, 1)
+	t.Cleanup(func() { renderSlots = nil })
+
+	if rec := doRequest(t, http.MethodGet, "/create", nil); rec.Code != http.StatusOK {
+		t.Fatalf("名额空闲时状态码 = %d", rec.Code)
+	}
+	if len(renderSlots) != 0 {
+		t.Fatal("渲染结束后没有归还名额")
+	}
+
+	// 占满名额
+	renderSlots <- struct{}{}
+
+	cfg.RenderQueueTimeout = 0
+	rec := doRequest(t, http.MethodGet, "/create", nil)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("不排队时状态码 = %d，Retry-After = %q，期望立即 503", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	cfg.RenderQueueTimeout = 30 * time.Millisecond
+	start := time.Now()
+	rec = doRequest(t, http.MethodGet, "/create", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("排队超时状态码 = %d，期望 503", rec.Code)
+	}
+	if waited := time.Since(start); waited < cfg.RenderQueueTimeout {
+		t.Errorf("只排队了 %v，期望至少 %v", waited, cfg.RenderQueueTimeout)
+	}
+
+	// 排队期间名额释放则正常渲染
+	cfg.RenderQueueTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		<-renderSlots
+	}()
+	if rec := doRequest(t, http.MethodGet, "/create", nil); rec.Code != http.StatusOK {
+		t.Errorf("排队后状态码 = %d，期望 200", rec.Code)
+	}
+}
+
+func TestInitialVotes(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":         "迁移的投票",
+		"options":       []string{"a", "b", "c"},
+		"initial_votes": map[string]int{"a": 10, "b": 5},
+	})
+	poll := mustGet(t, pollID)
+	if poll.VoterCount != 15 || poll.Votes["a"] != 10 || poll.Votes["b"] != 5 || poll.Votes["c"] != 0 {
+		t.Fatalf("初始 voter_count = %d，votes = %v", poll.VoterCount, poll.Votes)
+	}
+
+	mustVote(t, pollID, "a")
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))
+	votes := body["votes"].(map[string]interface{})
+	if body["voter_count"] != float64(16) || votes["a"] != float64(11) || votes["b"] != float64(5) {
+		t.Errorf("counts = %v", body)
+	}
+	// 初始票数没有投票记录，重新计票时保留
+	recounted, err := store.Recount(pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recounted.VoterCount != 16 || recounted.Votes["a"] != 11 || recounted.Votes["b"] != 5 {
+		t.Errorf("重新计票后 voter_count = %d，votes = %v", recounted.VoterCount, recounted.Votes)
+	}
+
+	multiID, _ := createTestPoll(t, map[string]interface{}{
+		"title":               "多选",
+		"options":             []string{"a", "b"},
+		"multi_select":        true,
+		"initial_votes":       map[string]int{"a": 4, "b": 3},
+		"initial_voter_count": 5,
+	})
+	if poll := mustGet(t, multiID); poll.VoterCount != 5 || poll.Votes["a"] != 4 {
+		t.Errorf("多选初始 voter_count = %d，votes = %v", poll.VoterCount, poll.Votes)
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  map[string]interface{}
+	}{
+		{"未知选项", map[string]interface{}{"initial_votes": map[string]int{"x": 1}}},
+		{"负数", map[string]interface{}{"initial_votes": map[string]int{"a": -1}}},
+		{"单选人数与票数不符", map[string]interface{}{"initial_votes": map[string]int{"a": 2}, "initial_voter_count": 3}},
+		{"多选缺少人数", map[string]interface{}{"initial_votes": map[string]int{"a": 2}, "multi_select": true}},
+		{"人数少于单个选项票数", map[string]interface{}{"initial_votes": map[string]int{"a": 5}, "initial_voter_count": 3, "multi_select": true}},
+	} {
+		tc.req["title"] = "t"
+		tc.req["options"] = []string{"a", "b"}
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", tc.req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400: %s", tc.name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestEmptyVote(t *testing.T) {
+	setupTest(t)
+	strict, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	abstain, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "allow_abstain": true})
+
+	for _, options := range [][]string{nil, {}} {
+		rec := doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{"poll_id": strict, "options": options})
+		if body := decodeBody(t, rec); body["success"] == true || body["error"] != errEmptyVote.Error() {
+			t.Errorf("options = %v: 不允许弃权时应拒绝（%d）: %s", options, rec.Code, rec.Body.String())
+		}
+	}
+	if got := mustGet(t, strict).VoterCount; got != 0 {
+		t.Errorf("被拒绝的空投票计入了人数: %d", got)
+	}
+
+	mustVote(t, abstain)
+	poll := mustGet(t, abstain)
+	if poll.VoterCount != 1 || poll.Votes["a"] != 0 || poll.Votes["b"] != 0 {
+		t.Errorf("弃权后 voter_count = %d，votes = %v，期望 1 人、无票", poll.VoterCount, poll.Votes)
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "要删除的投票", "options": []string{"a", "b"}})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+	mustVote(t, pollID, "a")
+
+	rec := doRequest(t, http.MethodPost, "/api/delete-poll/"+pollID+"?dry_run=1", nil, manageTokenHeader, manageToken)
+	var resp struct {
+		Success bool          `json:"success"`
+		DryRun  bool          `json:"dry_run"`
+		Delete  DeletePreview `json:"delete"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	d := resp.Delete
+	if !resp.Success || !resp.DryRun || d.PollID != pollID || d.Title != "要删除的投票" || d.VoterCount != 3 {
+		t.Errorf("dry run = %+v", resp)
+	}
+	if !reflect.DeepEqual(d.Votes, map[string]int{"a": 2, "b": 1}) || d.Rows["vote_events"] != 3 {
+		t.Errorf("votes = %v，rows = %v", d.Votes, d.Rows)
+	}
+	if poll := mustGet(t, pollID); poll.VoterCount != 3 {
+		t.Errorf("dry run 修改了投票: %+v", poll)
+	}
+
+	rec = doRequest(t, http.MethodPost, "/api/delete-poll/"+pollID, nil, manageTokenHeader, manageToken)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("删除失败: %s", rec.Body.String())
+	}
+	if _, err := store.Get(pollID); err == nil {
+		t.Error("删除后仍能读取投票")
+	}
+}
+
+func TestIPLimit(t *testing.T) {
+	setupTest(t)
+	// 测试请求的直连地址为 192.0.2.1，作为可信代理以便用 X-Forwarded-For 模拟不同客户端
+	proxies, err := parseCIDRs("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TrustedProxies = proxies
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "ip_limit": true})
+
+	voteFrom := func(pollID, ip string) *httptest.ResponseRecorder {
+		return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{"poll_id": pollID, "options": []string{"a"}}, "X-Forwarded-For", ip)
+	}
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if rec := voteFrom(pollID, ip); rec.Code != http.StatusOK {
+			t.Fatalf("%s 投票失败（%d）: %s", ip, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := voteFrom(pollID, "203.0.113.1"); rec.Code != http.StatusForbidden {
+		t.Errorf("同一 IP 再次投票状态码 = %d，期望 403", rec.Code)
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 2 {
+		t.Errorf("voter_count = %d，期望 2", got)
+	}
+
+	// 直连地址不是可信代理时伪造 X-Forwarded-For 无法绕过限制
+	cfg.TrustedProxies = nil
+	spoofed, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "ip_limit": true})
+	if rec := voteFrom(spoofed, "203.0.113.3"); rec.Code != http.StatusOK {
+		t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if rec := voteFrom(spoofed, "203.0.113.4"); rec.Code != http.StatusForbidden {
+		t.Errorf("伪造 X-Forwarded-For 的状态码 = %d，期望 403", rec.Code)
+	}
+
+	// 不限制 IP 的投票不受影响
+	open, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, open, "a")
+	mustVote(t, open, "a")
+
+	// 只保存 IP 的哈希
+	var raw int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM voter_ips WHERE ip_hash LIKE '%203.0.113%'`).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw != 0 {
+		t.Error("voter_ips 中保存了明文 IP")
+	}
+}
+
+func TestOptionOrderByVotes(t *testing.T) {
+	setupTest(t)
+	// renderedOrder 返回投票页中各选项按出现位置排列的顺序
+	renderedOrder := func(path string, options ...string) []string {
+		t.Helper()
+		rec := doRequest(t, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s 状态码 = %d", path, rec.Code)
+		}
+		page := rec.Body.String()
+		pos := map[string]int{}
+		for _, o := range options {
+			i := strings.Index(page, `value="`+o+`"`)
+			if i < 0 {
+				t.Fatalf("页面中没有选项 %q", o)
+			}
+			pos[o] = i
+		}
+		sorted := append([]string(nil), options...)
+		sort.Slice(sorted, func(i, j int) bool { return pos[sorted[i]] < pos[sorted[j]] })
+		return sorted
+	}
+
+	byVotes, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"aa", "bb", "cc"}, "option_order": "votes"})
+	mustVote(t, byVotes, "cc")
+	mustVote(t, byVotes, "cc")
+	mustVote(t, byVotes, "bb")
+	if got := renderedOrder("/poll/"+byVotes, "aa", "bb", "cc"); !reflect.DeepEqual(got, []string{"cc", "bb", "aa"}) {
+		t.Errorf("按票数排序 = %v，期望票数最高的 cc 在前", got)
+	}
+	if got := renderedOrder("/poll/"+byVotes+"?option_order=fixed", "aa", "bb", "cc"); !reflect.DeepEqual(got, []string{"aa", "bb", "cc"}) {
+		t.Errorf("?option_order=fixed 顺序 = %v", got)
+	}
+
+	// 默认按创建顺序，可用查询参数改为按票数
+	fixed, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"aa", "bb", "cc"}})
+	mustVote(t, fixed, "cc")
+	if got := renderedOrder("/poll/"+fixed, "aa", "bb", "cc"); !reflect.DeepEqual(got, []string{"aa", "bb", "cc"}) {
+		t.Errorf("默认顺序 = %v，期望创建顺序", got)
+	}
+	if got := renderedOrder("/poll/"+fixed+"?option_order=votes", "aa", "bb", "cc"); !reflect.DeepEqual(got, []string{"cc", "aa", "bb"}) {
+		t.Errorf("?option_order=votes 顺序 = %v", got)
+	}
+
+	// 结果隐藏时按票数排序会泄露结果，保持创建顺序
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"aa", "bb", "cc"}, "option_order": "votes", "hide_results": true})
+	mustVote(t, hidden, "cc")
+	if got := renderedOrder("/poll/"+hidden, "aa", "bb", "cc"); !reflect.DeepEqual(got, []string{"aa", "bb", "cc"}) {
+		t.Errorf("结果隐藏时顺序 = %v，期望创建顺序", got)
+	}
+
+	// 按选项名提交，与展示顺序无关
+	mustVote(t, byVotes, "aa")
+	if got := mustGet(t, byVotes).Votes["aa"]; got != 1 {
+		t.Errorf("aa 票数 = %d，期望 1", got)
+	}
+}
+
+func TestOptionsByVotesKeepsCreationOrderOnTies(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+	got := optionsByVotes(options, map[string]int{"b": 1, "d": 1})
+	if want := []string{"b", "d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("optionsByVotes = %v，期望 %v", got, want)
+	}
+	if !reflect.DeepEqual(options, []string{"a", "b", "c", "d"}) {
+		t.Errorf("optionsByVotes 修改了传入的切片: %v", options)
+	}
+}
+
+func TestLoadTemplatesFallsBackToEmbedded(t *testing.T) {
+	// 工作目录下没有 templates 目录时使用内置模板，而不是启动失败
+	t.Chdir(t.TempDir())
+	parsed := loadTemplates(template.New("").Funcs(templateFuncs))
+	for _, name := range []string{"index.html", "poll.html"} {
+		if parsed.Lookup(name) == nil {
+			t.Errorf("内置模板中缺少 %s", name)
+		}
+	}
+
+	// 磁盘上有模板时优先使用磁盘上的
+	if err := os.Mkdir("templates", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("templates", "index.html"), []byte("disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parsed = loadTemplates(template.New("").Funcs(templateFuncs))
+	var out strings.Builder
+	if err := parsed.ExecuteTemplate(&out, "index.html", nil); err != nil || out.String() != "disk" {
+		t.Errorf("磁盘模板输出 = %q，err = %v", out.String(), err)
+	}
+	if parsed.Lookup("poll.html") != nil {
+		t.Error("使用磁盘模板时不应混入内置模板")
+	}
+}
+
+func TestOptionCapacityUnderConcurrency(t *testing.T) {
+	setupTest(t)
+	const capacity, voters = 5, 20
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "multi_select": true,
+		"option_capacity": map[string]int{"a": capacity},
+	})
+
+	codes := make(chan int, voters)
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- castVote(t, pollID, "a").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	accepted, full := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			accepted++
+		case http.StatusConflict:
+			full++
+		default:
+			t.Errorf("意外的状态码 %d", code)
+		}
+	}
+	poll := mustGet(t, pollID)
+	if accepted != capacity || full != voters-capacity || poll.Votes["a"] != capacity || poll.VoterCount != capacity {
+		t.Errorf("成功 %d、名额已满 %d，votes = %v，voter_count = %d，期望恰好 %d 票", accepted, full, poll.Votes, poll.VoterCount, capacity)
+	}
+
+	// 选票中有已满的选项时整张选票不计入，并返回已满的选项
+	rec := castVote(t, pollID, "a", "b")
+	body := decodeBody(t, rec)
+	if rec.Code != http.StatusConflict || !reflect.DeepEqual(body["full_options"], []interface{}{"a"}) {
+		t.Errorf("部分已满的选票（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if got := mustGet(t, pollID).Votes["b"]; got != 0 {
+		t.Errorf("被拒绝的选票计入了 b: %d", got)
+	}
+	mustVote(t, pollID, "b")
+}
+
+func TestDecodeErrorMessages(t *testing.T) {
+	setupTest(t)
+	for _, ep := range []struct {
+		path string
+		// 各接口字段类型不符的请求体和错误信息
+		typeBody, typeWant string
+	}{
+		{"/api/create-poll", `{"options": "a,b"}`, `field "options" must be an array, got string`},
+		{"/api/vote", `{"options": "a,b"}`, `field "options" must be an array, got string`},
+		{"/api/create-survey", `{"questions": "q1"}`, `field "questions" must be an array, got string`},
+		{"/api/survey-vote", `{"answers": ["a"]}`, `field "answers" must be an object, got array`},
+	} {
+		for _, tc := range []struct {
+			name, body, want string
+		}{
+			{"空请求体", "", "request body is empty"},
+			{"只有空白", "  \n", "request body is empty"},
+			{"语法错误", `{"title": "t",}`, "malformed JSON at byte 15: invalid character '}' looking for beginning of object key string"},
+			{"不完整", `{"title": "t"`, "malformed JSON: unexpected end of request body"},
+			{"字段类型不符", ep.typeBody, ep.typeWant},
+			{"请求体类型不符", `["a", "b"]`, "request body must be an object, got array"},
+			{"多余内容", `{"title": "t"} {"title": "u"}`, "malformed JSON: unexpected data after JSON value"},
+		} {
+			rec := doRequest(t, http.MethodPost, ep.path, tc.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: 状态码 = %d，期望 400", ep.path, tc.name, rec.Code)
+				continue
+			}
+			if got := decodeBody(t, rec)["error"]; got != tc.want {
+				t.Errorf("%s %s: error = %q，期望 %q", ep.path, tc.name, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestDefaultChoiceLimits(t *testing.T) {
+	setupTest(t)
+	cfg.DefaultMinChoices, cfg.DefaultMaxChoices = 2, 3
+	four := []string{"a", "b", "c", "d"}
+	for _, tc := range []struct {
+		name             string
+		req              map[string]interface{}
+		wantMin, wantMax int
+	}{
+		{"未提供时使用默认值", map[string]interface{}{"multi_select": true}, 2, 3},
+		{"明确为 0 时保持 0", map[string]interface{}{"multi_select": true, "min_choices": 0, "max_choices": 0}, 0, 0},
+		{"只提供 min_choices", map[string]interface{}{"multi_select": true, "min_choices": 0}, 0, 3},
+		{"默认最少数量不超过提供的最多数量", map[string]interface{}{"multi_select": true, "max_choices": 1}, 1, 1},
+		{"默认值不超过选项数", map[string]interface{}{"multi_select": true, "options": []string{"a", "b"}}, 2, 2},
+		{"单选投票不使用默认值", map[string]interface{}{}, 0, 0},
+	} {
+		req := map[string]interface{}{"title": "t", "options": four}
+		for k, v := range tc.req {
+			req[k] = v
+		}
+		pollID, _ := createTestPoll(t, req)
+		if poll := mustGet(t, pollID); poll.MinChoices != tc.wantMin || poll.MaxChoices != tc.wantMax {
+			t.Errorf("%s: min=%d max=%d，期望 min=%d max=%d", tc.name, poll.MinChoices, poll.MaxChoices, tc.wantMin, tc.wantMax)
+		}
+	}
+
+	// 默认值与明确提供的限制一样参与投票校验
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": four, "multi_select": true})
+	if rec := castVote(t, pollID, "a"); rec.Code != http.StatusBadRequest {
+		t.Errorf("少于默认最少数量的选票状态码 = %d，期望 400", rec.Code)
+	}
+	mustVote(t, pollID, "a", "b")
+}
+
+func TestInvalidOptionsChangeNothing(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b", "c"}, "multi_select": true, "min_choices": 2, "max_choices": 3,
+		"ip_limit": true, "close_after_first_vote": 60, "access_mode": AccessAllowlist,
+	})
+	doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/allowed-voters", map[string]interface{}{"voters": []string{"alice-token"}}, adminHeader...)
+
+	// count 返回表中与该投票相关的行数
+	count := func(query string) int {
+		t.Helper()
+		var n int
+		if err := store.db.QueryRow(query, pollID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	for name, options := range map[string][]string{
+		"全部选项都不存在":   {"x", "y"},
+		"有效选项少于最少数量": {"a", "x"},
+	} {
+		rec := voteWithToken(t, pollID, "alice-token", options...)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400", name, rec.Code)
+		}
+		poll := mustGet(t, pollID)
+		if poll.VoterCount != 0 || poll.Votes["a"] != 0 || poll.FirstVoteAt != nil {
+			t.Errorf("%s: 无效选票改变了投票: voter_count=%d votes=%v first_vote_at=%v", name, poll.VoterCount, poll.Votes, poll.FirstVoteAt)
+		}
+		// 事务已回滚：没有投票记录，IP 和名单令牌都没有被占用
+		for _, query := range []string{
+			`SELECT COUNT(*) FROM vote_events WHERE poll_id = ?`,
+			`SELECT COUNT(*) FROM voter_ips WHERE poll_id = ?`,
+			`SELECT COUNT(*) FROM allowed_voters WHERE poll_id = ? AND used_at IS NOT NULL`,
+		} {
+			if n := count(query); n != 0 {
+				t.Errorf("%s: %s = %d，期望 0", name, query, n)
+			}
+		}
+	}
+
+	// 之后的有效选票照常计入
+	if rec := voteWithToken(t, pollID, "alice-token", "a", "b", "x"); rec.Code != http.StatusOK {
+		t.Fatalf("有效选票被拒绝（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if poll := mustGet(t, pollID); poll.VoterCount != 1 || poll.Votes["a"] != 1 || poll.Votes["b"] != 1 {
+		t.Errorf("voter_count=%d votes=%v", poll.VoterCount, poll.Votes)
+	}
+}
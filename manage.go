@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// manageTokenHeader 投票管理令牌所在的请求头，也可以用 manage_token 查询参数传递
+const manageTokenHeader = "X-Manage-Token"
+
+// newManageToken 生成投票管理令牌。令牌只在创建时返回一次，库中只保存其哈希
+func newManageToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// hashManageToken 管理令牌的哈希。令牌是随机生成的，不需要加盐
+func hashManageToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// isPollOwner 请求是否带有该投票的管理令牌。旧版本创建的投票没有管理令牌，只能由管理员管理
+func isPollOwner(poll *Poll, r *http.Request) bool {
+	if poll.manageTokenHash == "" {
+		return false
+	}
+	token := r.Header.Get(manageTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("manage_token")
+	}
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashManageToken(token)), []byte(poll.manageTokenHash)) == 1
+}
+
+// canManage 请求者是否为管理员或持有该投票管理令牌的创建者
+func canManage(poll *Poll, r *http.Request) bool {
+	return isAdmin(r) || isPollOwner(poll, r)
+}
+
+// requireManage 请求者不能管理该投票时返回 403
+func requireManage(w http.ResponseWriter, r *http.Request, poll *Poll) bool {
+	if canManage(poll, r) {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   "manage token required",
+	})
+	return false
+}
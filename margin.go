@@ -0,0 +1,51 @@
+package main
+
+import "math"
+
+// ResultMargin 第一名领先第二名的幅度，用于判断结果是否接近
+type ResultMargin struct {
+	Leader   string  `json:"leader,omitempty"` // 并列第一时为空
+	Votes    int     `json:"votes"`            // 第一名与第二名的票数差，并列时为 0
+	Percent  float64 `json:"percent"`          // 票数差占百分比分母（见 percentBase）的百分比，保留一位小数
+	Decisive bool    `json:"decisive"`         // 领先幅度达到 -decisive-margin
+}
+
+// Margin 本投票公开结果的领先幅度，见 resultMargin
+func (p *Poll) Margin() *ResultMargin {
+	return resultMargin(p.Options, p.Votes, p.PercentBase())
+}
+
+// resultMargin 按 votes 中的选项（已去掉不公开的选项）计算领先幅度，base 为百分比分母。
+// 只有一个选项时与 0 票比较；结果暂不公开或还没有人投票时返回 nil
+func resultMargin(options []string, votes map[string]int, base int) *ResultMargin {
+	if votes == nil {
+		return nil
+	}
+	first, second := -1, 0
+	leader := ""
+	for _, opt := range options {
+		n, ok := votes[opt]
+		if !ok {
+			continue
+		}
+		switch {
+		case n > first:
+			second = max(first, 0)
+			first, leader = n, opt
+		case n == first:
+			second, leader = n, ""
+		case n > second:
+			second = n
+		}
+	}
+	if first <= 0 {
+		return nil
+	}
+
+	m := &ResultMargin{Leader: leader, Votes: first - second}
+	if base > 0 {
+		m.Percent = math.Round(float64(m.Votes)*1000/float64(base)) / 10
+	}
+	m.Decisive = m.Votes > 0 && m.Percent >= cfg.DecisiveMargin
+	return m
+}
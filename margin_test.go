@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResultMargin(t *testing.T) {
+	setupTest(t)
+	cfg.DecisiveMargin = 10
+	options := []string{"a", "b", "c"}
+	for _, tc := range []struct {
+		name  string
+		votes map[string]int
+		base  int
+		want  *ResultMargin
+	}{
+		{"明显领先", map[string]int{"a": 12, "b": 5, "c": 3}, 20, &ResultMargin{Leader: "a", Votes: 7, Percent: 35, Decisive: true}},
+		{"接近平局", map[string]int{"a": 50, "b": 49, "c": 1}, 100, &ResultMargin{Leader: "a", Votes: 1, Percent: 1}},
+		{"并列第一", map[string]int{"a": 4, "b": 4, "c": 2}, 10, &ResultMargin{Votes: 0, Percent: 0}},
+		{"后出现的选项领先", map[string]int{"a": 3, "b": 3, "c": 5}, 11, &ResultMargin{Leader: "c", Votes: 2, Percent: 18.2, Decisive: true}},
+		{"恰好达到阈值", map[string]int{"a": 6, "b": 5, "c": 0}, 10, &ResultMargin{Leader: "a", Votes: 1, Percent: 10, Decisive: true}},
+		{"只有一个选项有票", map[string]int{"a": 0, "b": 2, "c": 0}, 2, &ResultMargin{Leader: "b", Votes: 2, Percent: 100, Decisive: true}},
+		{"分母为 0", map[string]int{"a": 2, "b": 1}, 0, &ResultMargin{Leader: "a", Votes: 1}},
+		{"没有人投票", map[string]int{"a": 0, "b": 0, "c": 0}, 0, nil},
+		{"结果不公开", nil, 10, nil},
+	} {
+		if got := resultMargin(options, tc.votes, tc.base); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: margin = %+v，期望 %+v", tc.name, got, tc.want)
+		}
+	}
+
+	// 单选项投票与 0 票比较
+	if got := resultMargin([]string{"only"}, map[string]int{"only": 3}, 3); got == nil || got.Leader != "only" || got.Votes != 3 || got.Percent != 100 {
+		t.Errorf("单选项 margin = %+v", got)
+	}
+	// 不在 votes 中的选项（隐藏选项）不参与比较
+	if got := resultMargin(options, map[string]int{"a": 2, "b": 1}, 10); got == nil || got.Leader != "a" || got.Votes != 1 {
+		t.Errorf("去掉隐藏选项后 margin = %+v", got)
+	}
+}
+
+func TestMarginInCounts(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+
+	counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))
+	want := map[string]interface{}{"leader": "a", "votes": float64(1), "percent": 33.3, "decisive": true}
+	if !reflect.DeepEqual(counts["margin"], want) {
+		t.Errorf("counts margin = %v，期望 %v", counts["margin"], want)
+	}
+
+	mustVote(t, pollID, "b")
+	counts = decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))
+	want = map[string]interface{}{"votes": float64(0), "percent": float64(0), "decisive": false}
+	if !reflect.DeepEqual(counts["margin"], want) {
+		t.Errorf("平局时 counts margin = %v，期望 %v", counts["margin"], want)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errMergeSelf = errors.New("cannot merge a poll into itself")
+
+// Merge 把 source 的各选项票数（按选项名匹配）和投票人数累加到 target，然后软删除 source。
+// source 中有 target 没有的选项时，addMissing 为 true 则把选项追加到 target，否则拒绝合并。
+// 只合并 source 当前轮次的结果，已存档的轮次留在 source 中
+func (ps *PollStore) Merge(targetID, sourceID string, addMissing bool) (*Poll, error) {
+	if targetID == sourceID {
+		return nil, errMergeSelf
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var targetOptions, sourceOptions string
+	var targetRound, sourceRound, sourceVoters, sourceInitialVoters int
+	err = tx.QueryRow(`SELECT options, round FROM polls WHERE id = ? AND deleted_at IS NULL`, targetID).Scan(&targetOptions, &targetRound)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("poll not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(`SELECT options, round, voter_count, initial_voter_count FROM polls WHERE id = ? AND deleted_at IS NULL`, sourceID).Scan(&sourceOptions, &sourceRound, &sourceVoters, &sourceInitialVoters)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("source poll not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options := strings.Split(targetOptions, "|||")
+	known := make(map[string]bool, len(options))
+	for _, opt := range options {
+		known[opt] = true
+	}
+
+	// 先读完 source 的票数，再在同一事务内更新 target
+	counts := make(map[string]int)
+	initialCounts := make(map[string]int)
+	rows, err := tx.Query(`SELECT option_name, vote_count, initial_count FROM votes WHERE poll_id = ?`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var count, initial int
+		if err := rows.Scan(&name, &count, &initial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		counts[name] = count
+		initialCounts[name] = initial
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range strings.Split(sourceOptions, "|||") {
+		if !known[opt] {
+			if !addMissing {
+				return nil, fmt.Errorf("option %q does not exist in target poll", opt)
+			}
+			options = append(options, opt)
+			known[opt] = true
+			if _, err := tx.Exec(`
+				INSERT INTO votes (poll_id, option_name, vote_count)
+				VALUES (?, ?, 0)
+			`, targetID, opt); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE votes SET vote_count = vote_count + ?, initial_count = initial_count + ?
+			WHERE poll_id = ? AND option_name = ?
+		`, counts[opt], initialCounts[opt], targetID, opt); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE polls SET voter_count = voter_count + ?, initial_voter_count = initial_voter_count + ?, options = ?
+		WHERE id = ?
+	`, sourceVoters, sourceInitialVoters, strings.Join(options, "|||"), targetID); err != nil {
+		return nil, err
+	}
+
+	// 投票时间线和自填答案随票数一起转移。转移的投票记录改标为 target 的当前轮次，
+	// 否则重新统计和轮次历史会把它们算到错误的轮次；source 往期的记录与其存档一起留下
+	if _, err := tx.Exec(`
+		UPDATE vote_events SET poll_id = ?, round = ? WHERE poll_id = ? AND round = ?
+	`, targetID, targetRound, sourceID, sourceRound); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE write_ins SET poll_id = ? WHERE poll_id = ?`, targetID, sourceID); err != nil {
+		return nil, err
+	}
+
+	// 软删除 source，并释放其短链接
+	if _, err := tx.Exec(`
+		UPDATE polls SET deleted_at = ?, slug = NULL
+		WHERE id = ?
+	`, time.Now(), sourceID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(targetID)
+}
+
+func apiMergeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		SourceID          string `json:"source_id"`
+		AddMissingOptions bool   `json:"add_missing_options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SourceID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	poll, err := store.Merge(r.PathValue("id"), req.SourceID, req.AddMissingOptions)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"poll":    poll,
+	})
+}
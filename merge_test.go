@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// mergeRequest 以管理员身份把 source 合并到 target
+func mergeRequest(t *testing.T, target, source string, addMissing bool) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/poll/"+target+"/merge", map[string]interface{}{
+		"source_id":           source,
+		"add_missing_options": addMissing,
+	}, adminHeader...)
+}
+
+func TestMergePolls(t *testing.T) {
+	setupTest(t)
+	target, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	source, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"b", "a"}})
+	mustVote(t, target, "a")
+	mustVote(t, target, "a")
+	mustVote(t, target, "b")
+	mustVote(t, source, "a")
+	mustVote(t, source, "b")
+	mustVote(t, source, "b")
+	mustVote(t, source, "b")
+
+	if rec := doRequest(t, http.MethodPost, "/api/poll/"+target+"/merge", map[string]string{"source_id": source}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+	if rec := mergeRequest(t, target, source, false); rec.Code != http.StatusOK {
+		t.Fatalf("合并失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	poll := mustGet(t, target)
+	if poll.VoterCount != 7 || poll.Votes["a"] != 3 || poll.Votes["b"] != 4 {
+		t.Errorf("合并后 voter_count = %d，votes = %v，期望 7 和 a:3 b:4", poll.VoterCount, poll.Votes)
+	}
+	if _, err := store.Get(source); err == nil {
+		t.Error("合并后 source 没有被删除")
+	}
+	// 投票记录随之转移，重新计票结果不变
+	recounted, err := store.Recount(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recounted.Votes, poll.Votes) || recounted.VoterCount != poll.VoterCount {
+		t.Errorf("重新计票 = %d %v，合并结果 = %d %v", recounted.VoterCount, recounted.Votes, poll.VoterCount, poll.Votes)
+	}
+
+	if rec := mergeRequest(t, target, target, false); rec.Code != http.StatusBadRequest {
+		t.Errorf("合并到自身状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := mergeRequest(t, target, source, false); rec.Code != http.StatusNotFound {
+		t.Errorf("source 已删除时状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestMergeMismatchedOptions(t *testing.T) {
+	setupTest(t)
+	target, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	source, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "c"}})
+	mustVote(t, target, "a")
+	mustVote(t, source, "c")
+
+	if rec := mergeRequest(t, target, source, false); rec.Code != http.StatusBadRequest {
+		t.Fatalf("选项不一致时状态码 = %d，期望 400", rec.Code)
+	}
+	if poll := mustGet(t, target); poll.VoterCount != 1 || len(poll.Options) != 2 {
+		t.Errorf("拒绝合并后 target 被修改: %+v", poll)
+	}
+	if poll := mustGet(t, source); poll.VoterCount != 1 {
+		t.Errorf("拒绝合并后 source 被修改: %+v", poll)
+	}
+
+	if rec := mergeRequest(t, target, source, true); rec.Code != http.StatusOK {
+		t.Fatalf("add_missing_options 合并失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	poll := mustGet(t, target)
+	if !reflect.DeepEqual(poll.Options, []string{"a", "b", "c"}) || poll.Votes["c"] != 1 || poll.VoterCount != 2 {
+		t.Errorf("合并后 options = %v，votes = %v，voter_count = %d", poll.Options, poll.Votes, poll.VoterCount)
+	}
+}
+
+func TestMergeIntoLaterRound(t *testing.T) {
+	setupTest(t)
+	target, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	source, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, target, "b")
+	if _, err := store.NewRound(target); err != nil {
+		t.Fatal(err)
+	}
+	mustVote(t, target, "a")
+	mustVote(t, source, "a")
+	mustVote(t, source, "b")
+
+	if rec := mergeRequest(t, target, source, false); rec.Code != http.StatusOK {
+		t.Fatalf("合并失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	// source 的投票记录归入 target 的当前轮次，重新计票时不会丢失
+	poll, err := store.Recount(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.Round != 2 || poll.VoterCount != 3 || poll.Votes["a"] != 2 || poll.Votes["b"] != 1 {
+		t.Errorf("重新计票后 round = %d，voter_count = %d，votes = %v", poll.Round, poll.VoterCount, poll.Votes)
+	}
+}
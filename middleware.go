@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeoutExemptPaths 不受请求超时限制的路径前缀：流式导出、备份等耗时较长且需要边写边发的接口
+var timeoutExemptPaths = []string{"/api/export", "/api/backup", "/api/qrcodes.zip"}
+
+// timeoutExemptSuffixes 不受请求超时限制的路径后缀，用于带投票 ID 的流式接口
+var timeoutExemptSuffixes = []string{"/events.csv"}
+
+// timeoutMiddleware 为每个请求的 context 设置超时，到期后返回 503，并取消 r.Context()。
+// PollStore 的方法不接收 context，已开始的数据库操作不会中断，处理函数会在后台执行完毕，只是结果不再发送。
+// timeout 为 0 时不限制
+func timeoutMiddleware(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	limited := http.TimeoutHandler(next, timeout, "Request timed out")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range timeoutExemptPaths {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		for _, suffix := range timeoutExemptSuffixes {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipMiddleware 客户端支持 gzip 且响应体超过 minSize 时压缩响应，已压缩的内容（图片等）原样输出
+func gzipMiddleware(next http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(enc, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible 判断内容类型是否值得压缩
+func compressible(contentType string) bool {
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/pdf"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// gzipResponseWriter 先缓冲到 minSize 字节再决定是否压缩
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	g.status = status
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.decided {
+		g.buf = append(g.buf, p...)
+		if len(g.buf) < g.minSize {
+			return len(p), nil
+		}
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// decide 决定是否压缩并输出缓冲内容，large 表示响应体已超过阈值
+func (g *gzipResponseWriter) decide(large bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+
+	if large && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) &&
+		g.status != http.StatusNoContent && g.status != http.StatusNotModified {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzipWriterPool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if g.gz != nil {
+		_, err := g.gz.Write(buf)
+		return err
+	}
+	_, err := g.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush 流式输出时立即发送已写入的内容
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(true)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Close() {
+	if !g.decided {
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		gzipWriterPool.Put(g.gz)
+		g.gz = nil
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveGzip 经 gzip 中间件发送带 Accept-Encoding: gzip 的 GET 请求
+func serveGzip(path string, minSize int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	gzipMiddleware(routes(), minSize).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGzipLargeListing(t *testing.T) {
+	setupTest(t)
+	for range 20 {
+		createTestPoll(t, map[string]interface{}{"title": "一个足够长的投票标题，用来撑大列表响应", "options": []string{"a", "b", "c"}})
+	}
+
+	rec := serveGzip("/api/polls", cfg.GzipMinSize)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("大列表没有压缩，Content-Encoding = %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) <= cfg.GzipMinSize || !json.Valid(data) {
+		t.Errorf("解压后的列表无效（%d 字节）", len(data))
+	}
+
+	// 小响应不压缩
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := serveGzip("/api/poll/"+pollID+"/counts", cfg.GzipMinSize); rec.Header().Get("Content-Encoding") != "" {
+		t.Error("小于阈值的响应被压缩")
+	}
+}
+
+func TestGzipSkipsPNG(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	// 阈值设为 1 字节，确保跳过压缩是因为内容类型而不是大小
+	rec := serveGzip("/qrcode/"+pollID, 1)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d", rec.Code)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("PNG 被压缩，Content-Encoding = %q", enc)
+	}
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte("\x89PNG")) {
+		t.Error("响应不是 PNG")
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	ctxErr := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			ctxErr <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			ctxErr <- nil
+		}
+		w.Write([]byte("done"))
+	})
+	handler := timeoutMiddleware(slow, timeout)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/polls", nil))
+	elapsed := time.Since(start)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "Request timed out") {
+		t.Errorf("超时的请求状态码 = %d，响应 %q，期望 503", rec.Code, rec.Body.String())
+	}
+	if elapsed < timeout || elapsed > time.Second {
+		t.Errorf("请求在 %v 后结束，期望在 %v 左右被中断", elapsed, timeout)
+	}
+	// 处理函数的 context 已取消
+	select {
+	case err := <-ctxErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("处理函数的 context 错误 = %v，期望 DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("处理函数的 context 没有取消")
+	}
+
+	// 未超时的请求不受影响
+	fast := timeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), timeout)
+	rec = httptest.NewRecorder()
+	fast.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/polls", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("未超时的请求状态码 = %d，响应 %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimeoutMiddlewareExemptsStreaming(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	handler := timeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * timeout)
+		w.Write([]byte("streamed"))
+	}), timeout)
+
+	for _, path := range []string{"/api/export", "/api/backup", "/api/qrcodes.zip", "/api/poll/p1/events.csv"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "streamed" {
+			t.Errorf("%s 状态码 = %d，期望不受超时限制", path, rec.Code)
+		}
+	}
+
+	// timeout 为 0 时不限制
+	next := http.NotFoundHandler()
+	if got := timeoutMiddleware(next, 0); reflect.ValueOf(got).Pointer() != reflect.ValueOf(next).Pointer() {
+		t.Error("timeout 为 0 时应直接返回原处理器")
+	}
+}
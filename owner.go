@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxCreatorIDLength 创建者标识的最大长度
+const maxCreatorIDLength = 128
+
+// logAudit 在调用方的事务中记录一条审计日志
+func logAudit(tx *timedTx, pollID, action, detail string) error {
+	_, err := tx.Exec(`
+		INSERT INTO audit_log (poll_id, action, detail, created_at) VALUES (?, ?, ?, ?)
+	`, pollID, action, detail, time.Now().UTC())
+	return err
+}
+
+// GetByCreator 列出某个创建者的全部投票，按创建时间倒序
+func (ps *PollStore) GetByCreator(creatorID string) ([]*Poll, error) {
+	rows, err := ps.db.Query(`SELECT `+pollColumns+` FROM polls WHERE creator_id = ? AND deleted_at IS NULL ORDER BY created_at DESC`, creatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var polls []*Poll
+	for rows.Next() {
+		poll, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := ps.loadVotesBatch(polls); err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
+
+// TransferOwnership 把投票转给新的创建者，并在同一事务中记录审计日志。投票不存在时返回 sql.ErrNoRows
+func (ps *PollStore) TransferOwnership(pollID, creatorID string) (previous string, err error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT creator_id FROM polls WHERE id = ? AND deleted_at IS NULL`, pollID).Scan(&previous); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`UPDATE polls SET creator_id = ? WHERE id = ?`, creatorID, pollID); err != nil {
+		return "", err
+	}
+	if err := logAudit(tx, pollID, "transfer", fmt.Sprintf("%q -> %q", previous, creatorID)); err != nil {
+		return "", err
+	}
+	return previous, tx.Commit()
+}
+
+// apiTransferHandler 转移投票的所有权，需要该投票的管理令牌或管理员令牌
+func apiTransferHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if !requireManage(w, r, poll) {
+		return
+	}
+
+	var req struct {
+		CreatorID string `json:"creator_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+	req.CreatorID = strings.TrimSpace(req.CreatorID)
+	if req.CreatorID == "" || len(req.CreatorID) > maxCreatorIDLength {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("creator_id must be 1-%d bytes", maxCreatorIDLength),
+		})
+		return
+	}
+
+	previous, err := store.TransferOwnership(poll.ID, req.CreatorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":             true,
+		"poll_id":             poll.ID,
+		"creator_id":          req.CreatorID,
+		"previous_creator_id": previous,
+	})
+}
+
+var errTooManyPolls = errors.New("too many polls: delete some before creating more")
+
+// checkPollLimit 同一创建者未删除的投票数达到上限时返回 errTooManyPolls。
+// 提供了 creator_id 时按 creator_id 或 IP 任一匹配计数，避免换标识绕过限制
+func checkPollLimit(tx *timedTx, creatorID, creatorIP string, max int) error {
+	var n int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM polls
+		WHERE deleted_at IS NULL AND ((creator_id != '' AND creator_id = ?) OR (creator_ip != '' AND creator_ip = ?))
+	`, creatorID, creatorIP).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n >= max {
+		return errTooManyPolls
+	}
+	return nil
+}
+
+// apiExportCreatorHandler 导出某个创建者的全部投票（完整配置和票数），供数据迁移。
+// 只对管理员开放：creator_id 是接入方随意填写的标签，不经校验，凭它无法确认请求者就是该创建者
+func apiExportCreatorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	creatorID := r.PathValue("id")
+	polls, err := store.GetByCreator(creatorID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if polls == nil {
+		polls = []*Poll{}
+	}
+
+	// 创建者标识由接入方提供，可能含任意字符，不用于文件名
+	w.Header().Set("Content-Disposition", `attachment; filename="creator-export.json"`)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"creator_id":  creatorID,
+		"exported_at": time.Now().UTC(),
+		"polls":       polls,
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransferOwnership(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "creator_id": "alice"})
+	_, otherToken := createTestPoll(t, map[string]interface{}{"title": "other", "options": []string{"a", "b"}, "creator_id": "carol"})
+	transfer := func(pollID, creatorID string, headers ...string) int {
+		return doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/transfer", map[string]string{"creator_id": creatorID}, headers...).Code
+	}
+
+	// 没有令牌或持有其他投票的管理令牌都不能转移
+	for _, headers := range [][]string{nil, {manageTokenHeader, otherToken}} {
+		if code := transfer(pollID, "bob", headers...); code != http.StatusForbidden {
+			t.Errorf("请求头 %v 时状态码 = %d，期望 403", headers, code)
+		}
+	}
+	// 持有该投票管理令牌的创建者可以转移
+	if code := transfer(pollID, "dave", manageTokenHeader, manageToken); code != http.StatusOK {
+		t.Fatalf("创建者转移状态码 = %d，期望 200", code)
+	}
+	if poll := mustGet(t, pollID); poll.CreatorID != "dave" {
+		t.Fatalf("creator_id = %q，期望 dave", poll.CreatorID)
+	}
+	if code := transfer(pollID, "alice", manageTokenHeader, manageToken); code != http.StatusOK {
+		t.Fatalf("转回状态码 = %d，期望 200", code)
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/transfer", map[string]string{"creator_id": "bob"}, adminHeader...)
+	if body := decodeBody(t, rec); rec.Code != http.StatusOK || body["previous_creator_id"] != "alice" || body["creator_id"] != "bob" {
+		t.Fatalf("转移失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	if polls, err := store.GetByCreator("bob"); err != nil || len(polls) != 1 || polls[0].ID != pollID {
+		t.Errorf("GetByCreator(bob) = %v, %v", polls, err)
+	}
+	if polls, err := store.GetByCreator("alice"); err != nil || len(polls) != 0 {
+		t.Errorf("GetByCreator(alice) = %v, %v", polls, err)
+	}
+
+	var detail string
+	if err := store.db.QueryRow(`SELECT detail FROM audit_log WHERE poll_id = ? AND action = 'transfer' ORDER BY id DESC LIMIT 1`, pollID).Scan(&detail); err != nil || detail != `"alice" -> "bob"` {
+		t.Errorf("审计日志 = %q, %v", detail, err)
+	}
+
+	if code := transfer("missing", "bob", adminHeader...); code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", code)
+	}
+	if code := transfer(pollID, "  ", adminHeader...); code != http.StatusBadRequest {
+		t.Errorf("空的 creator_id 状态码 = %d，期望 400", code)
+	}
+}
+
+func TestPollLimitPerCreator(t *testing.T) {
+	setupTest(t)
+	cfg.MaxPollsPerCreator = 2
+	proxies, err := parseCIDRs("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TrustedProxies = proxies
+	create := func(creatorID, ip string) *httptest.ResponseRecorder {
+		return doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "creator_id": creatorID}, "X-Forwarded-For", ip)
+	}
+
+	var first, firstToken string
+	for i := 0; i < 2; i++ {
+		rec := create("alice", "203.0.113.1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("第 %d 个投票创建失败（%d）: %s", i+1, rec.Code, rec.Body.String())
+		}
+		if i == 0 {
+			body := decodeBody(t, rec)
+			first, firstToken = body["poll_id"].(string), body["manage_token"].(string)
+		}
+	}
+	if rec := create("alice", "203.0.113.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("第 3 个投票状态码 = %d，期望 429", rec.Code)
+	}
+	// 换 creator_id 或换 IP 都不能绕过
+	if rec := create("alice-2", "203.0.113.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("同一 IP 换 creator_id 状态码 = %d，期望 429", rec.Code)
+	}
+	if rec := create("alice", "203.0.113.2"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("同一 creator_id 换 IP 状态码 = %d，期望 429", rec.Code)
+	}
+	if rec := create("bob", "203.0.113.3"); rec.Code != http.StatusOK {
+		t.Errorf("其他创建者状态码 = %d", rec.Code)
+	}
+
+	// 问卷的每个问题都计入限制
+	survey := func(ip string, questions int) int {
+		qs := make([]map[string]interface{}, questions)
+		for i := range qs {
+			qs[i] = map[string]interface{}{"title": "q", "options": []string{"a", "b"}, "creator_id": "carol"}
+		}
+		return doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{"title": "问卷", "questions": qs}, "X-Forwarded-For", ip).Code
+	}
+	if code := survey("203.0.113.1", 1); code != http.StatusTooManyRequests {
+		t.Errorf("超出限制的问卷状态码 = %d，期望 429", code)
+	}
+	if code := survey("203.0.113.4", 3); code != http.StatusTooManyRequests {
+		t.Errorf("问题数超出限制的问卷状态码 = %d，期望 429", code)
+	}
+	if code := survey("203.0.113.4", 2); code != http.StatusOK {
+		t.Errorf("未超出限制的问卷状态码 = %d，期望 200", code)
+	}
+
+	// 只计未删除的投票
+	doRequest(t, http.MethodPost, "/api/delete-poll/"+first, nil, manageTokenHeader, firstToken)
+	if rec := create("alice", "203.0.113.1"); rec.Code != http.StatusOK {
+		t.Errorf("删除一个后状态码 = %d，期望 200", rec.Code)
+	}
+}
+
+func TestExportCreator(t *testing.T) {
+	setupTest(t)
+	mine := map[string]bool{}
+	for _, title := range []string{"one", "two"} {
+		id, _ := createTestPoll(t, map[string]interface{}{"title": title, "options": []string{"a", "b"}, "creator_id": "alice", "hide_results": true})
+		mustVote(t, id, "a")
+		mine[id] = true
+	}
+	createTestPoll(t, map[string]interface{}{"title": "other", "options": []string{"a", "b"}, "creator_id": "bob"})
+	createTestPoll(t, map[string]interface{}{"title": "nobody", "options": []string{"a", "b"}})
+	deleted, deletedToken := createTestPoll(t, map[string]interface{}{"title": "deleted", "options": []string{"a", "b"}, "creator_id": "alice"})
+	doRequest(t, http.MethodPost, "/api/delete-poll/"+deleted, nil, manageTokenHeader, deletedToken)
+
+	if rec := doRequest(t, http.MethodGet, "/api/export/creator/alice", nil); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("没有管理令牌时状态码 = %d", rec.Code)
+	}
+	// 投票的管理令牌只能证明拥有那一个投票，不能据此导出整个创建者
+	_, otherToken := createTestPoll(t, map[string]interface{}{"title": "three", "options": []string{"a", "b"}, "creator_id": "mallory"})
+	if rec := doRequest(t, http.MethodGet, "/api/export/creator/alice", nil, manageTokenHeader, otherToken); rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+		t.Errorf("只带投票管理令牌时状态码 = %d", rec.Code)
+	}
+	rec := doRequest(t, http.MethodGet, "/api/export/creator/alice", nil, adminHeader...)
+	var resp struct {
+		CreatorID string  `json:"creator_id"`
+		Polls     []*Poll `json:"polls"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("导出失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if len(resp.Polls) != len(mine) {
+		t.Fatalf("导出了 %d 个投票，期望 %d 个", len(resp.Polls), len(mine))
+	}
+	for _, poll := range resp.Polls {
+		// 导出包含完整配置和结果（结果隐藏的投票也导出票数）
+		if !mine[poll.ID] || poll.CreatorID != "alice" || !poll.HideResults || poll.Votes["a"] != 1 {
+			t.Errorf("导出的投票 = %+v", poll)
+		}
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/export/creator/nobody", nil, adminHeader...); !strings.Contains(rec.Body.String(), `"polls":[]`) {
+		t.Errorf("没有投票的创建者: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageTokenTTL 投票页令牌的有效期，超过后需刷新页面
+const pageTokenTTL = time.Hour
+
+var usedPageTokens = newReplayGuard()
+
+// newPageToken 打开投票页时签发的令牌：pollID.签发时间（毫秒）.随机数.签名
+func newPageToken(pollID string) string {
+	payload := fmt.Sprintf("%s.%d.%s", pollID, time.Now().UnixMilli(), randomHex(8))
+	return payload + "." + signString(payload)
+}
+
+// verifyPageToken 校验投票页令牌：签名正确、未过期、距签发已超过 minDelay，且只能使用一次。
+// 提交过快时不消耗令牌，稍后可以重试
+func verifyPageToken(pollID, token string, minDelay time.Duration) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != pollID {
+		return fmt.Errorf("invalid page token")
+	}
+	if !verifyString(strings.Join(parts[:3], "."), parts[3]) {
+		return fmt.Errorf("invalid page token")
+	}
+	issuedMs, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid page token")
+	}
+	issued := time.UnixMilli(issuedMs)
+	expires := issued.Add(pageTokenTTL)
+	if time.Now().After(expires) {
+		return fmt.Errorf("page token expired, please reload the page")
+	}
+	if time.Since(issued) < minDelay {
+		return fmt.Errorf("vote submitted too quickly")
+	}
+
+	if !usedPageTokens.Use(token, expires) {
+		return fmt.Errorf("page token already used, please reload the page")
+	}
+	return nil
+}
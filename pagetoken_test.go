@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pageTokenPattern = regexp.MustCompile(`const pageToken = '([^']*)'`)
+
+func TestMinVoteDelay(t *testing.T) {
+	setupTest(t)
+	cfg.MinVoteDelay = 100 * time.Millisecond
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	pageToken := func() string {
+		m := pageTokenPattern.FindStringSubmatch(doRequest(t, http.MethodGet, "/poll/"+pollID, nil).Body.String())
+		if m == nil || m[1] == "" {
+			t.Fatal("投票页没有下发令牌")
+		}
+		return m[1]
+	}
+	vote := func(token string) (int, string) {
+		rec := doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+			"poll_id":    pollID,
+			"options":    []string{"a"},
+			"page_token": token,
+		})
+		body := decodeBody(t, rec)
+		msg, _ := body["error"].(string)
+		return rec.Code, msg
+	}
+
+	if code, _ := vote(""); code != http.StatusBadRequest {
+		t.Errorf("没有令牌时状态码 = %d，期望 400", code)
+	}
+	token := pageToken()
+	if code, msg := vote(token); code != http.StatusBadRequest || msg != "vote submitted too quickly" {
+		t.Errorf("提交过快: %d %q", code, msg)
+	}
+
+	time.Sleep(cfg.MinVoteDelay)
+	// 过快的提交不消耗令牌，停留足够久后可以使用
+	if code, msg := vote(token); code != http.StatusOK {
+		t.Fatalf("正常提交被拒绝: %d %q", code, msg)
+	}
+	if code, _ := vote(token); code != http.StatusBadRequest {
+		t.Errorf("重复使用令牌状态码 = %d，期望 400", code)
+	}
+
+	// 篡改签发时间或签名都无法通过
+	parts := strings.Split(pageToken(), ".")
+	parts[1] = "1"
+	if code, _ := vote(strings.Join(parts, ".")); code != http.StatusBadRequest {
+		t.Errorf("篡改签发时间的状态码 = %d，期望 400", code)
+	}
+	other := newPageToken("other-poll")
+	time.Sleep(cfg.MinVoteDelay)
+	if code, _ := vote(other); code != http.StatusBadRequest {
+		t.Errorf("其他投票的令牌状态码 = %d，期望 400", code)
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// pdfFontBytes 读取 -pdf-font 指定的 TTF 字体；未配置或读取失败时返回 nil，使用内置字体（不支持中文）
+var pdfFontBytes = sync.OnceValue(func() []byte {
+	if cfg.PDFFont == "" {
+		return nil
+	}
+	b, err := os.ReadFile(cfg.PDFFont)
+	if err != nil {
+		log.Printf("读取 PDF 字体 %s 失败: %v", cfg.PDFFont, err)
+		return nil
+	}
+	return b
+})
+
+// defaultBarColor 未设置选项颜色时的柱状图颜色，与结果页一致
+const defaultBarColor = "#667eea"
+
+// newResultsPDF 生成投票结果 PDF：标题、各选项票数、百分比和柱状图，内容超出一页时自动分页
+func newResultsPDF(view ResultsView) (*bytes.Buffer, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+
+	family := "Helvetica"
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	if font := pdfFontBytes(); font != nil {
+		family = "custom"
+		pdf.AddUTF8FontFromBytes(family, "", font)
+		pdf.AddUTF8FontFromBytes(family, "B", font)
+		tr = func(s string) string { return s }
+	}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(family, "", 8)
+		pdf.SetTextColor(150, 150, 150)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%d / {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	left, _, right, bottom := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+
+	pdf.SetFont(family, "B", 18)
+	pdf.SetTextColor(51, 51, 51)
+	pdf.MultiCell(contentWidth, 9, tr(view.Title), "", "L", false)
+	pdf.Ln(2)
+
+	status := "Open"
+	if view.IsClosed() {
+		status = "Closed"
+	}
+	pdf.SetFont(family, "", 10)
+	pdf.SetTextColor(120, 120, 120)
+	summary := fmt.Sprintf("Status: %s    Generated: %s", status, time.Now().Format("2006-01-02 15:04"))
+	if !view.VoterCountWithheld() {
+		summary = fmt.Sprintf("Voters: %d    %s", view.VoterCount, summary)
+	}
+	pdf.CellFormat(contentWidth, 6, summary, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	if view.Withheld {
+		pdf.SetFont(family, "", 12)
+		pdf.SetTextColor(51, 51, 51)
+		pdf.MultiCell(contentWidth, 7, view.withheldNotice(), "", "L", false)
+	} else {
+		const barHeight, rowGap = 6.0, 4.0
+		barWidth := contentWidth - 40
+
+		for _, option := range view.ResultOptions() {
+			count := view.Votes[option]
+			percent := view.OptionPercent(option)
+
+			// 选项名和柱状图保持在同一页
+			if pdf.GetY()+barHeight+rowGap+12 > pageHeight-bottom {
+				pdf.AddPage()
+			}
+
+			pdf.SetFont(family, "B", 11)
+			pdf.SetTextColor(51, 51, 51)
+			pdf.MultiCell(contentWidth, 6, tr(option), "", "L", false)
+
+			y := pdf.GetY() + 1
+			pdf.SetFillColor(240, 240, 240)
+			pdf.Rect(left, y, barWidth, barHeight, "F")
+			color := view.OptionColors[option]
+			if color == "" {
+				color = defaultBarColor
+			}
+			pdf.SetFillColor(hexToRGB(color))
+			if w := barWidth * percent / 100; w > 0 {
+				pdf.Rect(left, y, w, barHeight, "F")
+			}
+
+			pdf.SetXY(left+barWidth+3, y)
+			pdf.SetFont(family, "", 10)
+			pdf.SetTextColor(80, 80, 80)
+			label := fmt.Sprintf("%d  (%.1f%%)", count, percent)
+			if view.VoterCountWithheld() {
+				label = fmt.Sprintf("%.1f%%", percent)
+			}
+			pdf.CellFormat(37, barHeight, label, "", 1, "L", false, 0, "")
+			pdf.SetY(y + barHeight + rowGap)
+		}
+	}
+
+	if view.ClosingMessage != "" {
+		pdf.Ln(4)
+		pdf.SetFont(family, "", 11)
+		pdf.SetTextColor(51, 51, 51)
+		pdf.MultiCell(contentWidth, 6, tr(view.ClosingMessage), "", "L", false)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// hexToRGB 把 #rrggbb 转换为 RGB 分量，格式错误时返回灰色
+func hexToRGB(color string) (int, int, int) {
+	if len(color) != 7 {
+		return 128, 128, 128
+	}
+	v, err := strconv.ParseUint(color[1:], 16, 32)
+	if err != nil {
+		return 128, 128, 128
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+}
+
+// writeResultsPDF 以附件形式输出投票结果 PDF
+func writeResultsPDF(w http.ResponseWriter, view ResultsView) {
+	buf, err := newResultsPDF(view)
+	if err != nil {
+		log.Printf("生成 PDF 失败: %v", err)
+		http.Error(w, "Failed to generate PDF", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="poll-%s.pdf"`, view.ID))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
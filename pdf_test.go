@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestResultsPDF(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+	mustVote(t, pollID, "a")
+
+	rec := doRequest(t, http.MethodGet, "/api/results/"+pollID+".pdf", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Content-Type = %q，期望 application/pdf", ct)
+	}
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")) {
+		t.Errorf("响应不是 PDF（%d 字节）", rec.Body.Len())
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/results/missing.pdf", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestResultsPDFPaginates(t *testing.T) {
+	setupTest(t)
+	options := make([]string, 80)
+	for i := range options {
+		options[i] = fmt.Sprintf("选项 %d", i+1)
+	}
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":   strings.Repeat("一个很长很长的投票标题", 20),
+		"options": options,
+	})
+	mustVote(t, pollID, options[0])
+
+	rec := doRequest(t, http.MethodGet, "/api/results/"+pollID+".pdf", nil)
+	pages := regexp.MustCompile(`/Type /Page\b`).FindAll(rec.Body.Bytes(), -1)
+	if len(pages) < 2 {
+		t.Errorf("80 个选项只生成了 %d 页", len(pages))
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errInvalidTemplateName = errors.New("template name must be 1-64 lowercase letters, digits or dashes")
+
+// PollTemplate 保存的投票配置，标题中的 {date} 在创建投票时替换为当天日期
+type PollTemplate struct {
+	Name      string            `json:"name"`
+	Config    CreatePollRequest `json:"config"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// templateConfigFromPoll 提取投票的可复用配置（不含短链接和票数）
+func templateConfigFromPoll(poll *Poll) CreatePollRequest {
+	return CreatePollRequest{
+		Title:       poll.Title,
+		Options:     poll.Options,
+		MultiSelect: poll.MultiSelect,
+		MinChoices:  &poll.MinChoices,
+		MaxChoices:  &poll.MaxChoices,
+		WebhookURL:  poll.WebhookURL,
+		AccessMode:  poll.AccessMode,
+		HideResults: poll.HideResults,
+
+		CloseAfterFirstVote: poll.CloseAfterFirstVote,
+		ClosingMessage:      poll.ClosingMessage,
+		OptionColors:        poll.OptionColors,
+		OptionCapacity:      poll.OptionCapacity,
+		HiddenOptions:       poll.HiddenOptions,
+		Anonymous:           &poll.Anonymous,
+		AllowAbstain:        poll.AllowAbstain,
+		AllowWriteIns:       poll.AllowWriteIns,
+		IPLimit:             poll.IPLimit,
+		RedirectURL:         poll.RedirectURL,
+		OptionOrder:         poll.OptionOrder,
+		HideVoterCount:      poll.HideVoterCount,
+		ConfirmVote:         poll.ConfirmVote,
+		CreatorID:           poll.CreatorID,
+		ResultsVisibleAt:    poll.ResultsVisibleAt,
+		ExpectedVoters:      poll.ExpectedVoters,
+		GroupLimits:         poll.GroupLimits,
+		Kind:                poll.Kind,
+		NotifyEmail:         poll.NotifyEmail,
+		PercentMode:         poll.PercentMode,
+		ResultVisibility:    poll.ResultVisibility,
+	}
+}
+
+// SaveTemplate 保存或覆盖同名模板。短链接和初始票数不属于可复用配置，不会保存
+func (ps *PollStore) SaveTemplate(name string, config CreatePollRequest) error {
+	if len(name) > 64 || !slugPattern.MatchString(name) {
+		return errInvalidTemplateName
+	}
+	config.Slug = ""
+	config.InitialVotes = nil
+	config.InitialVoterCount = 0
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = ps.db.Exec(`
+		INSERT INTO poll_templates (name, config, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET config = excluded.config, created_at = excluded.created_at
+	`, name, string(data), time.Now().UTC())
+	return err
+}
+
+// GetTemplate 按名称读取模板
+func (ps *PollStore) GetTemplate(name string) (*PollTemplate, error) {
+	var t PollTemplate
+	var data string
+	err := ps.db.QueryRow(`SELECT name, config, created_at FROM poll_templates WHERE name = ?`, name).Scan(&t.Name, &data, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &t.Config); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTemplates 列出全部模板
+func (ps *PollStore) ListTemplates() ([]PollTemplate, error) {
+	rows, err := ps.db.Query(`SELECT name, config, created_at FROM poll_templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []PollTemplate{}
+	for rows.Next() {
+		var t PollTemplate
+		var data string
+		if err := rows.Scan(&t.Name, &data, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &t.Config); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// expandTitle 替换标题模式中的占位符
+func expandTitle(pattern string, now time.Time) string {
+	return strings.ReplaceAll(pattern, "{date}", now.Format("2006-01-02"))
+}
+
+func apiPollTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		list, err := store.ListTemplates()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success":   true,
+			"templates": list,
+		})
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	// 从已有投票保存（poll_id），或直接提供配置（config）
+	var req struct {
+		Name   string             `json:"name"`
+		PollID string             `json:"poll_id"`
+		Config *CreatePollRequest `json:"config"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.PollID == "") == (req.Config == nil) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request: provide name and either poll_id or config",
+		})
+		return
+	}
+
+	var config CreatePollRequest
+	if req.PollID != "" {
+		poll, err := store.Resolve(req.PollID)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"error":   "poll not found",
+			})
+			return
+		}
+		config = templateConfigFromPoll(poll)
+	} else {
+		config = *req.Config
+		config.applyOptionsText()
+		if errs := config.Validate(); len(errs) > 0 {
+			writeCreateError(w, errs)
+			return
+		}
+	}
+
+	if err := store.SaveTemplate(req.Name, config); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func apiPollFromTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	t, err := store.GetTemplate(r.PathValue("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "template not found",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 请求体可选，可覆盖标题和设置短链接
+	var overrides struct {
+		Title string `json:"title"`
+		Slug  string `json:"slug"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	req := t.Config
+	req.Title = expandTitle(req.Title, time.Now())
+	if overrides.Title != "" {
+		req.Title = overrides.Title
+	}
+	req.Slug = overrides.Slug
+
+	poll, err := createPoll(&req)
+	if err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"poll_id":      poll.ID,
+		"title":        poll.Title,
+		"manage_token": poll.ManageToken,
+	})
+}
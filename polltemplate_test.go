@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPollTemplateFromPoll(t *testing.T) {
+	setupTest(t)
+	source, _ := createTestPoll(t, map[string]interface{}{
+		"title":           "周会时间",
+		"options":         []string{"周一", "周三", "周五"},
+		"multi_select":    true,
+		"max_choices":     2,
+		"hide_results":    true,
+		"closing_message": "下周见",
+		"option_colors":   map[string]string{"周一": "#ff0000"},
+		"slug":            "weekly-meeting",
+	})
+	mustVote(t, source, "周一")
+
+	save := map[string]interface{}{"name": "weekly", "poll_id": source}
+	if rec := doRequest(t, http.MethodPost, "/api/poll-templates", save); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌保存模板状态码 = %d，期望 401", rec.Code)
+	}
+	if rec := doRequest(t, http.MethodPost, "/api/poll-templates", save, adminHeader...); decodeBody(t, rec)["success"] != true {
+		t.Fatalf("保存模板失败: %s", rec.Body.String())
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/poll-from-template/weekly", nil, adminHeader...)
+	body := decodeBody(t, rec)
+	if body["success"] != true || body["manage_token"] == "" {
+		t.Fatalf("按模板创建失败: %s", rec.Body.String())
+	}
+	src, poll := mustGet(t, source), mustGet(t, body["poll_id"].(string))
+	if poll.Title != src.Title || !reflect.DeepEqual(poll.Options, src.Options) {
+		t.Errorf("标题和选项 = %q %v，期望 %q %v", poll.Title, poll.Options, src.Title, src.Options)
+	}
+	if !poll.MultiSelect || poll.MaxChoices != 2 || !poll.HideResults || poll.ClosingMessage != "下周见" || poll.OptionColors["周一"] != "#ff0000" {
+		t.Errorf("设置没有沿用: %+v", poll)
+	}
+	// 短链接和票数不属于模板
+	if poll.Slug != "" || poll.VoterCount != 0 {
+		t.Errorf("slug = %q，voter_count = %d，期望都为空", poll.Slug, poll.VoterCount)
+	}
+}
+
+func TestPollTemplateFromConfig(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodPost, "/api/poll-templates", map[string]interface{}{
+		"name":   "standup",
+		"config": map[string]interface{}{"title": "站会 {date}", "options": []string{"参加", "请假"}, "allow_abstain": true},
+	}, adminHeader...)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("保存模板失败: %s", rec.Body.String())
+	}
+
+	body := decodeBody(t, doRequest(t, http.MethodPost, "/api/poll-from-template/standup", nil, adminHeader...))
+	poll := mustGet(t, body["poll_id"].(string))
+	if want := "站会 " + time.Now().Format("2006-01-02"); poll.Title != want {
+		t.Errorf("标题 = %q，期望 %q", poll.Title, want)
+	}
+	if !reflect.DeepEqual(poll.Options, []string{"参加", "请假"}) || !poll.AllowAbstain {
+		t.Errorf("poll = %+v", poll)
+	}
+
+	// 可覆盖标题并设置短链接
+	body = decodeBody(t, doRequest(t, http.MethodPost, "/api/poll-from-template/standup", map[string]string{"title": "临时站会", "slug": "standup-now"}, adminHeader...))
+	if poll := mustGet(t, body["poll_id"].(string)); poll.Title != "临时站会" || poll.Slug != "standup-now" {
+		t.Errorf("覆盖后 title = %q，slug = %q", poll.Title, poll.Slug)
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/poll-from-template/missing", nil, adminHeader...); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的模板状态码 = %d，期望 404", rec.Code)
+	}
+	rec = doRequest(t, http.MethodPost, "/api/poll-templates", map[string]interface{}{
+		"name":   "Bad Name",
+		"config": map[string]interface{}{"title": "t", "options": []string{"a", "b"}},
+	}, adminHeader...)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("非法模板名状态码 = %d，期望 400", rec.Code)
+	}
+}
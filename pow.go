@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// powTTL 工作量证明题目的有效期
+const powTTL = 10 * time.Minute
+
+var usedChallenges = newReplayGuard()
+
+// newPowChallenge 生成绑定到投票的题目：pollID.签发时间.随机数.签名
+func newPowChallenge(pollID string) string {
+	payload := fmt.Sprintf("%s.%d.%s", pollID, time.Now().Unix(), randomHex(8))
+	return payload + "." + signString(payload)
+}
+
+// verifyPow 校验 X-PoW 头（格式为 challenge:nonce），要求 sha256(challenge:nonce) 至少有 difficulty 个前导零比特。
+// 校验通过后占用并返回题目，并发提交同一解答会被拒绝；选票未计入时由调用方 Release，已解出的题目仍可使用
+func verifyPow(pollID, header string, difficulty int) (string, error) {
+	idx := strings.LastIndex(header, ":")
+	if idx < 0 {
+		return "", fmt.Errorf("missing proof of work")
+	}
+	challenge := header[:idx]
+
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 || parts[0] != pollID {
+		return "", fmt.Errorf("invalid challenge")
+	}
+	if !verifyString(strings.Join(parts[:3], "."), parts[3]) {
+		return "", fmt.Errorf("invalid challenge")
+	}
+	issued, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge")
+	}
+	expires := time.Unix(issued, 0).Add(powTTL)
+	if time.Now().After(expires) {
+		return "", fmt.Errorf("challenge expired")
+	}
+
+	sum := sha256.Sum256([]byte(header))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return "", fmt.Errorf("invalid proof of work")
+	}
+
+	if !usedChallenges.Use(challenge, expires) {
+		return "", fmt.Errorf("challenge already used")
+	}
+	return challenge, nil
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
+
+func apiVoteChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 问卷整体提交，题目绑定到问卷 ID
+	pollID := r.URL.Path[len("/api/vote-challenge/"):]
+	isSurvey, _ := store.SurveyExists(pollID)
+	if _, err := store.Get(pollID); err != nil && !isSurvey {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+
+	if cfg.PoWDifficulty <= 0 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"enabled": false,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"enabled":    true,
+		"challenge":  newPowChallenge(pollID),
+		"difficulty": cfg.PoWDifficulty,
+	})
+}
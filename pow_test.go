@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// solvePow 暴力求解题目，返回 X-PoW 头
+func solvePow(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		header := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(header))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return header
+		}
+	}
+}
+
+func TestVoteProofOfWork(t *testing.T) {
+	setupTest(t)
+	cfg.PoWDifficulty = 8
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	challengeFor := func() string {
+		body := decodeBody(t, doRequest(t, http.MethodGet, "/api/vote-challenge/"+pollID, nil))
+		if body["enabled"] != true {
+			t.Fatalf("challenge = %v", body)
+		}
+		return body["challenge"].(string)
+	}
+	vote := func(pow string) int {
+		return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+			"poll_id": pollID,
+			"options": []string{"a"},
+		}, "X-PoW", pow).Code
+	}
+
+	if code := vote(""); code != http.StatusBadRequest {
+		t.Errorf("没有工作量证明时状态码 = %d，期望 400", code)
+	}
+
+	// 找一个不满足难度的 nonce
+	challenge := challengeFor()
+	invalid := ""
+	for nonce := 0; ; nonce++ {
+		header := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(header))
+		if leadingZeroBits(sum[:]) < cfg.PoWDifficulty {
+			invalid = header
+			break
+		}
+	}
+	if code := vote(invalid); code != http.StatusBadRequest {
+		t.Errorf("错误的解状态码 = %d，期望 400", code)
+	}
+
+	valid := solvePow(challengeFor(), cfg.PoWDifficulty)
+	if code := vote(valid); code != http.StatusOK {
+		t.Fatalf("正确的解状态码 = %d，期望 200", code)
+	}
+	if code := vote(valid); code != http.StatusBadRequest {
+		t.Errorf("重复使用的题目状态码 = %d，期望 400", code)
+	}
+	if got := mustGet(t, pollID).VoterCount; got != 1 {
+		t.Errorf("voter_count = %d，期望 1", got)
+	}
+}
+
+func TestVerifyPowRejectsForgedChallenge(t *testing.T) {
+	setupTest(t)
+	forged := solvePow("poll.1700000000.abcd.not-a-signature", 4)
+	if _, err := verifyPow("poll", forged, 4); err == nil {
+		t.Error("伪造的题目通过了校验")
+	}
+	other := solvePow(newPowChallenge("other-poll"), 4)
+	if _, err := verifyPow("poll", other, 4); err == nil {
+		t.Error("其他投票的题目通过了校验")
+	}
+}
+
+func TestPowReleasedWhenVoteFails(t *testing.T) {
+	setupTest(t)
+	cfg.PoWDifficulty = 8
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/vote-challenge/"+pollID, nil))
+	valid := solvePow(body["challenge"].(string), cfg.PoWDifficulty)
+
+	doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, manageTokenHeader, manageToken)
+	rec := doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+		"poll_id": pollID,
+		"options": []string{"a"},
+	}, "X-PoW", valid)
+	if decodeBody(t, rec)["success"] == true {
+		t.Fatal("已结束的投票接受了选票")
+	}
+
+	// 选票没有计入，解出的题目仍可使用
+	if _, err := verifyPow(pollID, valid, cfg.PoWDifficulty); err != nil {
+		t.Errorf("投票失败后题目未释放: %v", err)
+	}
+}
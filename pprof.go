@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofMux 性能分析接口，只挂在 -pprof 指定的单独监听地址上
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// servePprof 在单独的地址上提供 pprof。接口可以读取内存和命令行参数，地址应只对内网开放
+func servePprof(addr string) {
+	log.Printf("pprof 监听在 http://%s/debug/pprof/", addr)
+	if err := http.ListenAndServe(addr, pprofMux()); err != nil {
+		log.Printf("pprof 监听失败: %v", err)
+	}
+}
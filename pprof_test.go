@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofOnlyOnSeparateListener(t *testing.T) {
+	setupTest(t)
+	// 对外的路由上没有调试接口
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline"} {
+		if rec := doRequest(t, http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("对外路由 %s 状态码 = %d，期望 404", path, rec.Code)
+		}
+	}
+
+	// -pprof 的单独监听地址上提供调试接口
+	mux := pprofMux()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/goroutine?debug=1"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("pprof %s 状态码 = %d", path, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/polls", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("pprof 监听地址上的 /api/polls 状态码 = %d，期望 404", rec.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+)
+
+// presentPollInterval 演示页轮询 /api/poll/{id}/counts 的间隔（毫秒）
+const presentPollInterval = 3000
+
+// PresentView 演示页数据：结果加上大号二维码对应的投票地址
+type PresentView struct {
+	ResultsView
+	VoteURL      string
+	PollInterval int
+}
+
+// presentHandler 投屏用的演示页：GET /present/{id}，大号二维码和实时刷新的结果
+func presentHandler(w http.ResponseWriter, r *http.Request) {
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+	if writeHeadOnly(w, r, "text/html; charset=utf-8") {
+		return
+	}
+
+	ref := poll.ID
+	if poll.Slug != "" {
+		ref = poll.Slug
+	}
+	renderTemplate(w, "present.html", PresentView{
+		ResultsView:  newResultsView(poll, r),
+		VoteURL:      cfg.BaseURL + "/poll/" + ref,
+		PollInterval: presentPollInterval,
+	})
+}
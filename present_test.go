@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPresentPage(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "年会节目", "options": []string{"相声", "合唱"}, "slug": "gala"})
+	mustVote(t, pollID, "相声")
+
+	rec := doRequest(t, http.MethodGet, "/present/gala", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("演示页状态码 = %d", rec.Code)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<h1>年会节目</h1>",
+		`id="qrPanel"`,
+		`<img src="/qrcode/` + pollID + `"`,
+		"http://vote.test/poll/gala",
+		`id="results"`,
+		`data-option="相声"`,
+		`data-option="合唱"`,
+		"1 人已投票",
+		"/counts",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("演示页缺少 %s", want)
+		}
+	}
+
+	// 二维码图片可以正常取得
+	if qr := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil); qr.Code != http.StatusOK || qr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("二维码状态码 = %d，Content-Type = %q", qr.Code, qr.Header().Get("Content-Type"))
+	}
+
+	if rec := doRequest(t, http.MethodHead, "/present/"+pollID, nil); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("HEAD 状态码 = %d，响应体 %d 字节", rec.Code, rec.Body.Len())
+	}
+	if rec := doRequest(t, http.MethodGet, "/present/no-such-poll", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestPresentPageWithheldResults(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_results": true})
+	mustVote(t, pollID, "a")
+
+	page := doRequest(t, http.MethodGet, "/present/"+pollID, nil).Body.String()
+	if !strings.Contains(page, `id="qrPanel"`) || !strings.Contains(page, "结果将在投票结束后公布") {
+		t.Error("结果未公开时演示页应显示二维码和提示")
+	}
+	if strings.Contains(page, `class="result-item"`) {
+		t.Error("结果未公开时演示页不应包含票数")
+	}
+}
+
+func TestPresentPageHiddenVoterCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true})
+	mustVote(t, pollID, "a")
+
+	// 隐去投票人数时不显示人数和各选项票数，只显示百分比
+	page := doRequest(t, http.MethodGet, "/present/"+pollID, nil).Body.String()
+	if strings.Contains(page, "人已投票</div>") || strings.Contains(page, `class="vote-count"`) {
+		t.Error("隐去投票人数时演示页不应显示人数或票数")
+	}
+	if !strings.Contains(page, "100.0%") {
+		t.Error("隐去投票人数时演示页应显示百分比")
+	}
+}
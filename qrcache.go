@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// qrCacheKey 决定二维码内容的全部参数。地址前缀也在键中，-base-url 变化后旧条目不会再被命中
+type qrCacheKey struct {
+	baseURL string
+	pollID  string
+	size    int
+	level   qrcode.RecoveryLevel
+	format  string
+}
+
+type qrCacheEntry struct {
+	key     qrCacheKey
+	data    []byte
+	expires time.Time
+}
+
+// qrCodeCache 已编码二维码的 LRU 缓存，条目数超过上限时淘汰最久未使用的条目
+type qrCodeCache struct {
+	mu    sync.Mutex
+	max   int
+	ttl   time.Duration
+	order *list.List // 最近使用的在前
+	items map[qrCacheKey]*list.Element
+
+	hits, misses int64
+}
+
+// QRCacheStats 缓存命中统计
+type QRCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// newQRCodeCache max 为 0 时不缓存
+func newQRCodeCache(max int, ttl time.Duration) *qrCodeCache {
+	return &qrCodeCache{
+		max:   max,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[qrCacheKey]*list.Element),
+	}
+}
+
+// get 返回未过期的缓存内容
+func (c *qrCodeCache) get(key qrCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*qrCacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.hits++
+			return entry.data, true
+		}
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *qrCodeCache) put(key qrCacheKey, data []byte) {
+	if c.max <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		el.Value = &qrCacheEntry{key: key, data: data, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&qrCacheEntry{key: key, data: data, expires: expires})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*qrCacheEntry).key)
+	}
+}
+
+// Stats 当前条目数和累计命中次数
+func (c *qrCodeCache) Stats() QRCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return QRCacheStats{Entries: c.order.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+// qrCache 在 main 中按 -qr-cache-size 和 -qr-cache-ttl 初始化
+var qrCache = newQRCodeCache(0, 0)
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestQRCodeCacheHit(t *testing.T) {
+	setupTest(t)
+	prev := qrCache
+	qrCache = newQRCodeCache(10, time.Hour)
+	t.Cleanup(func() { qrCache = prev })
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	first := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil)
+	second := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil)
+	if first.Code != http.StatusOK || !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Fatalf("两次请求结果不同（%d）", first.Code)
+	}
+	if stats := qrCache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("stats = %+v，期望命中 1 次、未命中 1 次", stats)
+	}
+
+	// -base-url 变化后不使用旧地址的二维码
+	cfg.BaseURL = "http://other.test"
+	if third := doRequest(t, http.MethodGet, "/qrcode/"+pollID, nil); bytes.Equal(third.Body.Bytes(), first.Body.Bytes()) {
+		t.Error("更换地址后仍返回旧的二维码")
+	}
+	if stats := qrCache.Stats(); stats.Misses != 2 {
+		t.Errorf("更换地址后 stats = %+v，期望未命中 2 次", stats)
+	}
+}
+
+func TestQRCodeCacheEvictsAndExpires(t *testing.T) {
+	key := func(id string) qrCacheKey {
+		return qrCacheKey{baseURL: "http://vote.test", pollID: id, size: 256, level: qrcode.Medium, format: "png"}
+	}
+	c := newQRCodeCache(2, time.Hour)
+	c.put(key("a"), []byte("a"))
+	c.put(key("b"), []byte("b"))
+	c.get(key("a")) // a 最近使用，淘汰 b
+	c.put(key("c"), []byte("c"))
+	if _, ok := c.get(key("b")); ok {
+		t.Error("最久未使用的条目没有被淘汰")
+	}
+	for _, id := range []string{"a", "c"} {
+		if data, ok := c.get(key(id)); !ok || string(data) != id {
+			t.Errorf("条目 %s = %q, %v", id, data, ok)
+		}
+	}
+
+	expiring := newQRCodeCache(2, time.Millisecond)
+	expiring.put(key("a"), []byte("a"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := expiring.get(key("a")); ok {
+		t.Error("过期的条目仍被命中")
+	}
+	if stats := expiring.Stats(); stats.Entries != 0 {
+		t.Errorf("过期条目没有被移除: %+v", stats)
+	}
+
+	disabled := newQRCodeCache(0, time.Hour)
+	disabled.put(key("a"), []byte("a"))
+	if _, ok := disabled.get(key("a")); ok {
+		t.Error("上限为 0 时仍然缓存")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// qrFileName ZIP 中二维码的文件名：优先使用短链接，其次使用标题，重名时追加投票 ID 前缀
+func qrFileName(poll *Poll, used map[string]bool) string {
+	name := poll.Slug
+	if name == "" {
+		name = strings.Map(func(r rune) rune {
+			if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(poll.Title))
+	}
+	if name == "" || used[name] {
+		if name != "" {
+			name += "-"
+		}
+		name += poll.ID[:min(8, len(poll.ID))]
+	}
+	used[name] = true
+	return name + ".png"
+}
+
+// apiQRCodesZipHandler 把全部投票（或 ?creator_id= 指定创建者的投票）的二维码打包为 ZIP 下载，
+// 边生成边写出，不在内存中缓存整个文件
+func apiQRCodesZipHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var polls []*Poll
+	var err error
+	if creator := r.URL.Query().Get("creator_id"); creator != "" {
+		polls, err = store.GetByCreator(creator)
+	} else {
+		polls, err = store.GetAll()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="qrcodes.zip"`)
+
+	zw := zip.NewWriter(w)
+	used := make(map[string]bool, len(polls))
+	for _, poll := range polls {
+		qr, err := pollQRCode(poll.ID)
+		if err != nil {
+			// 响应已经开始，只能中断并记录日志
+			log.Printf("导出二维码 %s 失败: %v", poll.ID, err)
+			return
+		}
+		// PNG 本身已压缩，直接存储
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     qrFileName(poll, used),
+			Method:   zip.Store,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return
+		}
+		if _, err := f.Write(qr); err != nil {
+			return
+		}
+	}
+	zw.Close()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"image/png"
+	"io"
+	"net/http"
+	"sort"
+	"testing"
+)
+
+// readQRZip 下载二维码 ZIP，校验每个文件都是 PNG，返回排序后的文件名
+func readQRZip(t *testing.T, path string) []string {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, path, nil, adminHeader...)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("状态码 = %d，Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("不是合法的 ZIP: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+			t.Errorf("%s 不是合法的 PNG: %v", f.Name, err)
+		}
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestQRCodesZip(t *testing.T) {
+	setupTest(t)
+	createTestPoll(t, map[string]interface{}{"title": "午饭", "options": []string{"a", "b"}, "slug": "lunch", "creator_id": "alice"})
+	dup1, _ := createTestPoll(t, map[string]interface{}{"title": "a/b 测试", "options": []string{"a", "b"}, "creator_id": "alice"})
+	dup2, _ := createTestPoll(t, map[string]interface{}{"title": "a/b 测试", "options": []string{"a", "b"}, "creator_id": "bob"})
+
+	if rec := doRequest(t, http.MethodGet, "/api/qrcodes.zip", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+
+	names := readQRZip(t, "/api/qrcodes.zip")
+	if len(names) != 3 {
+		t.Fatalf("ZIP 中有 %d 个文件，期望 3 个: %v", len(names), names)
+	}
+	// 短链接优先，标题中的路径分隔符被替换，重名时追加 ID 前缀
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = true
+	}
+	if !seen["lunch.png"] || !seen["a_b 测试.png"] {
+		t.Errorf("文件名 = %v", names)
+	}
+	if !seen["a_b 测试-"+dup1[:8]+".png"] && !seen["a_b 测试-"+dup2[:8]+".png"] {
+		t.Errorf("重名的标题没有追加 ID 前缀: %v", names)
+	}
+
+	if names := readQRZip(t, "/api/qrcodes.zip?creator_id=alice"); len(names) != 2 {
+		t.Errorf("按创建者过滤后有 %d 个文件，期望 2 个: %v", len(names), names)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultRankWindow 未指定 since 时与多久之前的排名比较
+const defaultRankWindow = 5 * time.Minute
+
+// OptionRank 选项当前排名及与之前相比的变化
+type OptionRank struct {
+	Option       string `json:"option"`
+	Votes        int    `json:"votes"`
+	Rank         int    `json:"rank"`
+	PreviousRank int    `json:"previous_rank"`
+	Delta        int    `json:"delta"` // 正数表示排名上升
+}
+
+// rankOptions 按票数从高到低计算排名，票数相同的选项并列（1, 2, 2, 4）
+func rankOptions(options []string, counts map[string]int) map[string]int {
+	sorted := append([]string(nil), options...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return counts[sorted[i]] > counts[sorted[j]]
+	})
+
+	ranks := make(map[string]int, len(sorted))
+	for i, opt := range sorted {
+		if i > 0 && counts[opt] == counts[sorted[i-1]] {
+			ranks[opt] = ranks[sorted[i-1]]
+		} else {
+			ranks[opt] = i + 1
+		}
+	}
+	return ranks
+}
+
+// RankDeltas 比较各选项当前排名与 since 时刻的排名。之前的票数由初始票数加上 since 之前的投票事件得出。
+// includeHidden 为 false 时隐藏选项不参与排名
+func (ps *PollStore) RankDeltas(id string, since time.Time, includeHidden bool) ([]OptionRank, error) {
+	poll, err := ps.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !includeHidden {
+		withholdHiddenOptions(poll)
+	}
+	options := poll.ResultOptions()
+
+	previous := make(map[string]int, len(poll.Options))
+	rows, err := ps.db.Query(`SELECT option_name, initial_count FROM votes WHERE poll_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		previous[name] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	events, err := ps.db.Query(`
+		SELECT options FROM vote_events WHERE poll_id = ? AND voted_at <= ? AND `+currentRoundEvents+`
+	`, id, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+
+	for events.Next() {
+		var options string
+		if err := events.Scan(&options); err != nil {
+			return nil, err
+		}
+		for _, opt := range strings.Split(options, "|||") {
+			previous[opt]++
+		}
+	}
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	current := rankOptions(options, poll.Votes)
+	before := rankOptions(options, previous)
+
+	result := make([]OptionRank, 0, len(options))
+	for _, opt := range options {
+		result = append(result, OptionRank{
+			Option:       opt,
+			Votes:        poll.Votes[opt],
+			Rank:         current[opt],
+			PreviousRank: before[opt],
+			Delta:        before[opt] - current[opt],
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Rank < result[j].Rank
+	})
+	return result, nil
+}
+
+func apiRanksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// since 可以是时间段（如 10m，表示 10 分钟前）或 RFC 3339 时间
+	since := time.Now().Add(-defaultRankWindow)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid since",
+			})
+			return
+		}
+	}
+
+	pollID := r.PathValue("id")
+	poll, err := store.Get(pollID)
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+	if resultsRestricted(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   poll.restrictedNotice(),
+		})
+		return
+	}
+	if poll.ResultsHidden() && !canPreview(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "results are not public yet",
+		})
+		return
+	}
+
+	ranks, err := store.RankDeltas(pollID, since, isAdmin(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"since":   since.UTC(),
+		"ranks":   ranks,
+	})
+}
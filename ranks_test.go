@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRankOptionsTies(t *testing.T) {
+	got := rankOptions([]string{"a", "b", "c", "d"}, map[string]int{"a": 1, "b": 5, "c": 3, "d": 3})
+	want := map[string]int{"b": 1, "c": 2, "d": 2, "a": 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rankOptions = %v，期望 %v", got, want)
+	}
+}
+
+func TestRankDeltas(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}})
+
+	// 第一段：一小时前 a 领先
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+	if _, err := store.db.Exec(`UPDATE vote_events SET voted_at = ? WHERE poll_id = ?`, time.Now().Add(-time.Hour).UTC(), pollID); err != nil {
+		t.Fatal(err)
+	}
+	// 第二段：c 反超
+	mustVote(t, pollID, "c")
+	mustVote(t, pollID, "c")
+	mustVote(t, pollID, "c")
+	mustVote(t, pollID, "b")
+
+	ranks, err := store.RankDeltas(pollID, time.Now().Add(-30*time.Minute), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OptionRank{
+		{Option: "c", Votes: 3, Rank: 1, PreviousRank: 3, Delta: 2},
+		{Option: "a", Votes: 2, Rank: 2, PreviousRank: 1, Delta: -1},
+		{Option: "b", Votes: 2, Rank: 2, PreviousRank: 2, Delta: 0},
+	}
+	if !reflect.DeepEqual(ranks, want) {
+		t.Errorf("RankDeltas = %+v\n期望 %+v", ranks, want)
+	}
+
+	// since 在所有投票之后时排名没有变化
+	ranks, err = store.RankDeltas(pollID, time.Now().Add(time.Minute), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range ranks {
+		if r.Delta != 0 {
+			t.Errorf("%s: delta = %d，期望 0", r.Option, r.Delta)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RawPoll polls 表中的一行，按 SQLite 实际存储的类型和值返回，不经过 scanPoll 的任何解析
+type RawPoll struct {
+	Columns []string               `json:"columns"`
+	Values  map[string]interface{} `json:"values"`
+	Types   map[string]string      `json:"types"`
+}
+
+// RawPoll 读取投票的原始行（包括已删除的投票），用于排查选项拆分、时间解析等问题。
+// 每列同时取 typeof 和文本形式，避免驱动按声明类型把 DATETIME 等列转换成 time.Time
+func (ps *PollStore) RawPoll(id string) (*RawPoll, error) {
+	rows, err := ps.db.Query(`SELECT name FROM pragma_table_info('polls') ORDER BY cid`)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exprs := make([]string, 0, 2*len(columns))
+	for _, c := range columns {
+		q := `"` + c + `"`
+		exprs = append(exprs, "typeof("+q+")", "CAST("+q+" AS TEXT)")
+	}
+	types := make([]string, len(columns))
+	texts := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, 0, 2*len(columns))
+	for i := range columns {
+		dest = append(dest, &types[i], &texts[i])
+	}
+	err = ps.db.QueryRow(`SELECT `+strings.Join(exprs, ", ")+` FROM polls WHERE id = ?`, id).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := &RawPoll{
+		Columns: columns,
+		Values:  make(map[string]interface{}, len(columns)),
+		Types:   make(map[string]string, len(columns)),
+	}
+	for i, c := range columns {
+		raw.Types[c] = types[i]
+		raw.Values[c] = rawValue(types[i], texts[i])
+	}
+	return raw, nil
+}
+
+// rawValue 按存储类型还原列值：整数和浮点数保持数字，NULL 为 null，其余为原样文本
+func rawValue(typ string, text sql.NullString) interface{} {
+	if !text.Valid {
+		return nil
+	}
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(text.String, 10, 64); err == nil {
+			return n
+		}
+	case "real":
+		if f, err := strconv.ParseFloat(text.String, 64); err == nil {
+			return f
+		}
+	}
+	return text.String
+}
+
+// apiRawPollHandler 管理接口：GET /api/poll/{id}/raw 返回数据库中存储的原始行
+func apiRawPollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	raw, err := store.RawPoll(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 也接受短链接标识
+		if poll, rerr := store.Resolve(id); rerr == nil {
+			raw, err = store.RawPoll(poll.ID)
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"poll":    raw,
+	})
+}
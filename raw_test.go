@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRawPoll(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":        "原始行",
+		"options":      []string{"早上 9 点", "下午 2 点", "晚上"},
+		"multi_select": true,
+		"max_choices":  2,
+		"slug":         "raw-check",
+		"anonymous":    false,
+	})
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/raw", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌状态码 = %d，期望 401", rec.Code)
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/raw", nil, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("读取原始行失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	raw := decodeBody(t, rec)["poll"].(map[string]interface{})
+	values := raw["values"].(map[string]interface{})
+	types := raw["types"].(map[string]interface{})
+
+	for column, want := range map[string]interface{}{
+		"id":           pollID,
+		"title":        "原始行",
+		"options":      "早上 9 点|||下午 2 点|||晚上",
+		"multi_select": float64(1),
+		"max_choices":  float64(2),
+		"anonymous":    float64(0),
+		"slug":         "raw-check",
+		"closed_at":    nil,
+		"deleted_at":   nil,
+	} {
+		if values[column] != want {
+			t.Errorf("%s = %#v，期望 %#v", column, values[column], want)
+		}
+	}
+	for column, want := range map[string]string{"options": "text", "multi_select": "integer", "closed_at": "null", "created_at": "text"} {
+		if types[column] != want {
+			t.Errorf("%s 的存储类型 = %v，期望 %s", column, types[column], want)
+		}
+	}
+	// 时间按存储的文本原样返回，不经过解析
+	if created, _ := values["created_at"].(string); !strings.HasPrefix(created, "20") {
+		t.Errorf("created_at = %#v，期望存储的原始文本", values["created_at"])
+	}
+	if columns, _ := raw["columns"].([]interface{}); len(columns) != len(values) || columns[0] != "id" {
+		t.Errorf("columns = %v", raw["columns"])
+	}
+
+	// 短链接也可以查询，合并后软删除的投票仍能看到原始行
+	if rec := doRequest(t, http.MethodGet, "/api/poll/raw-check/raw", nil, adminHeader...); rec.Code != http.StatusOK {
+		t.Errorf("按短链接查询状态码 = %d，期望 200", rec.Code)
+	}
+	if _, err := store.db.Exec(`UPDATE polls SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, pollID); err != nil {
+		t.Fatal(err)
+	}
+	rec = doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/raw", nil, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("已删除投票的原始行状态码 = %d，期望 200", rec.Code)
+	}
+	if values := decodeBody(t, rec)["poll"].(map[string]interface{})["values"].(map[string]interface{}); values["deleted_at"] == nil {
+		t.Error("删除后 deleted_at 仍为 null")
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/no-such-poll/raw", nil, adminHeader...); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// ResultsView 结果页的模板数据
+type ResultsView struct {
+	*Poll
+	Withheld   bool // 结果暂不公开
+	Restricted bool // 结果不在请求者的可见范围内（result_visibility），同时 Withheld 为 true
+	Preview    bool // 管理员预览未公开的结果
+
+	Rounds []PollRound // 已结束的轮次，只在结果页加载
+}
+
+// ResultsScheduled 是否还未到定时公布结果的时间
+func (p *Poll) ResultsScheduled() bool {
+	return p.ResultsVisibleAt != nil && time.Now().Before(*p.ResultsVisibleAt)
+}
+
+// withheldNotice 结果暂不公开时导出文件中的说明
+func (p *Poll) withheldNotice() string {
+	if p.ResultsScheduled() {
+		return "Results will be published at " + p.ResultsVisibleAt.Local().Format("2006-01-02 15:04") + "."
+	}
+	return "Results are hidden until the poll closes."
+}
+
+// withheldNotice 结果不对请求者公开时导出文件中的说明
+func (v ResultsView) withheldNotice() string {
+	if v.Restricted {
+		return v.restrictedNotice()
+	}
+	return v.Poll.withheldNotice()
+}
+
+// restrictedNotice 结果不在请求者可见范围内时的说明，也用作接口的错误信息
+func (p *Poll) restrictedNotice() string {
+	if p.ResultVisibility == ResultVisibilityOwner {
+		return "results are only visible to the poll owner"
+	}
+	return "results are only visible to people who have voted"
+}
+
+// resultsRestricted 结果是否因 result_visibility 不对该请求者公开：voters 要求请求者已投过票，
+// owner 要求投票管理令牌。管理员和创建者始终可见
+func resultsRestricted(poll *Poll, r *http.Request) bool {
+	switch poll.ResultVisibility {
+	case ResultVisibilityVoters:
+		return !canManage(poll, r) && !requesterVoted(poll.ID, r)
+	case ResultVisibilityOwner:
+		return !canManage(poll, r)
+	}
+	return false
+}
+
+// resultsWithheldFor 请求者此时是否不能看到票数：结果尚未公开（管理员预览除外），或不在可见范围内
+func resultsWithheldFor(poll *Poll, r *http.Request) bool {
+	return (poll.ResultsHidden() && !canPreview(poll, r)) || resultsRestricted(poll, r)
+}
+
+// ResultsHidden 结果是否仍对公众隐藏：设置了结束前隐藏且未结束，或未到定时公布的时间
+func (p *Poll) ResultsHidden() bool {
+	return p.ResultsScheduled() || (p.HideResults && !p.IsClosed())
+}
+
+// canPreview 请求是否为有权预览隐藏结果的预览：?preview=1 且带有管理令牌或该投票的管理令牌
+func canPreview(poll *Poll, r *http.Request) bool {
+	return r.URL.Query().Get("preview") == "1" && canManage(poll, r)
+}
+
+// withholdResults 清除票数，只保留投票配置
+func withholdResults(poll *Poll) {
+	poll.Votes = nil
+}
+
+// orderHiddenOptions 按选项顺序排列隐藏选项（从 votes 表读出时顺序不定）
+func (p *Poll) orderHiddenOptions() {
+	if len(p.HiddenOptions) < 2 {
+		return
+	}
+	ordered := make([]string, 0, len(p.HiddenOptions))
+	for _, opt := range p.Options {
+		if slices.Contains(p.HiddenOptions, opt) {
+			ordered = append(ordered, opt)
+		}
+	}
+	p.HiddenOptions = ordered
+}
+
+// OptionHidden 选项是否设置为不在公开结果中显示
+func (p *Poll) OptionHidden(option string) bool {
+	return slices.Contains(p.HiddenOptions, option)
+}
+
+// withholdHiddenOptions 从票数中去掉隐藏选项。隐藏选项仍可投票，因此选项列表保持不变
+func withholdHiddenOptions(poll *Poll) {
+	if len(poll.HiddenOptions) == 0 {
+		return
+	}
+	poll.hiddenWithheld = true
+	for _, opt := range poll.HiddenOptions {
+		delete(poll.Votes, opt)
+	}
+}
+
+// ResultOptions 结果中展示的选项：公开结果不含隐藏选项
+func (p *Poll) ResultOptions() []string {
+	if !p.hiddenWithheld {
+		return p.Options
+	}
+	visible := make([]string, 0, len(p.Options))
+	for _, opt := range p.Options {
+		if !p.OptionHidden(opt) {
+			visible = append(visible, opt)
+		}
+	}
+	return visible
+}
+
+// percentBase 百分比的分母：按 percent_mode 取投票人数或全部选项的票数之和
+func percentBase(mode string, votes map[string]int, voterCount int) int {
+	if mode != PercentOfSelections {
+		return voterCount
+	}
+	total := 0
+	for _, n := range votes {
+		total += n
+	}
+	return total
+}
+
+// PercentBase 本投票计算百分比时的分母
+func (p *Poll) PercentBase() int {
+	return percentBase(p.PercentMode, p.Votes, p.VoterCount)
+}
+
+// OptionPercent 选项票数占分母的百分比（未取整），分母为 0 时返回 0
+func (p *Poll) OptionPercent(option string) float64 {
+	base := p.PercentBase()
+	if base == 0 {
+		return 0
+	}
+	return float64(p.Votes[option]) * 100 / float64(base)
+}
+
+// votePercentages 各选项票数占 base（见 percentBase）的百分比，保留一位小数
+func votePercentages(votes map[string]int, base int) map[string]float64 {
+	if votes == nil {
+		return nil
+	}
+	percentages := make(map[string]float64, len(votes))
+	for option, count := range votes {
+		if base > 0 {
+			percentages[option] = math.Round(float64(count)*1000/float64(base)) / 10
+		} else {
+			percentages[option] = 0
+		}
+	}
+	return percentages
+}
+
+// participationRate 投票人数占应到人数的百分比（保留一位小数），未设置应到人数时返回 nil。
+// 实际投票人数可能多于应到人数，原始比例不封顶，展示时用 ParticipationDisplay
+func participationRate(voterCount, expected int) *float64 {
+	if expected <= 0 {
+		return nil
+	}
+	rate := math.Round(float64(voterCount)*1000/float64(expected)) / 10
+	return &rate
+}
+
+// ParticipationDisplay 结果页展示的参与率，超过 100% 时按 100% 显示
+func (p *Poll) ParticipationDisplay() float64 {
+	if p.ParticipationRate == nil {
+		return 0
+	}
+	return math.Min(*p.ParticipationRate, 100)
+}
+
+// withholdVoterCount 对外隐去投票人数，改为公开百分比。投票人数和票数仍保留在内存中用于结果页计算。
+// 参与率乘以应到人数、单选投票的票数之和都能算出投票人数，对外一并隐去
+func withholdVoterCount(poll *Poll) {
+	poll.voterCountWithheld = true
+	poll.Percentages = votePercentages(poll.Votes, poll.PercentBase())
+	poll.ParticipationRate = nil
+}
+
+// VoterCountWithheld 本次响应是否隐去了投票人数
+func (p *Poll) VoterCountWithheld() bool {
+	return p.voterCountWithheld
+}
+
+// MarshalJSON 隐去投票人数时 JSON 中不输出 voter_count、votes 和 margin，只保留百分比；
+// 约时间投票附带 best_slot；有票数时附带 margin
+func (p Poll) MarshalJSON() ([]byte, error) {
+	type plain Poll
+	bestSlot := p.BestSlot()
+	margin := p.Margin()
+	if !p.voterCountWithheld && bestSlot == "" && margin == nil {
+		return json.Marshal(plain(p))
+	}
+	out := struct {
+		plain
+		VoterCount *int           `json:"voter_count,omitempty"`
+		Votes      map[string]int `json:"votes,omitempty"`
+		BestSlot   string         `json:"best_slot,omitempty"`
+		Margin     *ResultMargin  `json:"margin,omitempty"`
+	}{plain: plain(p), BestSlot: bestSlot, Margin: margin}
+	if p.voterCountWithheld {
+		out.Margin = nil
+	} else {
+		out.VoterCount = &p.VoterCount
+		out.Votes = p.Votes
+	}
+	return json.Marshal(out)
+}
+
+// redactForPublic 去掉尚不应公开的内容：隐藏的票数、隐藏选项的票数、隐藏的投票人数、未结束投票的结束语。
+// 结果只对部分人可见的投票不公开票数
+func redactForPublic(poll *Poll) {
+	if poll.ResultsHidden() || poll.ResultVisibility != ResultVisibilityPublic {
+		withholdResults(poll)
+	}
+	withholdHiddenOptions(poll)
+	if poll.HideVoterCount {
+		withholdVoterCount(poll)
+	}
+	if !poll.IsClosed() {
+		poll.ClosingMessage = ""
+	}
+}
+
+// newResultsView 根据投票设置和请求者身份决定是否公开结果
+func newResultsView(poll *Poll, r *http.Request) ResultsView {
+	view := ResultsView{Poll: poll}
+	if !poll.IsClosed() {
+		poll.ClosingMessage = ""
+	}
+	if resultsRestricted(poll, r) {
+		view.Withheld = true
+		view.Restricted = true
+		withholdResults(poll)
+	} else if poll.ResultsHidden() {
+		if canPreview(poll, r) {
+			view.Preview = true
+		} else {
+			view.Withheld = true
+			withholdResults(poll)
+		}
+	}
+	if !isAdmin(r) {
+		withholdHiddenOptions(poll)
+	}
+	if poll.HideVoterCount && !isAdmin(r) {
+		withholdVoterCount(poll)
+	}
+	return view
+}
+
+// VoteCounts 轻量的实时票数，供前端轮询
+type VoteCounts struct {
+	VoterCount    *int               `json:"voter_count,omitempty"` // 投票设置了不公开投票人数时省略
+	Percentages   map[string]float64 `json:"percentages,omitempty"`
+	Views         int                `json:"views"`
+	Votes         map[string]int     `json:"votes,omitempty"` // 不公开投票人数时省略
+	Margin        *ResultMargin      `json:"margin,omitempty"`
+	UpdatedAt     time.Time          `json:"updated_at"` // 最近一次投票时间，无投票时为创建时间
+	HasVoted      *bool              `json:"has_voted,omitempty"`
+	Visualization string             `json:"visualization"`
+
+	ExpectedVoters    int      `json:"expected_voters,omitempty"`
+	ParticipationRate *float64 `json:"participation_rate,omitempty"`
+}
+
+// Counts 只读取票数，不加载投票配置
+func (ps *PollStore) Counts(id string) (*VoteCounts, error) {
+	var counts VoteCounts
+	var createdAt time.Time
+	var voterCount int
+	err := ps.db.QueryRow(`SELECT voter_count, views, created_at FROM polls WHERE id = ? AND deleted_at IS NULL`, id).Scan(&voterCount, &counts.Views, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	counts.VoterCount = &voterCount
+
+	counts.Votes = make(map[string]int)
+	rows, err := ps.db.Query(`SELECT option_name, vote_count FROM votes WHERE poll_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var optionName string
+		var voteCount int
+		if err := rows.Scan(&optionName, &voteCount); err != nil {
+			return nil, err
+		}
+		counts.Votes[optionName] = voteCount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var lastVote time.Time
+	err = ps.db.QueryRow(`
+		SELECT voted_at FROM vote_events WHERE poll_id = ? ORDER BY voted_at DESC LIMIT 1
+	`, id).Scan(&lastVote)
+	switch {
+	case err == nil:
+		counts.UpdatedAt = lastVote
+	case errors.Is(err, sql.ErrNoRows):
+		counts.UpdatedAt = createdAt
+	default:
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+func apiCountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pollID := r.PathValue("id")
+	poll, err := store.Get(pollID)
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	counts, err := store.Counts(pollID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if resultsWithheldFor(poll, r) {
+		counts.Votes = nil
+	}
+	if !isAdmin(r) {
+		for _, opt := range poll.HiddenOptions {
+			delete(counts.Votes, opt)
+		}
+	}
+	counts.Margin = resultMargin(poll.Options, counts.Votes, percentBase(poll.PercentMode, counts.Votes, *counts.VoterCount))
+	counts.ExpectedVoters = poll.ExpectedVoters
+	counts.ParticipationRate = participationRate(*counts.VoterCount, poll.ExpectedVoters)
+	if poll.HideVoterCount && !isAdmin(r) {
+		// 单选投票的票数之和就是投票人数，只返回百分比
+		counts.Percentages = votePercentages(counts.Votes, percentBase(poll.PercentMode, counts.Votes, *counts.VoterCount))
+		counts.VoterCount = nil
+		counts.Votes = nil
+		counts.ParticipationRate = nil
+		counts.Margin = nil
+	}
+	counts.HasVoted = viewerHasVoted(r, pollID)
+	counts.Visualization = poll.Visualization
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, counts)
+}
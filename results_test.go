@@ -0,0 +1,453 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviewHiddenResults(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{
+		"title":        "t",
+		"options":      []string{"pizza", "sushi"},
+		"hide_results": true,
+	})
+	mustVote(t, pollID, "pizza")
+	mustVote(t, pollID, "pizza")
+
+	for _, tc := range []struct {
+		name    string
+		query   string
+		headers []string
+		preview bool
+	}{
+		{"匿名", "?preview=1", nil, false},
+		{"错误的管理令牌", "?preview=1", []string{manageTokenHeader, "wrong"}, false},
+		{"管理令牌但未请求预览", "", []string{manageTokenHeader, manageToken}, false},
+		{"投票管理令牌", "?preview=1", []string{manageTokenHeader, manageToken}, true},
+		{"查询参数中的管理令牌", "?preview=1&manage_token=" + manageToken, nil, true},
+		{"管理员", "?preview=1", adminHeader, true},
+	} {
+		rec := doRequest(t, http.MethodGet, "/api/results/"+pollID+tc.query, nil, tc.headers...)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: 状态码 = %d", tc.name, rec.Code)
+		}
+		if got := strings.Contains(rec.Body.String(), "2 票"); got != tc.preview {
+			t.Errorf("%s: 结果页显示票数 = %v，期望 %v", tc.name, got, tc.preview)
+		}
+
+		var votes interface{}
+		if body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts"+tc.query, nil, tc.headers...)); body["votes"] != nil {
+			votes = body["votes"].(map[string]interface{})["pizza"]
+		}
+		if got := votes == float64(2); got != tc.preview {
+			t.Errorf("%s: counts 中 pizza = %v，期望可见 %v", tc.name, votes, tc.preview)
+		}
+	}
+}
+
+func TestCountsEndpoint(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	counts := func() map[string]interface{} {
+		rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("状态码 = %d", rec.Code)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("Cache-Control = %q", cc)
+		}
+		return decodeBody(t, rec)
+	}
+
+	body := counts()
+	for _, key := range []string{"voter_count", "votes", "updated_at"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("缺少字段 %s: %v", key, body)
+		}
+	}
+	// 只返回票数，不带投票配置
+	for _, key := range []string{"title", "options", "multi_select", "webhook_url"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("不应包含字段 %s", key)
+		}
+	}
+	created := body["updated_at"]
+
+	time.Sleep(10 * time.Millisecond)
+	mustVote(t, pollID, "b")
+	mustVote(t, pollID, "b")
+	mustVote(t, pollID, "a")
+	body = counts()
+	votes := body["votes"].(map[string]interface{})
+	if body["voter_count"] != float64(3) || votes["a"] != float64(1) || votes["b"] != float64(2) {
+		t.Errorf("counts = %v", body)
+	}
+	if body["updated_at"] == created {
+		t.Error("投票后 updated_at 没有更新")
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/missing/counts", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestClosingMessage(t *testing.T) {
+	setupTest(t)
+	const atCreate = "感谢参与，结果稍后公布"
+	const atClose = "Thanks, winner is pizza!"
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"pizza", "sushi"}, "closing_message": atCreate})
+	if got := mustGet(t, pollID).ClosingMessage; got != atCreate {
+		t.Fatalf("closing_message = %q，期望 %q", got, atCreate)
+	}
+
+	shown := func(msg string) (page, list bool) {
+		page = strings.Contains(doRequest(t, http.MethodGet, "/api/results/"+pollID, nil).Body.String(), msg)
+		list = strings.Contains(doRequest(t, http.MethodGet, "/api/polls", nil).Body.String(), msg)
+		return page, list
+	}
+	if page, list := shown(atCreate); page || list {
+		t.Errorf("投票进行中公开了结束语：结果页 %v，列表 %v", page, list)
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, map[string]string{"closing_message": atClose}, manageTokenHeader, manageToken)
+	if decodeBody(t, rec)["success"] != true {
+		t.Fatalf("结束投票失败: %s", rec.Body.String())
+	}
+	if got := mustGet(t, pollID).ClosingMessage; got != atClose {
+		t.Errorf("结束时设置的 closing_message = %q，期望 %q", got, atClose)
+	}
+	if page, list := shown(atClose); !page || !list {
+		t.Errorf("投票结束后没有显示结束语：结果页 %v，列表 %v", page, list)
+	}
+
+	// 结束时不带结束语则保留创建时的
+	other, otherToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "closing_message": atCreate})
+	doRequest(t, http.MethodPost, "/api/close-poll/"+other, nil, manageTokenHeader, otherToken)
+	if got := mustGet(t, other).ClosingMessage; got != atCreate {
+		t.Errorf("closing_message = %q，期望保留 %q", got, atCreate)
+	}
+}
+
+func TestHideVoterCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true, "expected_voters": 10})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+
+	// checkWithheld 公开的响应没有投票人数和票数（单选投票的票数之和就是人数），只有百分比
+	checkWithheld := func(name string, result map[string]interface{}) {
+		t.Helper()
+		for _, key := range []string{"voter_count", "votes", "margin", "participation_rate"} {
+			if _, ok := result[key]; ok {
+				t.Errorf("%s: 公开的响应包含 %s: %v", name, key, result[key])
+			}
+		}
+		if percentages, _ := result["percentages"].(map[string]interface{}); percentages["a"] != float64(75) || percentages["b"] != float64(25) {
+			t.Errorf("%s: percentages = %v，期望 a 75、b 25", name, result["percentages"])
+		}
+	}
+
+	checkWithheld("counts", decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil)))
+	list := decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil))
+	checkWithheld("polls", list["polls"].([]interface{})[0].(map[string]interface{}))
+	batch := decodeBody(t, doRequest(t, http.MethodPost, "/api/results/batch", map[string]interface{}{"poll_ids": []string{pollID}}))
+	checkWithheld("results/batch", batch["results"].([]interface{})[0].(map[string]interface{}))
+
+	// 管理员仍能看到投票人数
+	counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil, adminHeader...))
+	if counts["voter_count"] != float64(4) || counts["votes"] == nil {
+		t.Errorf("管理员看到的 counts = %v", counts)
+	}
+	list = decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil, adminHeader...))
+	if poll := list["polls"].([]interface{})[0].(map[string]interface{}); poll["voter_count"] != float64(4) {
+		t.Errorf("管理员看到的 voter_count = %v", poll["voter_count"])
+	}
+}
+
+func TestScheduledResults(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{
+		"title":              "t",
+		"options":            []string{"pizza", "sushi"},
+		"results_visible_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+	mustVote(t, pollID, "pizza")
+	mustVote(t, pollID, "pizza")
+	// 结束投票不会提前公开结果
+	doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, manageTokenHeader, manageToken)
+
+	// visible 检查结果页、票数接口和批量结果是否公开票数
+	visible := func(headers ...string) (page, counts, batch bool) {
+		t.Helper()
+		page = strings.Contains(doRequest(t, http.MethodGet, "/api/results/"+pollID+"?preview=1", nil, headers...).Body.String(), "2 票")
+		if votes, ok := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts?preview=1", nil, headers...))["votes"].(map[string]interface{}); ok {
+			counts = votes["pizza"] == float64(2)
+		}
+		body := decodeBody(t, doRequest(t, http.MethodPost, "/api/results/batch", map[string]interface{}{"poll_ids": []string{pollID}}, headers...))
+		result := body["results"].([]interface{})[0].(map[string]interface{})
+		batch = result["withheld"] != true && result["votes"] != nil
+		return page, counts, batch
+	}
+
+	if page, counts, batch := visible(); page || counts || batch {
+		t.Errorf("公布时间之前公开了结果: 结果页 %v，counts %v，批量 %v", page, counts, batch)
+	}
+	if page, counts, _ := visible(adminHeader...); !page || !counts {
+		t.Errorf("管理员预览: 结果页 %v，counts %v", page, counts)
+	}
+
+	if _, err := store.db.Exec(`UPDATE polls SET results_visible_at = ? WHERE id = ?`, time.Now().Add(-time.Minute).UTC(), pollID); err != nil {
+		t.Fatal(err)
+	}
+	if page, counts, batch := visible(); !page || !counts || !batch {
+		t.Errorf("公布时间之后: 结果页 %v，counts %v，批量 %v", page, counts, batch)
+	}
+}
+
+func TestParticipationRate(t *testing.T) {
+	for _, tc := range []struct {
+		voters, expected int
+		want             float64 // -1 表示不计算参与率
+	}{
+		{37, 50, 74},
+		{1, 3, 33.3},
+		{0, 50, 0},
+		{5, 0, -1},
+		{5, -1, -1},
+		{60, 50, 120},
+	} {
+		got := participationRate(tc.voters, tc.expected)
+		if tc.want < 0 && got != nil || tc.want >= 0 && (got == nil || *got != tc.want) {
+			t.Errorf("participationRate(%d, %d) = %v，期望 %v", tc.voters, tc.expected, got, tc.want)
+		}
+	}
+
+	setupTest(t)
+	over, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "expected_voters": 2})
+	for i := 0; i < 3; i++ {
+		mustVote(t, over, "a")
+	}
+	// 接口返回原始比例，结果页封顶显示 100%
+	if got := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+over+"/counts", nil))["participation_rate"]; got != float64(150) {
+		t.Errorf("counts participation_rate = %v，期望 150", got)
+	}
+	page := doRequest(t, http.MethodGet, "/api/results/"+over, nil).Body.String()
+	if !strings.Contains(page, "3 / 2 人") || !strings.Contains(page, "参与率 100.0%") {
+		t.Error("结果页没有封顶显示参与率 100%")
+	}
+
+	none, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, none, "a")
+	if _, ok := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+none+"/counts", nil))["participation_rate"]; ok {
+		t.Error("未设置应到人数时返回了 participation_rate")
+	}
+	if page := doRequest(t, http.MethodGet, "/api/results/"+none, nil).Body.String(); strings.Contains(page, "参与率") {
+		t.Error("未设置应到人数时结果页显示了参与率")
+	}
+}
+
+func TestPercentMode(t *testing.T) {
+	setupTest(t)
+	for _, tc := range []struct {
+		mode      string
+		wantA     float64
+		wantB     float64
+		wantPage  string
+		modeLabel bool
+	}{
+		// 3 人投票，共 5 次选择：a 3 次，b、c 各 1 次
+		{"", 100, 100.0 / 3, "100.0%", false},
+		{PercentOfVoters, 100, 100.0 / 3, "100.0%", false},
+		{PercentOfSelections, 60, 20, "60.0%", true},
+	} {
+		pollID, _ := createTestPoll(t, map[string]interface{}{
+			"title": "t", "options": []string{"a", "b", "c"},
+			"multi_select": true, "max_choices": 3, "percent_mode": tc.mode,
+		})
+		mustVote(t, pollID, "a", "b")
+		mustVote(t, pollID, "a")
+		mustVote(t, pollID, "a", "c")
+
+		poll := mustGet(t, pollID)
+		wantMode := tc.mode
+		if wantMode == "" {
+			wantMode = PercentOfVoters
+		}
+		if poll.PercentMode != wantMode {
+			t.Errorf("percent_mode = %q，期望 %q", poll.PercentMode, wantMode)
+		}
+		if a, b := poll.OptionPercent("a"), poll.OptionPercent("b"); math.Abs(a-tc.wantA) > 1e-9 || math.Abs(b-tc.wantB) > 1e-9 {
+			t.Errorf("%q: a=%v b=%v，期望 a=%v b=%v", wantMode, a, b, tc.wantA, tc.wantB)
+		}
+
+		list := decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil))
+		for _, p := range list["polls"].([]interface{}) {
+			if p := p.(map[string]interface{}); p["id"] == pollID && p["percent_mode"] != wantMode {
+				t.Errorf("JSON percent_mode = %v，期望 %q", p["percent_mode"], wantMode)
+			}
+		}
+
+		page := doRequest(t, http.MethodGet, "/api/results/"+pollID, nil).Body.String()
+		if !strings.Contains(page, tc.wantPage) {
+			t.Errorf("%q: 结果页缺少 %s", wantMode, tc.wantPage)
+		}
+		if strings.Contains(page, "百分比按选择总数计算") != tc.modeLabel {
+			t.Errorf("%q: 结果页计算方式说明与模式不符", wantMode)
+		}
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "percent_mode": "of_everything",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("无效的 percent_mode 状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestPercentModeWithHiddenVoterCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "multi_select": true, "max_choices": 2,
+		"hide_voter_count": true, "percent_mode": PercentOfSelections,
+	})
+	mustVote(t, pollID, "a", "b")
+	mustVote(t, pollID, "a")
+
+	counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))
+	percentages, _ := counts["percentages"].(map[string]interface{})
+	if math.Abs(percentages["a"].(float64)-66.7) > 0.05 || math.Abs(percentages["b"].(float64)-33.3) > 0.05 {
+		t.Errorf("按选择总数的 percentages = %v，期望 a 66.7、b 33.3", percentages)
+	}
+}
+
+func TestHiddenOptionsCountedButNotShown(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":          "t",
+		"options":        []string{"甲", "乙", "对照组"},
+		"hidden_options": []string{"对照组"},
+	})
+	mustVote(t, pollID, "甲")
+	mustVote(t, pollID, "对照组")
+	mustVote(t, pollID, "对照组")
+
+	// 隐藏选项照常出现在投票页，可以投票并计票
+	if page := doRequest(t, http.MethodGet, "/poll/"+pollID, nil).Body.String(); !strings.Contains(page, `value="对照组"`) {
+		t.Error("投票页缺少隐藏选项")
+	}
+	if poll := mustGet(t, pollID); poll.Votes["对照组"] != 2 || poll.VoterCount != 3 {
+		t.Fatalf("隐藏选项没有计票: votes=%v voter_count=%d", poll.Votes, poll.VoterCount)
+	}
+
+	// 公开的结果和接口不含隐藏选项
+	for _, path := range []string{
+		"/api/results/" + pollID,
+		"/api/poll/" + pollID + "/counts",
+		"/api/poll/" + pollID + "/ranks",
+	} {
+		if body := doRequest(t, http.MethodGet, path, nil).Body.String(); strings.Contains(body, "对照组") {
+			t.Errorf("公开的 %s 包含隐藏选项:\n%s", path, body)
+		}
+	}
+	// 投票列表仍需列出全部选项供投票，只是不含隐藏选项的票数
+	list := decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil))
+	if votes, _ := list["polls"].([]interface{})[0].(map[string]interface{})["votes"].(map[string]interface{}); votes["甲"] != float64(1) || votes["对照组"] != nil {
+		t.Errorf("公开的投票列表 votes = %v，期望只有公开选项", votes)
+	}
+	batch := doRequest(t, http.MethodPost, "/api/results/batch", map[string]interface{}{"poll_ids": []string{pollID}})
+	if strings.Contains(batch.Body.String(), "对照组") {
+		t.Errorf("公开的 results/batch 包含隐藏选项: %s", batch.Body.String())
+	}
+
+	// 管理员预览能看到隐藏选项及其票数
+	if page := doRequest(t, http.MethodGet, "/api/results/"+pollID, nil, adminHeader...).Body.String(); !strings.Contains(page, "对照组") {
+		t.Error("管理员结果页缺少隐藏选项")
+	}
+	counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil, adminHeader...))
+	if votes, _ := counts["votes"].(map[string]interface{}); votes["对照组"] != float64(2) {
+		t.Errorf("管理员看到的 votes = %v，期望对照组 2 票", counts["votes"])
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "hidden_options": []string{"c"},
+	}); rec.Code != http.StatusBadRequest {
+		t.Errorf("隐藏不存在的选项状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestResultVisibility(t *testing.T) {
+	setupTest(t)
+	for _, visibility := range []string{ResultVisibilityPublic, ResultVisibilityVoters, ResultVisibilityOwner} {
+		t.Run(visibility, func(t *testing.T) {
+			pollID, manageToken := createTestPoll(t, map[string]interface{}{
+				"title": "t", "options": []string{"a", "b"}, "access_mode": AccessAllowlist, "result_visibility": visibility,
+			})
+			doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/allowed-voters", map[string]interface{}{
+				"voters": []string{"voted-token", "idle-token"},
+			}, adminHeader...)
+			rec := voteWithToken(t, pollID, "voted-token", "a")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+			}
+			var voterCookie string
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == voterCookieName {
+					voterCookie = c.Name + "=" + c.Value
+				}
+			}
+
+			// 各类请求者：query 附加在地址后，headers 为请求头
+			callers := []struct {
+				name    string
+				query   string
+				headers []string
+				see     bool
+			}{
+				{"公众", "", nil, visibility == ResultVisibilityPublic},
+				{"未投票的访问者", "", []string{"Cookie", voterCookieName + "=someone-else"}, visibility == ResultVisibilityPublic},
+				{"未投票的名单令牌", "?token=idle-token", nil, visibility == ResultVisibilityPublic},
+				{"已投票的访问者", "", []string{"Cookie", voterCookie}, visibility != ResultVisibilityOwner},
+				{"已投票的名单令牌", "?token=voted-token", nil, visibility != ResultVisibilityOwner},
+				{"创建者", "", []string{manageTokenHeader, manageToken}, true},
+				{"管理员", "", adminHeader, true},
+			}
+			for _, c := range callers {
+				counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts"+c.query, nil, c.headers...))
+				if got := counts["votes"] != nil; got != c.see {
+					t.Errorf("%s: counts 含票数 = %v，期望 %v", c.name, got, c.see)
+				}
+
+				page := doRequest(t, http.MethodGet, "/api/results/"+pollID+c.query, nil, c.headers...).Body.String()
+				if got := !strings.Contains(page, "结果仅对"); got != c.see {
+					t.Errorf("%s: 结果页可见 = %v，期望 %v", c.name, got, c.see)
+				}
+
+				batch := decodeBody(t, doRequest(t, http.MethodPost, "/api/results/batch"+c.query, map[string]interface{}{"poll_ids": []string{pollID}}, c.headers...))
+				if got := batch["results"].([]interface{})[0].(map[string]interface{})["votes"] != nil; got != c.see {
+					t.Errorf("%s: results/batch 含票数 = %v，期望 %v", c.name, got, c.see)
+				}
+			}
+
+			// 公开的投票列表只在 public 时含票数
+			list := decodeBody(t, doRequest(t, http.MethodGet, "/api/polls", nil))
+			for _, p := range list["polls"].([]interface{}) {
+				if p := p.(map[string]interface{}); p["id"] == pollID && (p["votes"] != nil) != (visibility == ResultVisibilityPublic) {
+					t.Errorf("投票列表 votes = %v", p["votes"])
+				}
+			}
+		})
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "result_visibility": "friends",
+	}); rec.Code != http.StatusBadRequest {
+		t.Errorf("无效的 result_visibility 状态码 = %d，期望 400", rec.Code)
+	}
+}
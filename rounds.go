@@ -0,0 +1,302 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errRoundPollNotFound 开始新一轮时投票不存在或已删除
+var errRoundPollNotFound = errors.New("poll not found")
+
+// currentRoundEvents 只取投票当前轮次的投票事件，拼接在 vote_events 的 WHERE 条件中
+const currentRoundEvents = `round = (SELECT round FROM polls WHERE polls.id = vote_events.poll_id)`
+
+// PollRound 已结束轮次的存档结果
+type PollRound struct {
+	Round       int                `json:"round"`
+	Votes       map[string]int     `json:"votes,omitempty"`
+	Percentages map[string]float64 `json:"percentages,omitempty"`
+	VoterCount  *int               `json:"voter_count,omitempty"` // 投票设置了不公开投票人数时省略
+	WriteIns    map[string]int     `json:"write_ins,omitempty"`
+	StartedAt   time.Time          `json:"started_at"`
+	EndedAt     time.Time          `json:"ended_at"`
+
+	percentMode string
+}
+
+// PercentBase 存档轮次计算百分比的分母
+func (r PollRound) PercentBase() int {
+	voterCount := 0
+	if r.VoterCount != nil {
+		voterCount = *r.VoterCount
+	}
+	return percentBase(r.percentMode, r.Votes, voterCount)
+}
+
+// NewRound 把当前结果存档为一轮，清零票数后开始下一轮：投票重新开放，投票人可以再投一次。
+// 投票事件保留并标记轮次，重新统计、排名和投票人列表只看当前轮次
+func (ps *PollStore) NewRound(pollID string) (*Poll, error) {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var round, voterCount int
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		SELECT round, voter_count, created_at FROM polls WHERE id = ? AND deleted_at IS NULL
+	`, pollID).Scan(&round, &voterCount, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errRoundPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := queryCounts(tx, `SELECT option_name, vote_count FROM votes WHERE poll_id = ?`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	writeIns, err := queryCounts(tx, `SELECT text, COUNT(*) FROM write_ins WHERE poll_id = ? GROUP BY text`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	votesJSON, err := json.Marshal(votes)
+	if err != nil {
+		return nil, err
+	}
+	writeInsJSON, err := json.Marshal(writeIns)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本轮开始时间：上一轮的结束时间，第一轮为创建时间
+	startedAt := createdAt.UTC()
+	var lastEnded time.Time
+	err = tx.QueryRow(`SELECT ended_at FROM poll_rounds WHERE poll_id = ? ORDER BY round DESC LIMIT 1`, pollID).Scan(&lastEnded)
+	switch {
+	case err == nil:
+		startedAt = lastEnded
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`
+		INSERT INTO poll_rounds (poll_id, round, votes, write_ins, voter_count, started_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, pollID, round, string(votesJSON), string(writeInsJSON), voterCount, startedAt, now); err != nil {
+		return nil, err
+	}
+
+	// 清零票数和迁移进来的初始票数（它们属于第一轮），重新开放投票
+	stmts := []string{
+		`UPDATE polls SET round = round + 1, voter_count = 0, initial_voter_count = 0, closed_at = NULL, first_vote_at = NULL WHERE id = ?`,
+		`UPDATE votes SET vote_count = 0, initial_count = 0 WHERE poll_id = ?`,
+		`UPDATE allowed_voters SET used_at = NULL WHERE poll_id = ?`,
+		`DELETE FROM voters WHERE poll_id = ?`,
+		`DELETE FROM voter_ips WHERE poll_id = ?`,
+		`DELETE FROM write_ins WHERE poll_id = ?`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, pollID); err != nil {
+			return nil, err
+		}
+	}
+	if err := logAudit(tx, pollID, "new_round", strconv.Itoa(round+1)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(pollID)
+}
+
+// queryCounts 读取 名称 -> 数量 形式的统计
+func queryCounts(tx *timedTx, query string, args ...interface{}) (map[string]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, err
+		}
+		counts[name] = n
+	}
+	return counts, rows.Err()
+}
+
+// renameInRoundsTx 把已存档轮次票数中的旧选项名替换为新名称。各轮的投票记录也会改名并保留原轮次，
+// 存档与记录保持一致
+func renameInRoundsTx(tx *timedTx, pollID, oldName, newName string) error {
+	rows, err := tx.Query(`SELECT round, votes FROM poll_rounds WHERE poll_id = ?`, pollID)
+	if err != nil {
+		return err
+	}
+	updates := make(map[int]string)
+	for rows.Next() {
+		var round int
+		var data string
+		if err := rows.Scan(&round, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var votes map[string]int
+		if err := json.Unmarshal([]byte(data), &votes); err != nil {
+			rows.Close()
+			return err
+		}
+		count, ok := votes[oldName]
+		if !ok {
+			continue
+		}
+		delete(votes, oldName)
+		votes[newName] = count
+		renamed, err := json.Marshal(votes)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		updates[round] = string(renamed)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for round, votes := range updates {
+		if _, err := tx.Exec(`UPDATE poll_rounds SET votes = ? WHERE poll_id = ? AND round = ?`, votes, pollID, round); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRounds 按轮次顺序列出已结束的轮次
+func (ps *PollStore) ListRounds(poll *Poll) ([]PollRound, error) {
+	rows, err := ps.db.Query(`
+		SELECT round, votes, write_ins, voter_count, started_at, ended_at
+		FROM poll_rounds WHERE poll_id = ? ORDER BY round
+	`, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rounds := []PollRound{}
+	for rows.Next() {
+		var r PollRound
+		var votes, writeIns string
+		var voterCount int
+		if err := rows.Scan(&r.Round, &votes, &writeIns, &voterCount, &r.StartedAt, &r.EndedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(votes), &r.Votes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(writeIns), &r.WriteIns); err != nil {
+			return nil, err
+		}
+		r.VoterCount = &voterCount
+		r.percentMode = poll.PercentMode
+		rounds = append(rounds, r)
+	}
+	return rounds, rows.Err()
+}
+
+// redactRounds 按与结果页相同的规则处理存档轮次：定时公布前或不在结果可见范围内时不含票数，公开时去掉隐藏选项，
+// 不公开投票人数时只给百分比（单选投票的票数之和就是投票人数）
+func redactRounds(poll *Poll, rounds []PollRound, r *http.Request) {
+	for i := range rounds {
+		round := &rounds[i]
+		if (poll.ResultsScheduled() && !canPreview(poll, r)) || resultsRestricted(poll, r) {
+			round.Votes = nil
+			round.WriteIns = nil
+			round.VoterCount = nil
+			continue
+		}
+		if !isAdmin(r) {
+			for _, opt := range poll.HiddenOptions {
+				delete(round.Votes, opt)
+			}
+			if poll.HideVoterCount {
+				round.Percentages = votePercentages(round.Votes, round.PercentBase())
+				round.VoterCount = nil
+				round.Votes = nil
+			}
+		}
+	}
+}
+
+// apiNewRoundHandler 管理接口：POST /api/poll/{id}/new-round 存档本轮结果并开始下一轮
+func apiNewRoundHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	poll, err := store.NewRound(r.PathValue("id"))
+	if errors.Is(err, errRoundPollNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"round":   poll.Round,
+	})
+}
+
+// apiRoundsHandler 轮次历史：GET /api/poll/{id}/rounds 返回当前轮次和已结束轮次的结果
+func apiRoundsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	rounds, err := store.ListRounds(poll)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redactRounds(poll, rounds, r)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"round":   poll.Round,
+		"rounds":  rounds,
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRound 以管理员身份开始新一轮
+func newRound(t *testing.T, pollID string) {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/new-round", nil, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("开始新一轮失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// getRounds 读取轮次历史
+func getRounds(t *testing.T, pollID string) (int, []PollRound) {
+	t.Helper()
+	var body struct {
+		Round  int         `json:"round"`
+		Rounds []PollRound `json:"rounds"`
+	}
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/rounds", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("读取轮次状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("响应不是 JSON: %v\n%s", err, rec.Body.String())
+	}
+	return body.Round, body.Rounds
+}
+
+func TestNewRoundArchivesAndResets(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "allow_write_ins": true})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+	voteWriteIn(t, pollID, "c")
+	doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil)
+
+	if rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/new-round", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌状态码 = %d，期望 401", rec.Code)
+	}
+	newRound(t, pollID)
+
+	// 当前轮次清零并重新开放
+	poll := mustGet(t, pollID)
+	if poll.Round != 2 || poll.VoterCount != 0 || poll.Votes["a"] != 0 || poll.Votes["b"] != 0 || poll.IsClosed() {
+		t.Fatalf("新一轮 round=%d voter_count=%d votes=%v closed=%v", poll.Round, poll.VoterCount, poll.Votes, poll.IsClosed())
+	}
+	if writeIns, err := store.WriteInCounts(pollID); err != nil || len(writeIns) != 0 {
+		t.Errorf("新一轮仍有自填答案: %v %v", writeIns, err)
+	}
+
+	// 第一轮存档可以查询
+	round, rounds := getRounds(t, pollID)
+	if round != 2 || len(rounds) != 1 {
+		t.Fatalf("round=%d rounds=%+v", round, rounds)
+	}
+	first := rounds[0]
+	if first.Round != 1 || first.VoterCount == nil || *first.VoterCount != 4 ||
+		!reflect.DeepEqual(first.Votes, map[string]int{"a": 2, "b": 1}) || !reflect.DeepEqual(first.WriteIns, map[string]int{"c": 1}) {
+		t.Errorf("第一轮存档 = %+v", first)
+	}
+
+	// 第二轮的票数和重新计票只计本轮，结束后第二轮从第一轮结束时开始
+	mustVote(t, pollID, "b")
+	if recounted, err := store.Recount(pollID); err != nil || recounted.Votes["a"] != 0 || recounted.Votes["b"] != 1 {
+		t.Errorf("第二轮重新计票 = %v, %v", recounted, err)
+	}
+	newRound(t, pollID)
+	round, rounds = getRounds(t, pollID)
+	if round != 3 || len(rounds) != 2 || rounds[1].Round != 2 || rounds[1].Votes["b"] != 1 || rounds[1].Votes["a"] != 0 {
+		t.Fatalf("round=%d rounds=%+v", round, rounds)
+	}
+	if !rounds[1].StartedAt.Equal(rounds[0].EndedAt) {
+		t.Errorf("第二轮开始于 %v，期望第一轮结束时间 %v", rounds[1].StartedAt, rounds[0].EndedAt)
+	}
+	if rounds[0].EndedAt.After(time.Now()) || rounds[0].StartedAt.After(rounds[0].EndedAt) {
+		t.Errorf("第一轮时间不对: %v - %v", rounds[0].StartedAt, rounds[0].EndedAt)
+	}
+
+	// 结果页显示历史轮次
+	if page := doRequest(t, http.MethodGet, "/api/results/"+pollID, nil).Body.String(); !strings.Contains(page, "第 1 轮") || !strings.Contains(page, "第 2 轮") {
+		t.Error("结果页没有显示历史轮次")
+	}
+
+	if rec := doRequest(t, http.MethodPost, "/api/poll/no-such-poll/new-round", nil, adminHeader...); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestNewRoundAllowsVotingAgain(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "ip_limit": true})
+	mustVote(t, pollID, "a")
+	if rec := castVote(t, pollID, "b"); rec.Code != http.StatusForbidden {
+		t.Fatalf("同一 IP 再次投票状态码 = %d，期望 403", rec.Code)
+	}
+	newRound(t, pollID)
+	mustVote(t, pollID, "b")
+	if poll := mustGet(t, pollID); poll.VoterCount != 1 || poll.Votes["b"] != 1 {
+		t.Errorf("第二轮 voter_count=%d votes=%v", poll.VoterCount, poll.Votes)
+	}
+}
+
+func TestRoundsHiddenVoterCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+	newRound(t, pollID)
+
+	// 存档轮次同样只给百分比
+	_, rounds := getRounds(t, pollID)
+	if len(rounds) != 1 || rounds[0].Votes != nil || rounds[0].VoterCount != nil || rounds[0].Percentages["a"] == 0 {
+		t.Fatalf("rounds = %+v", rounds)
+	}
+	page := doRequest(t, http.MethodGet, "/api/results/"+pollID, nil).Body.String()
+	if strings.Contains(page, "2 票") || !strings.Contains(page, "%</span></li>") {
+		t.Errorf("结果页的存档轮次应只显示百分比:\n%s", page)
+	}
+}
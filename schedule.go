@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 投票类型
+const (
+	KindPoll     = "poll"     // 普通投票
+	KindSchedule = "schedule" // 约时间：每个选项是一个时间段，投票人勾选自己有空的时间
+)
+
+// slotLayouts 时间段起止时间接受的格式，必须带时区
+var slotLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00"}
+
+// TimeSlot 约时间投票的一个选项，End 为零值表示只给出了开始时间
+type TimeSlot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// parseSlot 解析 "开始" 或 "开始/结束" 形式的时间段，如 2026-06-01T10:00+08:00/2026-06-01T11:00+08:00
+func parseSlot(s string) (TimeSlot, error) {
+	startStr, endStr, hasEnd := strings.Cut(strings.TrimSpace(s), "/")
+	var slot TimeSlot
+	var err error
+	if slot.Start, err = parseSlotTime(startStr); err != nil {
+		return TimeSlot{}, err
+	}
+	if hasEnd {
+		if slot.End, err = parseSlotTime(endStr); err != nil {
+			return TimeSlot{}, err
+		}
+		if !slot.End.After(slot.Start) {
+			return TimeSlot{}, fmt.Errorf("slot must end after it starts")
+		}
+	}
+	return slot, nil
+}
+
+func parseSlotTime(s string) (time.Time, error) {
+	for _, layout := range slotLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 such as 2026-06-01T10:00+08:00", s)
+}
+
+// validateSlots 约时间投票的每个选项都必须是时间段，且不能有相同的时间段（写法不同也算重复）
+func (req *CreatePollRequest) validateSlots(errs *ValidationErrors) {
+	if !req.MultiSelect {
+		errs.Add("multi_select", "kind %q requires multi_select", KindSchedule)
+	}
+	seen := make(map[TimeSlot]int, len(req.Options))
+	for i, opt := range req.Options {
+		slot, err := parseSlot(opt)
+		if err != nil {
+			errs.Add(fmt.Sprintf("options[%d]", i), "%v", err)
+			continue
+		}
+		key := TimeSlot{Start: slot.Start.UTC(), End: slot.End.UTC()}
+		if j, ok := seen[key]; ok {
+			errs.Add(fmt.Sprintf("options[%d]", i), "same time slot as options[%d]", j)
+			continue
+		}
+		seen[key] = i
+	}
+}
+
+// BestSlot 约时间投票中有空人数最多的时间段，人数相同时取最早的。
+// 不是约时间投票、票数未公开或还没有人投票时返回空串
+func (p *Poll) BestSlot() string {
+	if p.Kind != KindSchedule || p.Votes == nil {
+		return ""
+	}
+	best, bestVotes := "", 0
+	var bestStart time.Time
+	for _, opt := range p.Options {
+		n := p.Votes[opt]
+		if n == 0 {
+			continue
+		}
+		slot, err := parseSlot(opt)
+		if err != nil {
+			continue
+		}
+		if n > bestVotes || (n == bestVotes && slot.Start.Before(bestStart)) {
+			best, bestVotes, bestStart = opt, n, slot.Start
+		}
+	}
+	return best
+}
+
+// Availability 约时间投票中每个实名投票人勾选的时间段
+type Availability struct {
+	Voter     string `json:"voter"`
+	Available []bool `json:"available"` // 与 slots 一一对应
+}
+
+// AvailabilityGrid 按投票人列出有空的时间段。同一投票人投过多次时以最后一次为准
+func (ps *PollStore) AvailabilityGrid(poll *Poll) ([]Availability, error) {
+	rows, err := ps.db.Query(`
+		SELECT voter, options FROM vote_events WHERE poll_id = ? AND voter != '' AND `+currentRoundEvents+` ORDER BY voted_at, id
+	`, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(poll.Options))
+	for i, opt := range poll.Options {
+		index[opt] = i
+	}
+	grid := []Availability{}
+	byVoter := make(map[string]int)
+	for rows.Next() {
+		var voter, options string
+		if err := rows.Scan(&voter, &options); err != nil {
+			return nil, err
+		}
+		available := make([]bool, len(poll.Options))
+		if options != "" {
+			for _, opt := range strings.Split(options, "|||") {
+				if i, ok := index[opt]; ok {
+					available[i] = true
+				}
+			}
+		}
+		if i, ok := byVoter[voter]; ok {
+			grid[i].Available = available
+			continue
+		}
+		byVoter[voter] = len(grid)
+		grid = append(grid, Availability{Voter: voter, Available: available})
+	}
+	return grid, rows.Err()
+}
+
+// apiAvailabilityHandler 约时间投票的"谁在什么时间有空"表格，只用于实名投票，
+// 结果隐藏时与动态列表一样不公开所选时间
+func apiAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if poll.Kind != KindSchedule {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "poll is not a schedule",
+		})
+		return
+	}
+	if poll.Anonymous {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "poll is anonymous",
+		})
+		return
+	}
+	if resultsRestricted(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   poll.restrictedNotice(),
+		})
+		return
+	}
+	if poll.ResultsHidden() && !canPreview(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "results are not public yet",
+		})
+		return
+	}
+
+	// 表格按投票人逐行列出，行数就是投票人数，不公开投票人数时只对管理员开放
+	if poll.HideVoterCount && !isAdmin(r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "voter count is hidden for this poll",
+		})
+		return
+	}
+
+	grid, err := store.AvailabilityGrid(poll)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"slots":     poll.Options,
+		"voters":    grid,
+		"best_slot": poll.BestSlot(),
+	})
+}
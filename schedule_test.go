@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	slotMorning   = "2026-06-01T09:00+08:00/2026-06-01T10:00+08:00"
+	slotNoon      = "2026-06-01T12:00+08:00/2026-06-01T13:00+08:00"
+	slotAfternoon = "2026-06-01T15:00+08:00"
+)
+
+func TestParseSlot(t *testing.T) {
+	for _, s := range []string{slotMorning, slotAfternoon, "2026-06-01T10:00:00Z", " 2026-06-01T10:00-05:00/2026-06-01T11:00-05:00 "} {
+		if _, err := parseSlot(s); err != nil {
+			t.Errorf("parseSlot(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"明天上午",
+		"2026-06-01T10:00",        // 没有时区
+		"2026-06-01T10:00+08:00/", // 缺少结束时间
+		"2026-06-01T10:00+08:00/2026-06-01T09:00+08:00", // 结束早于开始
+		"2026-06-01T10:00+08:00/2026-06-01T10:00+08:00", // 起止相同
+	} {
+		if _, err := parseSlot(s); err == nil {
+			t.Errorf("parseSlot(%q) 应返回错误", s)
+		}
+	}
+}
+
+func TestBestSlot(t *testing.T) {
+	poll := &Poll{Kind: KindSchedule, Options: []string{slotAfternoon, slotNoon, slotMorning}}
+	if got := poll.BestSlot(); got != "" {
+		t.Errorf("没有票数时 BestSlot = %q", got)
+	}
+	poll.Votes = map[string]int{}
+	if got := poll.BestSlot(); got != "" {
+		t.Errorf("无人投票时 BestSlot = %q", got)
+	}
+	poll.Votes = map[string]int{slotAfternoon: 2, slotNoon: 3, slotMorning: 1}
+	if got := poll.BestSlot(); got != slotNoon {
+		t.Errorf("BestSlot = %q，期望人数最多的 %q", got, slotNoon)
+	}
+	// 人数相同时取最早的时间段，与选项顺序无关
+	poll.Votes[slotMorning] = 3
+	if got := poll.BestSlot(); got != slotMorning {
+		t.Errorf("人数相同时 BestSlot = %q，期望最早的 %q", got, slotMorning)
+	}
+	poll.Kind = KindPoll
+	if got := poll.BestSlot(); got != "" {
+		t.Errorf("普通投票 BestSlot = %q", got)
+	}
+}
+
+func TestScheduleValidation(t *testing.T) {
+	setupTest(t)
+	for name, tc := range map[string]struct {
+		req   map[string]interface{}
+		field string
+	}{
+		"无效时间":  {map[string]interface{}{"options": []string{slotMorning, "明天上午"}, "multi_select": true}, "options[1]"},
+		"重复时间段": {map[string]interface{}{"options": []string{"2026-06-01T15:00+08:00", "2026-06-01T07:00Z"}, "multi_select": true}, "options[1]"},
+		"单选":    {map[string]interface{}{"options": []string{slotMorning, slotNoon}}, "multi_select"},
+	} {
+		tc.req["title"] = "约时间"
+		tc.req["kind"] = KindSchedule
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", tc.req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"`+tc.field+`"`) {
+			t.Errorf("%s（%d）: %s，期望 %s 字段错误", name, rec.Code, rec.Body.String(), tc.field)
+		}
+	}
+	if rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "kind": "survey"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("未知的 kind 状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestAvailabilityGrid(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "约时间", "kind": KindSchedule, "multi_select": true, "anonymous": false,
+		"options": []string{slotMorning, slotNoon, slotAfternoon},
+	})
+	for name, slots := range map[string][]string{
+		"alice": {slotMorning, slotNoon},
+		"bob":   {slotNoon},
+		"carol": {slotNoon, slotAfternoon},
+	} {
+		if rec := voteAs(t, pollID, name, slots...); rec.Code != http.StatusOK {
+			t.Fatalf("%s 投票失败（%d）: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/availability", nil)
+	var resp struct {
+		Slots    []string       `json:"slots"`
+		Voters   []Availability `json:"voters"`
+		BestSlot string         `json:"best_slot"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("availability（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if resp.BestSlot != slotNoon || len(resp.Voters) != 3 {
+		t.Errorf("best_slot = %q，%d 位投票人", resp.BestSlot, len(resp.Voters))
+	}
+	want := map[string][]bool{
+		"alice": {true, true, false},
+		"bob":   {false, true, false},
+		"carol": {false, true, true},
+	}
+	for _, v := range resp.Voters {
+		if !reflect.DeepEqual(v.Available, want[v.Voter]) {
+			t.Errorf("%s 有空的时间 = %v，期望 %v", v.Voter, v.Available, want[v.Voter])
+		}
+	}
+
+	normal, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+normal+"/availability", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("普通投票状态码 = %d，期望 400", rec.Code)
+	}
+	anonymous, _ := createTestPoll(t, map[string]interface{}{"title": "t", "kind": KindSchedule, "multi_select": true, "options": []string{slotMorning, slotNoon}})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+anonymous+"/availability", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("匿名投票状态码 = %d，期望 403", rec.Code)
+	}
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "t", "kind": KindSchedule, "multi_select": true, "anonymous": false, "hide_voter_count": true, "options": []string{slotMorning, slotNoon}})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+hidden+"/availability", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("不公开投票人数时状态码 = %d，期望 403", rec.Code)
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+hidden+"/availability", nil, adminHeader...); rec.Code != http.StatusOK {
+		t.Errorf("管理员查看不公开投票人数的投票状态码 = %d，期望 200", rec.Code)
+	}
+	voteAs(t, hidden, "alice", slotNoon)
+	if page := doRequest(t, http.MethodGet, "/api/results/"+hidden, nil).Body.String(); strings.Contains(page, "1 人）") {
+		t.Error("不公开投票人数时结果页显示了最多人有空的时间的人数")
+	}
+}
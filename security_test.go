@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSecure 经安全响应头、gzip 中间件和完整路由处理请求，与 main 中的顺序相同
+func serveSecure(req *http.Request, csp string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	securityHeadersMiddleware(gzipMiddleware(routes(), cfg.GzipMinSize), csp, 365*24*time.Hour).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSecurityHeadersOnIndex(t *testing.T) {
+	setupTest(t)
+	rec := serveSecure(httptest.NewRequest(http.MethodGet, "/", nil), defaultCSP)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("首页状态码 = %d", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"Referrer-Policy":         referrerPolicy,
+		"Content-Security-Policy": defaultCSP,
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q，期望 %q", name, got, want)
+		}
+	}
+	// 明文 HTTP 不发送 HSTS
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HTTP 请求发送了 Strict-Transport-Security: %q", got)
+	}
+	// 现有页面使用内联脚本和 blob: 图片，CSP 需要允许
+	if !strings.Contains(defaultCSP, "script-src 'self' 'unsafe-inline'") || !strings.Contains(defaultCSP, "blob:") {
+		t.Errorf("默认 CSP 不能满足现有模板: %s", defaultCSP)
+	}
+
+	// HTTPS 访问时发送 HSTS
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	if got := serveSecure(req, defaultCSP).Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("HTTPS Strict-Transport-Security = %q", got)
+	}
+	// 可信代理声明的 HTTPS 同样发送，不可信来源的声明不采信
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if got := serveSecure(req, defaultCSP).Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("不可信来源的 X-Forwarded-Proto 触发了 HSTS: %q", got)
+	}
+	proxies, err := parseCIDRs("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TrustedProxies = proxies
+	if got := serveSecure(req, defaultCSP).Header().Get("Strict-Transport-Security"); got == "" {
+		t.Error("可信代理声明 HTTPS 时没有发送 HSTS")
+	}
+
+	// CSP 可以关闭
+	if got := serveSecure(httptest.NewRequest(http.MethodGet, "/", nil), "").Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("关闭 CSP 后仍发送: %q", got)
+	}
+}
+
+func TestSecurityHeadersOnlyOnHTML(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	for _, path := range []string{"/api/poll/" + pollID + "/counts", "/qrcode/" + pollID} {
+		if got := serveSecure(httptest.NewRequest(http.MethodGet, path, nil), defaultCSP).Header().Get("Content-Security-Policy"); got != "" {
+			t.Errorf("%s 发送了 CSP: %q", path, got)
+		}
+	}
+	if got := serveSecure(httptest.NewRequest(http.MethodGet, "/poll/"+pollID, nil), defaultCSP).Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("投票页 X-Content-Type-Options = %q", got)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 分享卡片尺寸，适合社交平台的链接预览（1.91:1）
+const (
+	shareWidth     = 1200
+	shareHeight    = 630
+	shareQRSize    = 360
+	shareMargin    = 60
+	shareTitleSize = 48
+	shareURLSize   = 28
+	shareMaxLines  = 2
+)
+
+// shareFont 分享卡片使用的字体：优先使用 -pdf-font（支持中文），否则使用内置的 Go 字体（只含西文字符）
+var shareFont = sync.OnceValue(func() *opentype.Font {
+	if b := pdfFontBytes(); b != nil {
+		f, err := opentype.Parse(b)
+		if err == nil {
+			return f
+		}
+		log.Printf("解析字体 %s 失败，分享图片使用内置字体: %v", cfg.PDFFont, err)
+	}
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		panic(err)
+	}
+	return f
+})
+
+// shareFace 指定字号的字体
+func shareFace(size float64) (font.Face, error) {
+	return opentype.NewFace(shareFont(), &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// wrapText 按宽度折行，超过 maxLines 行时截断并在末尾加省略号
+func wrapText(face font.Face, text string, width int, maxLines int) []string {
+	limit := fixed.I(width)
+	var lines []string
+	line := []rune{}
+	for _, r := range text {
+		if r == '\n' {
+			r = ' '
+		}
+		if font.MeasureString(face, string(append(line, r))) > limit && len(line) > 0 {
+			// 西文尽量在空格处断行，中文可在任意字符处断行
+			rest := []rune{}
+			if i := lastIndexRune(line, ' '); i > 0 && r != ' ' {
+				rest = append(rest, line[i+1:]...)
+				line = line[:i]
+			}
+			lines = append(lines, string(line))
+			line = append([]rune{}, rest...)
+			if len(lines) == maxLines {
+				last := []rune(lines[maxLines-1])
+				for len(last) > 0 && font.MeasureString(face, string(last)+"…") > limit {
+					last = last[:len(last)-1]
+				}
+				lines[maxLines-1] = string(last) + "…"
+				return lines
+			}
+		}
+		line = append(line, r)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// lastIndexRune 最后一个 r 的位置，没有时返回 -1
+func lastIndexRune(s []rune, r rune) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawCentered 在 y 基线处水平居中绘制一行文字
+func drawCentered(dst draw.Image, face font.Face, text string, y int, c color.Color) {
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face}
+	width := d.MeasureString(text)
+	d.Dot = fixed.Point26_6{X: (fixed.I(shareWidth) - width) / 2, Y: fixed.I(y)}
+	d.DrawString(text)
+}
+
+// newShareImage 生成分享卡片：顶部标题（最多两行），中间二维码，底部投票链接
+func newShareImage(title, url string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, shareWidth, shareHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	titleFace, err := shareFace(shareTitleSize)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	urlFace, err := shareFace(shareURLSize)
+	if err != nil {
+		return nil, err
+	}
+	defer urlFace.Close()
+
+	lineHeight := titleFace.Metrics().Height.Ceil()
+	y := shareMargin + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(titleFace, title, shareWidth-2*shareMargin, shareMaxLines) {
+		drawCentered(img, titleFace, line, y, color.RGBA{0x33, 0x33, 0x33, 0xff})
+		y += lineHeight
+	}
+
+	qr, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	qrTop := shareMargin + shareMaxLines*lineHeight
+	qrRect := image.Rect((shareWidth-shareQRSize)/2, qrTop, (shareWidth+shareQRSize)/2, qrTop+shareQRSize)
+	draw.Draw(img, qrRect, qr.Image(shareQRSize), image.Point{}, draw.Src)
+
+	urlLines := wrapText(urlFace, url, shareWidth-2*shareMargin, 1)
+	drawCentered(img, urlFace, urlLines[0], shareHeight-shareMargin+urlFace.Metrics().Ascent.Ceil()/2, color.RGBA{0x66, 0x7e, 0xea, 0xff})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// apiShareImageHandler 返回投票的分享卡片 PNG，链接优先使用短链接
+func apiShareImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	ref := poll.ID
+	if poll.Slug != "" {
+		ref = poll.Slug
+	}
+	img, err := newShareImage(poll.Title, cfg.BaseURL+"/poll/"+ref)
+	if err != nil {
+		http.Error(w, "Failed to generate share image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(img)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestShareImage(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": strings.Repeat("A very long poll title ", 20), "options": []string{"a", "b"}})
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/share.png", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("状态码 = %d，Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("不是有效的 PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != shareWidth || b.Dy() != shareHeight {
+		t.Errorf("图片尺寸 = %dx%d，期望 %dx%d", b.Dx(), b.Dy(), shareWidth, shareHeight)
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/missing/share.png", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestWrapTextTruncatesLongTitles(t *testing.T) {
+	face, err := shareFace(shareTitleSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const width = 400
+	lines := wrapText(face, strings.Repeat("word ", 100), width, 2)
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "…") {
+		t.Fatalf("lines = %q，期望截断为 2 行并以省略号结尾", lines)
+	}
+	for _, line := range lines {
+		if font.MeasureString(face, line) > fixed.I(width) {
+			t.Errorf("%q 超出宽度", line)
+		}
+	}
+	if lines := wrapText(face, "short", width, 2); len(lines) != 1 || lines[0] != "short" {
+		t.Errorf("短标题 = %q", lines)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/heap"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// signingKey 返回签发令牌用的密钥；未配置时在首次使用时随机生成（重启后旧令牌失效）
+var signingKey = sync.OnceValue(func() []byte {
+	if cfg.SecretKey != "" {
+		return []byte(cfg.SecretKey)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+})
+
+// signString 计算 data 的 HMAC-SHA256 签名（十六进制）
+func signString(data string) string {
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyString 校验 data 的签名
+func verifyString(data, signature string) bool {
+	return hmac.Equal([]byte(signString(data)), []byte(signature))
+}
+
+// randomHex 生成 n 字节的随机十六进制串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// replayGuard 记录已使用过的一次性令牌。过期时间另存一个最小堆，每次写入只清理堆顶已过期的条目，
+// 不必遍历全部令牌
+type replayGuard struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	expires expiryHeap
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{seen: make(map[string]time.Time)}
+}
+
+// Release 撤销 Use 的标记，令牌可以再次使用。用于先占用令牌、后续操作失败的情况
+func (g *replayGuard) Release(token string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.seen, token)
+}
+
+// Use 标记令牌已使用，令牌此前已被使用时返回 false；expires 之后条目可被清理
+func (g *replayGuard) Use(token string, expires time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(time.Now())
+	if _, ok := g.seen[token]; ok {
+		return false
+	}
+	g.seen[token] = expires
+	heap.Push(&g.expires, expiryEntry{token: token, expires: expires})
+	return true
+}
+
+// prune 删除已过期的条目。Release 后又重新使用的令牌在堆中留有旧条目，过期时间不一致的不删除
+func (g *replayGuard) prune(now time.Time) {
+	for g.expires.Len() > 0 && now.After(g.expires[0].expires) {
+		e := heap.Pop(&g.expires).(expiryEntry)
+		if exp, ok := g.seen[e.token]; ok && exp.Equal(e.expires) {
+			delete(g.seen, e.token)
+		}
+	}
+}
+
+type expiryEntry struct {
+	token   string
+	expires time.Time
+}
+
+// expiryHeap 按过期时间排列的最小堆，实现 heap.Interface
+type expiryHeap []expiryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryEntry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
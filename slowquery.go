@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// timedDB 在 *sql.DB 的查询方法外计时，超过 -slow-query-threshold 的查询记录日志。
+// 未设置阈值时直接调用，不读取时间。Begin 返回的事务同样计时
+type timedDB struct {
+	*sql.DB
+}
+
+// timedTx 计时的事务，事务中的每条语句单独计时
+type timedTx struct {
+	*sql.Tx
+}
+
+// Begin 开始一个计时的事务
+func (db *timedDB) Begin() (*timedTx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &timedTx{tx}, nil
+}
+
+func (db *timedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if cfg.SlowQueryThreshold <= 0 {
+		return db.DB.Query(query, args...)
+	}
+	start := time.Now()
+	rows, err := db.DB.Query(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return rows, err
+}
+
+// QueryRow 与 Query 一样只计到取得第一行：SQLite 在此之前完成排序、聚合等主要工作
+func (db *timedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	if cfg.SlowQueryThreshold <= 0 {
+		return db.DB.QueryRow(query, args...)
+	}
+	start := time.Now()
+	row := db.DB.QueryRow(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return row
+}
+
+func (db *timedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if cfg.SlowQueryThreshold <= 0 {
+		return db.DB.Exec(query, args...)
+	}
+	start := time.Now()
+	res, err := db.DB.Exec(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return res, err
+}
+
+func (tx *timedTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if cfg.SlowQueryThreshold <= 0 {
+		return tx.Tx.Query(query, args...)
+	}
+	start := time.Now()
+	rows, err := tx.Tx.Query(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return rows, err
+}
+
+func (tx *timedTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	if cfg.SlowQueryThreshold <= 0 {
+		return tx.Tx.QueryRow(query, args...)
+	}
+	start := time.Now()
+	row := tx.Tx.QueryRow(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return row
+}
+
+func (tx *timedTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if cfg.SlowQueryThreshold <= 0 {
+		return tx.Tx.Exec(query, args...)
+	}
+	start := time.Now()
+	res, err := tx.Tx.Exec(query, args...)
+	logSlowQuery(query, time.Since(start))
+	return res, err
+}
+
+// logSlowQuery 耗时超过阈值时记录日志，label 为发起查询的 PollStore 方法，只在需要记录时才查找
+func logSlowQuery(query string, elapsed time.Duration) {
+	if elapsed < cfg.SlowQueryThreshold {
+		return
+	}
+	slog.Warn("slow query",
+		"label", queryCaller(),
+		"duration", elapsed,
+		"query", strings.Join(strings.Fields(query), " "),
+	)
+}
+
+// queryCaller 调用 timedDB、timedTx 方法的函数名，如 (*PollStore).Counts
+func queryCaller() string {
+	// 跳过 runtime.Callers、queryCaller、logSlowQuery 和 timedDB、timedTx 的方法
+	pc := make([]uintptr, 1)
+	if runtime.Callers(4, pc) == 0 {
+		return "unknown"
+	}
+	frame, _ := runtime.CallersFrames(pc).Next()
+	// 去掉包路径：可执行文件中为 main.，测试中为模块路径 toupiao.
+	name := frame.Function
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
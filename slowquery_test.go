@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sleepDriver 每条语句先睡眠 delay 再返回的假数据库驱动，查询返回一行 n = 1
+type sleepDriver struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (d *sleepDriver) Open(string) (driver.Conn, error) { return sleepConn{d}, nil }
+
+func (d *sleepDriver) sleep() {
+	d.mu.Lock()
+	delay := d.delay
+	d.mu.Unlock()
+	time.Sleep(delay)
+}
+
+type sleepConn struct{ d *sleepDriver }
+
+func (c sleepConn) Prepare(string) (driver.Stmt, error) { return sleepStmt{c.d}, nil }
+func (c sleepConn) Close() error                        { return nil }
+func (c sleepConn) Begin() (driver.Tx, error)           { return sleepTx{}, nil }
+
+type sleepTx struct{}
+
+func (sleepTx) Commit() error   { return nil }
+func (sleepTx) Rollback() error { return nil }
+
+type sleepStmt struct{ d *sleepDriver }
+
+func (s sleepStmt) Close() error  { return nil }
+func (s sleepStmt) NumInput() int { return -1 }
+func (s sleepStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.d.sleep()
+	return driver.RowsAffected(1), nil
+}
+func (s sleepStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.sleep()
+	return &sleepRows{}, nil
+}
+
+type sleepRows struct{ done bool }
+
+func (r *sleepRows) Columns() []string { return []string{"n"} }
+func (r *sleepRows) Close() error      { return nil }
+func (r *sleepRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+var sleepDB = &sleepDriver{}
+
+func init() {
+	sql.Register("sleepdb", sleepDB)
+}
+
+// captureSlog 把默认 slog 的输出收集到缓冲区，测试结束后恢复
+func captureSlog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestSlowQueryLogged(t *testing.T) {
+	setupTest(t)
+	db, err := sql.Open("sleepdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ps := &PollStore{db: &timedDB{db}}
+	logs := captureSlog(t)
+	setDelay := func(d time.Duration) {
+		sleepDB.mu.Lock()
+		sleepDB.delay = d
+		sleepDB.mu.Unlock()
+	}
+	t.Cleanup(func() { setDelay(0) })
+
+	// 未设置阈值时不计时
+	setDelay(30 * time.Millisecond)
+	if _, err := ps.Exists("p1"); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("未设置阈值时记录了日志: %s", logs.String())
+	}
+
+	// 超过阈值的查询记录耗时、发起查询的方法和压缩空白后的语句
+	cfg.SlowQueryThreshold = 10 * time.Millisecond
+	if _, err := ps.Exists("p1"); err != nil {
+		t.Fatal(err)
+	}
+	line := logs.String()
+	for _, want := range []string{
+		"level=WARN",
+		`msg="slow query"`,
+		"label=(*PollStore).Exists",
+		`query="SELECT COUNT(*) FROM polls WHERE (id = ? OR slug = ? OR join_code = ?) AND deleted_at IS NULL"`,
+		"duration=",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("慢查询日志缺少 %s: %s", want, line)
+		}
+	}
+
+	// 事务中的语句同样计时
+	logs.Reset()
+	tx, err := ps.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`UPDATE polls SET views = views + 1`); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if !strings.Contains(logs.String(), `query="UPDATE polls SET views = views + 1"`) {
+		t.Errorf("事务中的慢语句没有记录: %s", logs.String())
+	}
+
+	// 未超过阈值的查询不记录
+	logs.Reset()
+	setDelay(0)
+	if _, err := ps.Exists("p1"); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("快速查询记录了日志: %s", logs.String())
+	}
+}
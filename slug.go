@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// slug 相关错误
+var (
+	errInvalidSlug = errors.New("slug must be 3-64 lowercase letters, digits or dashes")
+	errSlugTaken   = errors.New("slug already taken")
+
+	errSlugPollNotFound = errors.New("poll not found")
+)
+
+// validateSlug 短链接只允许小写字母、数字和中划线，且不能是 UUID 形式以免与投票 ID 混淆
+func validateSlug(slug string) error {
+	if len(slug) < 3 || len(slug) > 64 || !slugPattern.MatchString(slug) {
+		return errInvalidSlug
+	}
+	if _, err := uuid.Parse(slug); err == nil {
+		return errInvalidSlug
+	}
+	return nil
+}
+
+// slugTaken 检查短链接是否已被其他投票使用
+func slugTaken(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, slug, exceptID string) (bool, error) {
+	var n int
+	err := q.QueryRow(`SELECT COUNT(*) FROM polls WHERE slug = ? AND id != ?`, slug, exceptID).Scan(&n)
+	return n > 0, err
+}
+
+// Resolve 按投票 ID、短链接或投票代码（不区分大小写）查找投票
+func (ps *PollStore) Resolve(idOrSlug string) (*Poll, error) {
+	poll, err := ps.Get(idOrSlug)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return poll, err
+	}
+
+	var id string
+	err = ps.db.QueryRow(`SELECT id FROM polls WHERE slug = ? AND deleted_at IS NULL`, idOrSlug).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, err = ps.resolveJoinCode(idOrSlug)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ps.Get(id)
+}
+
+// Exists 只检查投票 ID、短链接或投票代码是否存在，不加载投票数据
+func (ps *PollStore) Exists(idOrSlug string) (bool, error) {
+	var n int
+	err := ps.db.QueryRow(`SELECT COUNT(*) FROM polls WHERE (id = ? OR slug = ? OR join_code = ?) AND deleted_at IS NULL`, idOrSlug, idOrSlug, normalizeJoinCode(idOrSlug)).Scan(&n)
+	return n > 0, err
+}
+
+// SetSlug 设置或清除（slug 为空）投票的短链接
+func (ps *PollStore) SetSlug(pollID, slug string) error {
+	if slug != "" {
+		if err := validateSlug(slug); err != nil {
+			return err
+		}
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if slug != "" {
+		taken, err := slugTaken(tx, slug, pollID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errSlugTaken
+		}
+	}
+
+	var value interface{}
+	if slug != "" {
+		value = slug
+	}
+	result, err := tx.Exec(`UPDATE polls SET slug = ? WHERE id = ? AND deleted_at IS NULL`, value, pollID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errSlugPollNotFound
+	}
+
+	return tx.Commit()
+}
+
+// apiSlugHandler 管理接口：设置或清除投票的短链接，请求体 {"slug": "team-lunch"}
+func apiSlugHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var req struct {
+		Slug string `json:"slug"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		})
+		return
+	}
+
+	if err := store.SetSlug(r.PathValue("id"), req.Slug); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errInvalidSlug):
+			status = http.StatusBadRequest
+		case errors.Is(err, errSlugTaken):
+			status = http.StatusConflict
+		case errors.Is(err, errSlugPollNotFound):
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"slug":    req.Slug,
+	})
+}
+
+// apiSlugAvailableHandler 创建投票前检查短链接是否可用。已删除投票的短链接仍被占用
+func apiSlugAvailableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	slug := r.URL.Query().Get("slug")
+	if err := validateSlug(slug); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success":   false,
+			"available": false,
+			"error":     err.Error(),
+		})
+		return
+	}
+
+	taken, err := slugTaken(store.db, slug, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"available": !taken,
+	})
+}
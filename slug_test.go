@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSlugResolution(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "slug": "team-lunch"})
+
+	poll, err := store.Resolve("team-lunch")
+	if err != nil || poll.ID != pollID {
+		t.Fatalf("Resolve(team-lunch) = %v, %v", poll, err)
+	}
+	for _, path := range []string{"/poll/team-lunch", "/poll/" + pollID, "/api/results/team-lunch"} {
+		if rec := doRequest(t, http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("GET %s 状态码 = %d", path, rec.Code)
+		}
+	}
+	if rec := doRequest(t, http.MethodGet, "/poll/no-such-slug", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的短链接状态码 = %d，期望 404", rec.Code)
+	}
+
+	// 改用新的短链接后旧的失效
+	rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/slug", map[string]string{"slug": "friday-lunch"}, adminHeader...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("设置短链接失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if poll, err := store.Resolve("friday-lunch"); err != nil || poll.ID != pollID {
+		t.Errorf("Resolve(friday-lunch) = %v, %v", poll, err)
+	}
+	if _, err := store.Resolve("team-lunch"); err == nil {
+		t.Error("旧短链接仍可访问")
+	}
+}
+
+func TestSlugConflict(t *testing.T) {
+	setupTest(t)
+	createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "slug": "taken"})
+	other, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "slug": "taken"})
+	if body := decodeBody(t, rec); rec.Code != http.StatusConflict || body["success"] != false {
+		t.Errorf("创建时使用已占用的短链接（%d）: %s，期望 409", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, http.MethodPost, "/api/poll/"+other+"/slug", map[string]string{"slug": "taken"}, adminHeader...)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("设置已占用的短链接状态码 = %d，期望 409", rec.Code)
+	}
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/available?slug=taken", nil))
+	if body["available"] != false {
+		t.Errorf("slug-available = %v", body)
+	}
+}
+
+func TestInvalidSlugRejected(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	for _, slug := range []string{"ab", "Team-Lunch", "team_lunch", "-lead", "trail-", "two--dashes", "中文", pollID} {
+		if err := validateSlug(slug); err == nil {
+			t.Errorf("validateSlug(%q) 应报错", slug)
+		}
+		rec := doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/slug", map[string]string{"slug": slug}, adminHeader...)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: 状态码 = %d，期望 400", slug, rec.Code)
+		}
+	}
+	if err := validateSlug("team-lunch-2"); err != nil {
+		t.Errorf("合法的短链接被拒绝: %v", err)
+	}
+}
+
+func TestSlugAvailable(t *testing.T) {
+	setupTest(t)
+	createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "slug": "taken"})
+
+	for slug, want := range map[string]bool{"taken": false, "still-free": true} {
+		rec := doRequest(t, http.MethodGet, "/api/poll/available?slug="+slug, nil)
+		if body := decodeBody(t, rec); rec.Code != http.StatusOK || body["available"] != want {
+			t.Errorf("slug %q（%d）: %s，期望 available = %v", slug, rec.Code, rec.Body.String(), want)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q", got)
+		}
+	}
+	for _, slug := range []string{"", "Bad Slug"} {
+		if rec := doRequest(t, http.MethodGet, "/api/poll/available?slug="+url.QueryEscape(slug), nil); rec.Code != http.StatusBadRequest || decodeBody(t, rec)["available"] != false {
+			t.Errorf("无效的 slug %q（%d）: %s", slug, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 查询走索引而不是全表扫描
+	var plan strings.Builder
+	rows, err := store.db.Query(`EXPLAIN QUERY PLAN SELECT COUNT(*) FROM polls WHERE slug = ? AND id != ?`, "taken", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan.WriteString(detail + "\n")
+	}
+	if !strings.Contains(plan.String(), "INDEX") {
+		t.Errorf("查询计划未使用索引:\n%s", plan.String())
+	}
+}
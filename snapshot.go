@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResultSnapshot 某一时刻的结果快照，创建后不随后续投票变化，便于引用
+type ResultSnapshot struct {
+	ID          string             `json:"id"`
+	PollID      string             `json:"poll_id"`
+	Title       string             `json:"title"`
+	Options     []string           `json:"options"`
+	Votes       map[string]int     `json:"votes,omitempty"`       // 投票设置了不公开投票人数时省略
+	VoterCount  *int               `json:"voter_count,omitempty"` // 投票设置了不公开投票人数时省略
+	Percentages map[string]float64 `json:"percentages,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// CreateSnapshot 保存投票当前的票数。快照对外公开，不公开投票人数的投票只保存百分比，
+// 不保存票数（单选投票的票数之和就是投票人数）
+func (ps *PollStore) CreateSnapshot(poll *Poll) (*ResultSnapshot, error) {
+	// 快照任何人都能查看，不包含隐藏选项的票数
+	withholdHiddenOptions(poll)
+	snap := &ResultSnapshot{
+		ID:        uuid.New().String(),
+		PollID:    poll.ID,
+		Title:     poll.Title,
+		Options:   poll.ResultOptions(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if poll.HideVoterCount {
+		snap.Percentages = votePercentages(poll.Votes, poll.PercentBase())
+	} else {
+		voterCount := poll.VoterCount
+		snap.Votes = poll.Votes
+		snap.VoterCount = &voterCount
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ps.db.Exec(`
+		INSERT INTO result_snapshots (id, poll_id, data, created_at) VALUES (?, ?, ?, ?)
+	`, snap.ID, snap.PollID, string(data), snap.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// GetSnapshot 读取快照，不存在时返回 sql.ErrNoRows。投票删除后快照仍然可以访问
+func (ps *PollStore) GetSnapshot(id string) (*ResultSnapshot, error) {
+	var data string
+	if err := ps.db.QueryRow(`SELECT data FROM result_snapshots WHERE id = ?`, id).Scan(&data); err != nil {
+		return nil, err
+	}
+	var snap ResultSnapshot
+	if err := json.Unmarshal([]byte(data), &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// apiCreateSnapshotHandler 为投票结果创建快照。结果尚未公开时只有管理员和创建者可以创建
+func apiCreateSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if resultsRestricted(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   poll.restrictedNotice(),
+		})
+		return
+	}
+	if poll.ResultsHidden() && !canManage(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "results are not public yet",
+		})
+		return
+	}
+
+	snap, err := store.CreateSnapshot(poll)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":     true,
+		"snapshot_id": snap.ID,
+		"url":         cfg.BaseURL + "/api/snapshot/" + snap.ID,
+		"created_at":  snap.CreatedAt,
+	})
+}
+
+// apiSnapshotHandler 返回已保存的快照
+func apiSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	snap, err := store.GetSnapshot(r.PathValue("sid"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "snapshot not found",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 快照不会变化，可以长期缓存
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"snapshot": snap,
+	})
+}
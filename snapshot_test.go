@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func createSnapshot(t *testing.T, pollID string, headers ...string) string {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/results/"+pollID+"/snapshot", nil, headers...)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("创建快照失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	return decodeBody(t, rec)["snapshot_id"].(string)
+}
+
+func getSnapshot(t *testing.T, snapshotID string) ResultSnapshot {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/snapshot/"+snapshotID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("读取快照失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Snapshot ResultSnapshot `json:"snapshot"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Snapshot
+}
+
+func TestSnapshotUnaffectedByLaterVotes(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "a")
+	mustVote(t, pollID, "b")
+
+	snapshotID := createSnapshot(t, pollID)
+	mustVote(t, pollID, "b")
+	mustVote(t, pollID, "b")
+
+	snap := getSnapshot(t, snapshotID)
+	if snap.PollID != pollID || !reflect.DeepEqual(snap.Votes, map[string]int{"a": 2, "b": 1}) || snap.VoterCount == nil || *snap.VoterCount != 3 {
+		t.Errorf("快照 = %+v，期望保留创建时的 a 2、b 1、3 人", snap)
+	}
+	if live := mustGet(t, pollID); live.Votes["b"] != 3 {
+		t.Errorf("实时票数 b = %d，期望 3", live.Votes["b"])
+	}
+
+	// 投票删除后快照仍可访问
+	doRequest(t, http.MethodPost, "/api/delete-poll/"+pollID, nil)
+	if snap := getSnapshot(t, snapshotID); snap.Votes["a"] != 2 {
+		t.Errorf("删除投票后快照 = %+v", snap)
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/snapshot/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的快照状态码 = %d，期望 404", rec.Code)
+	}
+}
+
+func TestSnapshotOfHiddenResults(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_results": true})
+	mustVote(t, pollID, "a")
+	if rec := doRequest(t, http.MethodPost, "/api/results/"+pollID+"/snapshot", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("结果隐藏时状态码 = %d，期望 403", rec.Code)
+	}
+	if snap := getSnapshot(t, createSnapshot(t, pollID, manageTokenHeader, manageToken)); snap.Votes["a"] != 1 {
+		t.Errorf("管理者创建的快照 = %+v", snap)
+	}
+
+	// 不公开投票人数的投票只保存百分比
+	hidden, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_voter_count": true})
+	mustVote(t, hidden, "a")
+	mustVote(t, hidden, "b")
+	snap := getSnapshot(t, createSnapshot(t, hidden))
+	if snap.Votes != nil || snap.VoterCount != nil || snap.Percentages["a"] != 50 {
+		t.Errorf("不公开投票人数的快照 = %+v", snap)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// spaHandler 托管单页应用：目录中存在的文件直接返回，其余非 API 路径都返回入口 index.html，
+// 由前端路由处理。/api/ 下未注册的路径仍然返回 404
+func spaHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := filepath.Join(cfg.SPADir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	if info, err := os.Stat(name); err != nil || info.IsDir() {
+		name = filepath.Join(cfg.SPADir, "index.html")
+		// 入口页面不缓存，发布新版本后立即生效
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFile(w, r, name)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	setupTest(t)
+
+	// 未开启时未知路径返回 404，根路径显示首页
+	if rec := doRequest(t, http.MethodGet, "/dashboard/polls", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("未开启 -spa 时未知路径状态码 = %d，期望 404", rec.Code)
+	}
+	if rec := doRequest(t, http.MethodGet, "/", nil); rec.Code != http.StatusOK {
+		t.Errorf("首页状态码 = %d", rec.Code)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<div id=app></div>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SPADir = dir
+
+	for _, path := range []string{"/", "/dashboard/polls", "/assets/missing.js", "/etc/passwd"} {
+		rec := doRequest(t, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "id=app") || rec.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("%s: 状态码 = %d，Cache-Control = %q，body = %q，期望返回入口页面", path, rec.Code, rec.Header().Get("Cache-Control"), rec.Body.String())
+		}
+	}
+	// 真实文件和 API 路由照常处理
+	if rec := doRequest(t, http.MethodGet, "/assets/app.js", nil); rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" {
+		t.Errorf("静态文件（%d）: %q", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/unknown", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("未知 API 路径状态码 = %d，期望 404", rec.Code)
+	}
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil); rec.Code != http.StatusOK {
+		t.Errorf("API 路由状态码 = %d", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 选项补全返回的数量
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+// OptionSuggestion 以往投票中用过的选项名及使用它的投票数
+type OptionSuggestion struct {
+	Option string `json:"option"`
+	Polls  int    `json:"polls"`
+}
+
+// likeEscaper 转义 LIKE 中的通配符，配合 ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SuggestOptions 返回以 prefix 开头（ASCII 字母不区分大小写）的不同选项名，按使用过的投票数从多到少排列，
+// 数量相同时按名称排序。接口不需要登录，因此只统计未删除、任何人都能投票且结果一直公开的投票，
+// 名单投票、隐藏结果或限定结果可见范围的投票的选项以及不公开的选项不会出现在补全中
+func (ps *PollStore) SuggestOptions(prefix string, limit int) ([]OptionSuggestion, error) {
+	rows, err := ps.db.Query(`
+		SELECT v.option_name, COUNT(DISTINCT v.poll_id) AS n
+		FROM votes v JOIN polls p ON p.id = v.poll_id
+		WHERE p.deleted_at IS NULL
+			AND p.access_mode = 'public' AND p.hide_results = 0 AND p.results_visible_at IS NULL
+			AND p.result_visibility = 'public'
+			AND v.hidden = 0 AND v.option_name LIKE ? ESCAPE '\'
+		GROUP BY v.option_name
+		ORDER BY n DESC, v.option_name
+		LIMIT ?
+	`, likeEscaper.Replace(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []OptionSuggestion{}
+	for rows.Next() {
+		var s OptionSuggestion
+		if err := rows.Scan(&s.Option, &s.Polls); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+// apiOptionSuggestHandler 创建投票时的选项自动补全：GET /api/options/suggest?q=前缀&limit=10
+func apiOptionSuggestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "q is required",
+		})
+		return
+	}
+	limit := defaultSuggestLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "limit must be between 1 and " + strconv.Itoa(maxSuggestLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	suggestions, err := store.SuggestOptions(q, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"suggestions": suggestions,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func suggest(t *testing.T, query string) []OptionSuggestion {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/options/suggest"+query, nil)
+	var resp struct {
+		Suggestions []OptionSuggestion `json:"suggestions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("%s（%d）: %s", query, rec.Code, rec.Body.String())
+	}
+	return resp.Suggestions
+}
+
+func TestSuggestOptionsByFrequency(t *testing.T) {
+	setupTest(t)
+	for _, options := range [][]string{
+		{"Pizza", "Pasta", "Salad"},
+		{"Pizza", "Pasta"},
+		{"Pizza", "Pho"},
+		{"pizza_100%", "Other"},
+	} {
+		createTestPoll(t, map[string]interface{}{"title": "t", "options": options})
+	}
+	deleted, deletedToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"Pho", "Pancake"}})
+	doRequest(t, http.MethodPost, "/api/delete-poll/"+deleted, nil, manageTokenHeader, deletedToken)
+	// 名单投票、隐藏结果和限定结果可见范围的投票的选项不公开
+	for _, private := range []map[string]interface{}{
+		{"title": "t", "options": []string{"Pho", "Private"}, "access_mode": AccessAllowlist},
+		{"title": "t", "options": []string{"Pho", "Private"}, "hide_results": true},
+		{"title": "t", "options": []string{"Pho", "Private"}, "results_visible_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+		{"title": "t", "options": []string{"Pho", "Private"}, "result_visibility": ResultVisibilityVoters},
+		{"title": "t", "options": []string{"Pho", "Private"}, "result_visibility": ResultVisibilityOwner},
+		{"title": "t", "options": []string{"Other", "Private"}, "hidden_options": []string{"Private"}},
+	} {
+		createTestPoll(t, private)
+	}
+
+	want := []OptionSuggestion{{"Pizza", 3}, {"Pasta", 2}, {"Pho", 1}, {"pizza_100%", 1}}
+	if got := suggest(t, "?q=p"); !reflect.DeepEqual(got, want) {
+		t.Errorf("q=p: %+v，期望 %+v", got, want)
+	}
+	if got := suggest(t, "?q=p&limit=2"); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("limit=2: %+v", got)
+	}
+	// 通配符按字面匹配
+	if got := suggest(t, "?q=pizza_"); !reflect.DeepEqual(got, []OptionSuggestion{{"pizza_100%", 1}}) {
+		t.Errorf("q=pizza_: %+v", got)
+	}
+	if got := suggest(t, "?q=%25"); len(got) != 0 {
+		t.Errorf("q=%%: %+v", got)
+	}
+	if got := suggest(t, "?q=zzz"); got == nil || len(got) != 0 {
+		t.Errorf("没有匹配时应返回空列表: %+v", got)
+	}
+	if rec := doRequest(t, http.MethodGet, "/api/options/suggest?q=%20", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("q 为空时状态码 = %d，期望 400", rec.Code)
+	}
+
+	for _, limit := range []string{"0", "51", "x"} {
+		if rec := doRequest(t, http.MethodGet, "/api/options/suggest?q=p&limit="+limit, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s 状态码 = %d，期望 400", limit, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errSurveyAnswers = errors.New("every shown question must be answered exactly once")
+
+// errQuestionNotShown 按显示条件不应显示的问题提交了答案
+var errQuestionNotShown = errors.New("question is not shown for these answers")
+
+// errSurveyQuestion 通过 /api/vote 单独投问卷中的问题
+var errSurveyQuestion = errors.New("this poll is a survey question: submit the whole survey to /api/survey-vote")
+
+// Survey 问卷：按顺序排列的一组投票（问题），一起提交
+type Survey struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	Questions []*Poll           `json:"questions"`
+	ShowIf    map[string]ShowIf `json:"show_if,omitempty"` // 问题 ID -> 显示条件，没有条件的问题始终显示
+	CreatedAt time.Time         `json:"created_at"`
+
+	ManageToken string `json:"-"`                     // 各问题共用的投票管理令牌，只在创建时有值
+	PageToken   string `json:"page_token,omitempty"`  // 开启最短停留时间时签发，随问卷提交
+	HumanCheck  string `json:"human_check,omitempty"` // 开启 -vote-honeypot 时据此计算 X-Vote-Check 头
+}
+
+// ShowIf 问题的显示条件：前面某个问题的答案包含指定选项时才显示
+type ShowIf struct {
+	QuestionID string `json:"question_id"`
+	Option     string `json:"option"`
+}
+
+// SurveyBranch 创建问卷时的显示条件，问题按在 questions 中的序号（从 0 开始）引用
+type SurveyBranch struct {
+	Question   int    `json:"question"`
+	IfQuestion int    `json:"if_question"`
+	IfOption   string `json:"if_option"`
+}
+
+// CreateSurveyRequest 创建问卷请求，每个问题与创建投票的请求体相同
+type CreateSurveyRequest struct {
+	Title     string              `json:"title"`
+	Questions []CreatePollRequest `json:"questions"`
+	Branches  []SurveyBranch      `json:"branches,omitempty"`
+}
+
+// Validate 校验问卷和每个问题，问题的字段错误以 questions[i]. 为前缀
+func (req *CreateSurveyRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(req.Title) == "" {
+		errs.Add("title", "title is required")
+	} else if blocklist.Contains(req.Title) {
+		errs.Add("title", "title contains a blocked word")
+	}
+	if len(req.Questions) == 0 {
+		errs.Add("questions", "at least 1 question is required")
+	}
+	for i := range req.Questions {
+		for _, e := range req.Questions[i].Validate() {
+			errs.Add(fmt.Sprintf("questions[%d].%s", i, e.Field), "%s", e.Message)
+		}
+		// 问卷整体提交，没有逐题的两步确认
+		if req.Questions[i].ConfirmVote {
+			errs.Add(fmt.Sprintf("questions[%d].confirm_vote", i), "confirm_vote is not supported for survey questions")
+		}
+	}
+
+	// 条件只能依据前面的问题，因此不会形成循环
+	conditioned := make(map[int]bool, len(req.Branches))
+	for i, b := range req.Branches {
+		field := fmt.Sprintf("branches[%d]", i)
+		switch {
+		case b.Question < 0 || b.Question >= len(req.Questions):
+			errs.Add(field+".question", "question out of range")
+		case b.IfQuestion < 0 || b.IfQuestion >= b.Question:
+			errs.Add(field+".if_question", "if_question must refer to an earlier question")
+		case conditioned[b.Question]:
+			errs.Add(field+".question", "question %d already has a condition", b.Question)
+		case !slices.Contains(req.Questions[b.IfQuestion].Options, b.IfOption):
+			errs.Add(field+".if_option", "if_option is not an option of question %d", b.IfQuestion)
+		}
+		conditioned[b.Question] = true
+	}
+	return errs
+}
+
+// shownQuestions 按问卷的显示条件和答案计算每个问题是否显示。
+// 问题依据的问题本身未显示（或已被删除）时同样不显示
+func (s *Survey) shownQuestions(answers map[string][]string) map[string]bool {
+	shown := make(map[string]bool, len(s.Questions))
+	for _, q := range s.Questions {
+		cond, ok := s.ShowIf[q.ID]
+		shown[q.ID] = !ok || (shown[cond.QuestionID] && slices.Contains(answers[cond.QuestionID], cond.Option))
+	}
+	return shown
+}
+
+// CreateSurvey 在同一个事务中创建问卷和全部问题
+func (ps *PollStore) CreateSurvey(req *CreateSurveyRequest) (*Survey, error) {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	survey := &Survey{
+		ID:          uuid.New().String(),
+		Title:       req.Title,
+		CreatedAt:   time.Now(),
+		ManageToken: newManageToken(),
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO surveys (id, title, created_at) VALUES (?, ?, ?)`, survey.ID, survey.Title, survey.CreatedAt); err != nil {
+		return nil, err
+	}
+	branches := make(map[int]SurveyBranch, len(req.Branches))
+	for _, b := range req.Branches {
+		branches[b.Question] = b
+	}
+	for i := range req.Questions {
+		q := &req.Questions[i]
+		settings := q.settings()
+		settings.ManageToken = survey.ManageToken
+		minChoices, maxChoices := q.choiceLimits()
+		poll, err := createPollTx(tx, q.Title, q.Options, q.MultiSelect, minChoices, maxChoices, settings)
+		if err != nil {
+			return nil, fmt.Errorf("question %d: %w", i+1, err)
+		}
+		// 条件依据的问题在前面，已经创建，保存其投票 ID 而不是序号
+		var cond ShowIf
+		if b, ok := branches[i]; ok {
+			cond = ShowIf{QuestionID: survey.Questions[b.IfQuestion].ID, Option: b.IfOption}
+			if survey.ShowIf == nil {
+				survey.ShowIf = make(map[string]ShowIf)
+			}
+			survey.ShowIf[poll.ID] = cond
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO survey_questions (survey_id, position, poll_id, show_if_poll, show_if_option) VALUES (?, ?, ?, ?, ?)
+		`, survey.ID, i, poll.ID, cond.QuestionID, cond.Option); err != nil {
+			return nil, err
+		}
+		survey.Questions = append(survey.Questions, poll)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return survey, nil
+}
+
+// SurveyExists 只检查问卷是否存在，不加载问题
+func (ps *PollStore) SurveyExists(id string) (bool, error) {
+	var n int
+	err := ps.db.QueryRow(`SELECT COUNT(*) FROM surveys WHERE id = ?`, id).Scan(&n)
+	return n > 0, err
+}
+
+// GetSurvey 读取问卷及按顺序排列的问题，已删除的问题不返回
+func (ps *PollStore) GetSurvey(id string) (*Survey, error) {
+	survey := &Survey{ID: id, Questions: []*Poll{}}
+	err := ps.db.QueryRow(`SELECT title, created_at FROM surveys WHERE id = ?`, id).Scan(&survey.Title, &survey.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := ps.db.Query(`SELECT poll_id, show_if_poll, show_if_option FROM survey_questions WHERE survey_id = ? ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	var pollIDs []string
+	for rows.Next() {
+		var pollID string
+		var cond ShowIf
+		if err := rows.Scan(&pollID, &cond.QuestionID, &cond.Option); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pollIDs = append(pollIDs, pollID)
+		if cond.QuestionID != "" {
+			if survey.ShowIf == nil {
+				survey.ShowIf = make(map[string]ShowIf)
+			}
+			survey.ShowIf[pollID] = cond
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 先读完问题列表再逐个查询，单连接（内存数据库）时不能嵌套查询
+	for _, pollID := range pollIDs {
+		poll, err := ps.Get(pollID)
+		if err != nil {
+			continue
+		}
+		survey.Questions = append(survey.Questions, poll)
+	}
+	return survey, nil
+}
+
+// checkBallot 校验一个问题的答案：选项存在、不重复，且符合单选/多选和选择数量限制。
+// 空答案交给 addVoteTx 按是否允许弃权处理。group 为投票人分组，没有时传空串
+func checkBallot(poll *Poll, options []string, group string) error {
+	if len(options) == 0 {
+		return nil
+	}
+	known := make(map[string]bool, len(poll.Options))
+	for _, opt := range poll.Options {
+		known[opt] = true
+	}
+	seen := make(map[string]bool, len(options))
+	for _, opt := range options {
+		if !known[opt] {
+			return fmt.Errorf("unknown option %q", opt)
+		}
+		if seen[opt] {
+			return fmt.Errorf("duplicate option %q", opt)
+		}
+		seen[opt] = true
+	}
+	if !poll.MultiSelect && len(options) > 1 {
+		return fmt.Errorf("only one option can be selected")
+	}
+	if poll.MultiSelect {
+		return poll.ChoiceLimits(group).check(len(options))
+	}
+	return nil
+}
+
+// SurveyVote 在同一个事务中记录全部问题的答案，任何一个问题失败都不会写入。
+// 按显示条件未显示的问题不计票，可以不提交或提交空答案，提交了选项则拒绝
+func (ps *PollStore) SurveyVote(surveyID string, answers map[string][]string, voter Voter) error {
+	survey, err := ps.GetSurvey(surveyID)
+	if err != nil {
+		return err
+	}
+	shown := survey.shownQuestions(answers)
+	for questionID := range answers {
+		if _, ok := shown[questionID]; !ok {
+			return errSurveyAnswers
+		}
+	}
+	for i, q := range survey.Questions {
+		options, ok := answers[q.ID]
+		if !shown[q.ID] {
+			if len(options) > 0 {
+				return fmt.Errorf("question %d: %w", i+1, errQuestionNotShown)
+			}
+			continue
+		}
+		if !ok {
+			return errSurveyAnswers
+		}
+		if err := checkBallot(q, options, ""); err != nil {
+			return fmt.Errorf("question %d: %w", i+1, err)
+		}
+	}
+
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, q := range survey.Questions {
+		if !shown[q.ID] {
+			continue
+		}
+		if _, err := addVoteTx(tx, q.ID, answers[q.ID], "", voter, surveyID); err != nil {
+			return fmt.Errorf("question %d: %w", i+1, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func apiCreateSurveyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var req CreateSurveyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	// 每个问题都是一个投票，与创建投票一样计入创建者的投票数限制
+	creatorIP := hashVoterKey(clientIP(r))
+	for i := range req.Questions {
+		req.Questions[i].applyOptionsText()
+		req.Questions[i].creatorIP = creatorIP
+		if !isAdmin(r) {
+			req.Questions[i].maxPolls = cfg.MaxPollsPerCreator
+		}
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeCreateError(w, errs)
+		return
+	}
+
+	survey, err := store.CreateSurvey(&req)
+	if err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	questionIDs := make([]string, len(survey.Questions))
+	for i, q := range survey.Questions {
+		questionIDs[i] = q.ID
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"survey_id":    survey.ID,
+		"question_ids": questionIDs,
+		"manage_token": survey.ManageToken,
+	})
+}
+
+// apiSurveyHandler 问卷和各问题的结果，隐藏结果的问题按公开规则处理
+func apiSurveyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	survey, err := store.GetSurvey(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "survey not found",
+		})
+		return
+	}
+	for _, q := range survey.Questions {
+		if !canPreview(q, r) {
+			redactForPublic(q)
+		}
+	}
+	if cfg.MinVoteDelay > 0 {
+		survey.PageToken = newPageToken(survey.ID)
+	}
+	if cfg.VoteHoneypot {
+		survey.HumanCheck = humanCheckSeed(survey.ID)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"survey":  survey,
+	})
+}
+
+// SurveyVoteRequest 提交问卷，answers 为问题（投票）ID -> 所选选项
+type SurveyVoteRequest struct {
+	SurveyID string              `json:"survey_id"`
+	Answers  map[string][]string `json:"answers"`
+	Token    string              `json:"token,omitempty"`
+	Name     string              `json:"name,omitempty"`
+
+	PageToken string `json:"page_token,omitempty"` // GET /api/survey/{id} 签发的令牌
+	Website   string `json:"website,omitempty"`    // 隐藏的诱饵字段，正常用户不会填写
+}
+
+// checkSurveyGuards 问卷提交前的防刷检查，与单个投票相同。工作量证明、诱饵和停留时间按问卷 ID 校验；
+// 邀请令牌由名单投票的问题签发，按各问题校验。需要两步确认的问题不能随问卷提交。返回值与 checkVoteGuards 相同
+func checkSurveyGuards(w http.ResponseWriter, r *http.Request, req *SurveyVoteRequest, survey *Survey) (string, bool) {
+	guard := VoteRequest{PollID: survey.ID, PageToken: req.PageToken, Website: req.Website}
+	challenge, ok := checkVoteGuards(w, r, &guard)
+	if !ok {
+		return "", false
+	}
+
+	for _, q := range survey.Questions {
+		if q.ConfirmVote {
+			usedChallenges.Release(challenge)
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   errConfirmRequired.Error(),
+			})
+			return "", false
+		}
+		if q.AccessMode != AccessAllowlist || !strings.HasPrefix(req.Token, invitePrefix) {
+			continue
+		}
+		if err := verifyInviteToken(q.ID, req.Token); err != nil {
+			usedChallenges.Release(challenge)
+			log.Printf("拒绝问卷 %s（%s）: %v", survey.ID, clientIP(r), err)
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return "", false
+		}
+	}
+	return challenge, true
+}
+
+func apiSurveyVoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var req SurveyVoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	survey, err := store.GetSurvey(req.SurveyID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "survey not found",
+		})
+		return
+	}
+	challenge, ok := checkSurveyGuards(w, r, &req, survey)
+	if !ok {
+		return
+	}
+	// 提交失败时释放题目，与单个投票相同
+	recorded := false
+	defer func() {
+		if !recorded {
+			usedChallenges.Release(challenge)
+		}
+	}()
+
+	voter := Voter{Token: req.Token, ID: ensureVoterCookie(w, r), Name: req.Name, IP: clientIP(r)}
+	if err := store.SurveyVote(req.SurveyID, req.Answers, voter); err != nil {
+		status := http.StatusBadRequest
+		var fullErr *OptionsFullError
+		switch {
+		case errors.As(err, &fullErr):
+			status = http.StatusConflict
+		case errors.Is(err, sql.ErrNoRows):
+			status = http.StatusNotFound
+			err = errors.New("survey not found")
+		case errors.Is(err, errVoterNotAllowed) || errors.Is(err, errTokenUsed) || errors.Is(err, errIPAlreadyVoted):
+			log.Printf("拒绝问卷 %s（%s）: %v", req.SurveyID, clientIP(r), err)
+			status = http.StatusForbidden
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	recorded = true
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// createTestSurvey 创建问卷，返回问卷 ID 和按顺序排列的问题 ID
+func createTestSurvey(t *testing.T, req map[string]interface{}) (string, []string) {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/create-survey", req)
+	body := decodeBody(t, rec)
+	if body["success"] != true {
+		t.Fatalf("创建问卷失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	var questionIDs []string
+	for _, id := range body["question_ids"].([]interface{}) {
+		questionIDs = append(questionIDs, id.(string))
+	}
+	return body["survey_id"].(string), questionIDs
+}
+
+// submitSurvey 以新访问者的身份提交问卷
+func submitSurvey(t *testing.T, surveyID string, answers map[string][]string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/survey-vote", map[string]interface{}{
+		"survey_id": surveyID,
+		"answers":   answers,
+	})
+}
+
+func TestSurveyVoteIsAtomic(t *testing.T) {
+	setupTest(t)
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title": "问卷",
+		"questions": []map[string]interface{}{
+			{"title": "q1", "options": []string{"a", "b"}},
+			{"title": "q2", "options": []string{"x", "y"}, "option_capacity": map[string]int{"x": 1}},
+		},
+	})
+
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"a"}, q[1]: {"x"}}); rec.Code != http.StatusOK {
+		t.Fatalf("提交问卷失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if first, second := mustGet(t, q[0]), mustGet(t, q[1]); first.Votes["a"] != 1 || second.Votes["x"] != 1 || first.VoterCount != 1 || second.VoterCount != 1 {
+		t.Fatalf("提交后 q1 = %v（%d 人），q2 = %v（%d 人）", first.Votes, first.VoterCount, second.Votes, second.VoterCount)
+	}
+
+	// 第二个问题在写入时失败（名额已满），第一个问题的答案随之回滚
+	rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"b"}, q[1]: {"x"}})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("名额已满时状态码 = %d，期望 409: %s", rec.Code, rec.Body.String())
+	}
+	// 校验失败时不写入任何问题
+	for _, answers := range []map[string][]string{
+		{q[0]: {"b"}, q[1]: {"nope"}},
+		{q[0]: {"b"}},
+		{q[0]: {"b"}, q[1]: {"y"}, "unknown": {"a"}},
+		{q[0]: {"a", "b"}, q[1]: {"y"}},
+	} {
+		if rec := submitSurvey(t, surveyID, answers); rec.Code != http.StatusBadRequest {
+			t.Errorf("answers = %v: 状态码 = %d，期望 400: %s", answers, rec.Code, rec.Body.String())
+		}
+	}
+	if first, second := mustGet(t, q[0]), mustGet(t, q[1]); first.Votes["b"] != 0 || second.Votes["y"] != 0 || first.VoterCount != 1 || second.VoterCount != 1 {
+		t.Errorf("失败的提交写入了部分答案: q1 = %v（%d 人），q2 = %v（%d 人）", first.Votes, first.VoterCount, second.Votes, second.VoterCount)
+	}
+
+	// 问卷中的问题不能单独投票
+	rec = castVote(t, q[0], "b")
+	if body := decodeBody(t, rec); body["success"] == true || body["error"] != errSurveyQuestion.Error() {
+		t.Errorf("单独投问卷问题（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	// 结果按问题汇总
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/survey/"+surveyID, nil))
+	questions := body["survey"].(map[string]interface{})["questions"].([]interface{})
+	if len(questions) != 2 || questions[0].(map[string]interface{})["id"] != q[0] {
+		t.Fatalf("问卷问题 = %v", questions)
+	}
+	if votes := questions[1].(map[string]interface{})["votes"].(map[string]interface{}); votes["x"] != float64(1) {
+		t.Errorf("q2 票数 = %v", votes)
+	}
+}
+
+func TestSurveyManageToken(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{
+		"title": "问卷",
+		"questions": []map[string]interface{}{
+			{"title": "q1", "options": []string{"a", "b"}},
+			{"title": "q2", "options": []string{"x", "y"}},
+		},
+	})
+	body := decodeBody(t, rec)
+	token, _ := body["manage_token"].(string)
+	if body["success"] != true || token == "" {
+		t.Fatalf("创建问卷没有返回管理令牌: %s", rec.Body.String())
+	}
+
+	// 与创建投票相同，带 CSRF Cookie 的请求必须携带令牌
+	cookie := csrfCookieName + "=" + csrfCookie(t, "/create")
+	if rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{
+		"title":     "问卷",
+		"questions": []map[string]interface{}{{"title": "q1", "options": []string{"a", "b"}}},
+	}, "Cookie", cookie); rec.Code != http.StatusForbidden {
+		t.Errorf("缺少 CSRF 令牌时状态码 = %d，期望 403", rec.Code)
+	}
+
+	// 各问题共用一个管理令牌
+	for _, id := range body["question_ids"].([]interface{}) {
+		rec := doRequest(t, http.MethodPost, "/api/close-poll/"+id.(string), nil, manageTokenHeader, token)
+		if decodeBody(t, rec)["success"] != true {
+			t.Errorf("用问卷的管理令牌结束问题 %s 失败: %s", id, rec.Body.String())
+		}
+	}
+}
+
+func TestSurveyBranches(t *testing.T) {
+	setupTest(t)
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title": "问卷",
+		"questions": []map[string]interface{}{
+			{"title": "q1", "options": []string{"yes", "no"}},
+			{"title": "q2", "options": []string{"x", "y"}},
+		},
+		"branches": []map[string]interface{}{{"question": 1, "if_question": 0, "if_option": "yes"}},
+	})
+
+	// 条件不满足时不显示的问题可以不答，提交了选项则拒绝
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"no"}}); rec.Code != http.StatusOK {
+		t.Fatalf("提交失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"no"}, q[1]: {"x"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("回答未显示的问题状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("漏答显示的问题状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}, q[1]: {"y"}}); rec.Code != http.StatusOK {
+		t.Fatalf("提交失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if first, second := mustGet(t, q[0]), mustGet(t, q[1]); first.VoterCount != 2 || second.VoterCount != 1 || second.Votes["y"] != 1 {
+		t.Errorf("q1 %d 人，q2 = %v（%d 人）", first.VoterCount, second.Votes, second.VoterCount)
+	}
+
+	// 条件只能依据前面的问题
+	rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{
+		"title": "问卷",
+		"questions": []map[string]interface{}{
+			{"title": "q1", "options": []string{"yes", "no"}},
+			{"title": "q2", "options": []string{"x", "y"}},
+		},
+		"branches": []map[string]interface{}{{"question": 0, "if_question": 1, "if_option": "x"}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("依据后面问题的条件状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestSurveyVoteGuards(t *testing.T) {
+	setupTest(t)
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title":     "问卷",
+		"questions": []map[string]interface{}{{"title": "q1", "options": []string{"a", "b"}}},
+	})
+
+	// 最短停留时间按问卷校验，没有问卷页签发的令牌时拒绝
+	cfg.MinVoteDelay = time.Hour
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"a"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("没有页面令牌时状态码 = %d，期望 400", rec.Code)
+	}
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/survey/"+surveyID, nil))
+	if body["survey"].(map[string]interface{})["page_token"] == nil {
+		t.Error("开启最短停留时间时问卷没有签发页面令牌")
+	}
+	if got := mustGet(t, q[0]).VoterCount; got != 0 {
+		t.Errorf("被拒绝的问卷计入了人数: %d", got)
+	}
+	cfg.MinVoteDelay = 0
+
+	// 工作量证明的题目绑定到问卷 ID
+	cfg.PoWDifficulty = 1
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"a"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("没有工作量证明时状态码 = %d，期望 400", rec.Code)
+	}
+	challenge, _ := decodeBody(t, doRequest(t, http.MethodGet, "/api/vote-challenge/"+surveyID, nil))["challenge"].(string)
+	if challenge == "" {
+		t.Fatal("问卷没有工作量证明题目")
+	}
+	pow := solvePow(challenge, cfg.PoWDifficulty)
+	submit := func(answers map[string][]string) int {
+		return doRequest(t, http.MethodPost, "/api/survey-vote", map[string]interface{}{
+			"survey_id": surveyID,
+			"answers":   answers,
+		}, "X-PoW", pow).Code
+	}
+	// 提交失败时释放题目，同一个解答可以再次提交
+	if code := submit(map[string][]string{q[0]: {"nope"}}); code != http.StatusBadRequest {
+		t.Errorf("选项不存在时状态码 = %d，期望 400", code)
+	}
+	if code := submit(map[string][]string{q[0]: {"a"}}); code != http.StatusOK {
+		t.Errorf("带工作量证明提交状态码 = %d，期望 200", code)
+	}
+	if code := submit(map[string][]string{q[0]: {"a"}}); code != http.StatusBadRequest {
+		t.Errorf("重复使用题目状态码 = %d，期望 400", code)
+	}
+
+	// 创建时不接受需要两步确认的问题
+	rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{
+		"title":     "问卷",
+		"questions": []map[string]interface{}{{"title": "q1", "options": []string{"a", "b"}, "confirm_vote": true}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("confirm_vote 问题的状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestSurveyChainedBranches(t *testing.T) {
+	setupTest(t)
+	questions := []map[string]interface{}{
+		{"title": "q1", "options": []string{"yes", "no"}},
+		{"title": "q2", "options": []string{"x", "y"}},
+		{"title": "q3", "options": []string{"m", "n"}},
+	}
+	surveyID, q := createTestSurvey(t, map[string]interface{}{
+		"title":     "问卷",
+		"questions": questions,
+		"branches": []map[string]interface{}{
+			{"question": 1, "if_question": 0, "if_option": "yes"},
+			{"question": 2, "if_question": 1, "if_option": "x"},
+		},
+	})
+
+	// 显示条件随问卷返回，按问题 ID 引用
+	body := decodeBody(t, doRequest(t, http.MethodGet, "/api/survey/"+surveyID, nil))
+	showIf := body["survey"].(map[string]interface{})["show_if"].(map[string]interface{})
+	if cond := showIf[q[2]].(map[string]interface{}); cond["question_id"] != q[1] || cond["option"] != "x" {
+		t.Errorf("q3 的显示条件 = %v", cond)
+	}
+
+	// q2 未显示时，依据 q2 的 q3 同样不显示
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"no"}, q[2]: {"m"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("回答未显示的 q3 状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"no"}, q[1]: {}, q[2]: nil}); rec.Code != http.StatusOK {
+		t.Errorf("未显示的问题提交空答案（%d）: %s", rec.Code, rec.Body.String())
+	}
+	// 条件成立时必须回答
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}, q[1]: {"x"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("漏答 q3 状态码 = %d，期望 400", rec.Code)
+	}
+	if rec := submitSurvey(t, surveyID, map[string][]string{q[0]: {"yes"}, q[1]: {"x"}, q[2]: {"n"}}); rec.Code != http.StatusOK {
+		t.Fatalf("提交失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if counts := []int{mustGet(t, q[0]).VoterCount, mustGet(t, q[1]).VoterCount, mustGet(t, q[2]).VoterCount}; counts[0] != 2 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("各问题人数 = %v，期望 [2 1 1]", counts)
+	}
+
+	for _, branch := range []map[string]interface{}{
+		{"question": 1, "if_question": 0, "if_option": "maybe"},
+		{"question": 5, "if_question": 0, "if_option": "yes"},
+		{"question": 1, "if_question": 1, "if_option": "x"},
+	} {
+		rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{"title": "问卷", "questions": questions, "branches": []map[string]interface{}{branch}})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("条件 %v 状态码 = %d，期望 400", branch, rec.Code)
+		}
+	}
+	rec := doRequest(t, http.MethodPost, "/api/create-survey", map[string]interface{}{"title": "问卷", "questions": questions, "branches": []map[string]interface{}{
+		{"question": 2, "if_question": 0, "if_option": "yes"},
+		{"question": 2, "if_question": 1, "if_option": "x"},
+	}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("同一问题两个条件状态码 = %d，期望 400", rec.Code)
+	}
+}
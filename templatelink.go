@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errInvalidTemplateLink = errors.New("invalid template link")
+
+// newTemplateLink 把投票配置编码为可分享的模板：base64url(JSON).签名。
+// 只包含设置，不含票数、短链接、定时公布时间，也不含回调地址、通知邮箱和创建者等不公开的信息
+func newTemplateLink(poll *Poll) (string, error) {
+	config := templateConfigFromPoll(poll)
+	config.WebhookURL = ""
+	config.NotifyEmail = ""
+	config.CreatorID = ""
+	config.ResultsVisibleAt = nil
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signString("template-link."+payload), nil
+}
+
+// parseTemplateLink 校验模板的签名并解出投票配置，被篡改或格式不对时返回 errInvalidTemplateLink
+func parseTemplateLink(link string) (*CreatePollRequest, error) {
+	payload, signature, ok := strings.Cut(strings.TrimSpace(link), ".")
+	if !ok || !verifyString("template-link."+payload, signature) {
+		return nil, errInvalidTemplateLink
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidTemplateLink
+	}
+	var config CreatePollRequest
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errInvalidTemplateLink
+	}
+	return &config, nil
+}
+
+// apiTemplateLinkHandler GET /api/poll/{id}/as-template 返回投票设置的可分享模板，
+// 他人可用 POST /api/create-from-template-link 创建相同设置的新投票。模板含有隐藏选项、
+// 跳转地址和分组限制等不公开的设置，只有管理员和持投票管理令牌的创建者可以获取
+func apiTemplateLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if !canManage(poll, r) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "only the poll owner can share its setup",
+		})
+		return
+	}
+	link, err := newTemplateLink(poll)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"template": link,
+	})
+}
+
+// apiCreateFromTemplateLinkHandler POST /api/create-from-template-link 用分享的模板创建新投票，
+// 请求体 {"template": "...", "title": "...", "slug": "..."}，标题和短链接可选
+func apiCreateFromTemplateLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var body struct {
+		Template string `json:"template"`
+		Title    string `json:"title"`
+		Slug     string `json:"slug"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	req, err := parseTemplateLink(body.Template)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if body.Title != "" {
+		req.Title = body.Title
+	}
+	req.Slug = body.Slug
+	req.creatorIP = hashVoterKey(clientIP(r))
+	if !isAdmin(r) {
+		req.maxPolls = cfg.MaxPollsPerCreator
+	}
+
+	poll, err := createPoll(req)
+	if err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"success":      true,
+		"poll_id":      poll.ID,
+		"title":        poll.Title,
+		"manage_token": poll.ManageToken,
+	}
+	if poll.JoinCode != "" {
+		resp["join_code"] = poll.JoinCode
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
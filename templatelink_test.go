@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// getTemplateLink 读取投票设置的模板，返回响应
+func getTemplateLink(t *testing.T, pollID string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/as-template", nil, headers...)
+}
+
+func TestTemplateLinkRoundTrip(t *testing.T) {
+	setupTest(t)
+	pollID, manageToken := createTestPoll(t, map[string]interface{}{
+		"title":           "周会时间",
+		"options":         []string{"周一", "周三", "周五", "都不行"},
+		"multi_select":    true,
+		"min_choices":     1,
+		"max_choices":     2,
+		"option_colors":   map[string]string{"周一": "#ff0000"},
+		"option_capacity": map[string]int{"周五": 3},
+		"hidden_options":  []string{"都不行"},
+		"allow_write_ins": true,
+		"percent_mode":    PercentOfSelections,
+		"slug":            "weekly",
+		"notify_email":    "owner@example.com",
+	})
+	mustVote(t, pollID, "周一")
+
+	// 只有持管理令牌的创建者和管理员能获取模板
+	if rec := getTemplateLink(t, pollID); rec.Code != http.StatusForbidden {
+		t.Errorf("没有管理令牌状态码 = %d，期望 403", rec.Code)
+	}
+	_, otherToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := getTemplateLink(t, pollID, manageTokenHeader, otherToken); rec.Code != http.StatusForbidden {
+		t.Errorf("其他投票的管理令牌状态码 = %d，期望 403", rec.Code)
+	}
+	if rec := getTemplateLink(t, pollID, adminHeader...); rec.Code != http.StatusOK {
+		t.Errorf("管理员获取模板状态码 = %d，期望 200", rec.Code)
+	}
+	rec := getTemplateLink(t, "weekly", manageTokenHeader, manageToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("创建者获取模板失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	link := decodeBody(t, rec)["template"].(string)
+	if strings.ContainsAny(link, "+/=") {
+		t.Errorf("模板 %q 不是 URL 安全的", link)
+	}
+
+	rec = doRequest(t, http.MethodPost, "/api/create-from-template-link", map[string]interface{}{"template": link, "title": "下周的周会"})
+	body := decodeBody(t, rec)
+	if body["success"] != true {
+		t.Fatalf("用模板创建失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	if token, _ := body["manage_token"].(string); token == "" || token == manageToken {
+		t.Errorf("新投票的 manage_token = %v", body["manage_token"])
+	}
+
+	orig, copied := mustGet(t, pollID), mustGet(t, body["poll_id"].(string))
+	if copied.ID == orig.ID || copied.Title != "下周的周会" {
+		t.Errorf("新投票 id=%s title=%q", copied.ID, copied.Title)
+	}
+	for name, pair := range map[string][2]interface{}{
+		"options":           {orig.Options, copied.Options},
+		"multi_select":      {orig.MultiSelect, copied.MultiSelect},
+		"min_choices":       {orig.MinChoices, copied.MinChoices},
+		"max_choices":       {orig.MaxChoices, copied.MaxChoices},
+		"option_colors":     {orig.OptionColors, copied.OptionColors},
+		"option_capacity":   {orig.OptionCapacity, copied.OptionCapacity},
+		"hidden_options":    {orig.HiddenOptions, copied.HiddenOptions},
+		"allow_write_ins":   {orig.AllowWriteIns, copied.AllowWriteIns},
+		"percent_mode":      {orig.PercentMode, copied.PercentMode},
+		"anonymous":         {orig.Anonymous, copied.Anonymous},
+		"result_visibility": {orig.ResultVisibility, copied.ResultVisibility},
+	} {
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			t.Errorf("%s: 原投票 %v，新投票 %v", name, pair[0], pair[1])
+		}
+	}
+	// 只复制设置，不含票数、短链接和通知邮箱
+	if copied.VoterCount != 0 || copied.Votes["周一"] != 0 || copied.Slug != "" || copied.NotifyEmail != "" {
+		t.Errorf("新投票带上了数据: voter_count=%d votes=%v slug=%q notify_email=%q", copied.VoterCount, copied.Votes, copied.Slug, copied.NotifyEmail)
+	}
+}
+
+func TestTemplateLinkRejectsTampering(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	link := decodeBody(t, getTemplateLink(t, pollID, adminHeader...))["template"].(string)
+	payload, signature, _ := strings.Cut(link, ".")
+
+	// 换一个合法的载荷但保留原签名
+	other, _ := createTestPoll(t, map[string]interface{}{"title": "另一个", "options": []string{"x", "y"}})
+	otherPayload, _, _ := strings.Cut(decodeBody(t, getTemplateLink(t, other, adminHeader...))["template"].(string), ".")
+
+	for name, tampered := range map[string]string{
+		"替换载荷": otherPayload + "." + signature,
+		"改动签名": payload + "." + strings.Repeat("0", len(signature)),
+		"没有签名": payload,
+		"空模板":  "",
+	} {
+		rec := doRequest(t, http.MethodPost, "/api/create-from-template-link", map[string]interface{}{"template": tampered})
+		if rec.Code != http.StatusBadRequest || decodeBody(t, rec)["error"] != errInvalidTemplateLink.Error() {
+			t.Errorf("%s: 状态码 = %d，响应 %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if n := pollCount(t); n != 2 {
+		t.Errorf("有 %d 个投票，被篡改的模板不应创建投票", n)
+	}
+}
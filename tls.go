@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled 是否配置了证书文件或自动证书
+func (c *Config) tlsEnabled() bool {
+	return c.TLSCert != "" || len(c.AutocertDomains) > 0
+}
+
+// validateTLS 证书文件和自动证书只能二选一，证书和私钥必须成对配置
+func (c *Config) validateTLS() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	if c.TLSCert != "" && len(c.AutocertDomains) > 0 {
+		return errors.New("-tls-cert and -autocert-domains are mutually exclusive")
+	}
+	return nil
+}
+
+// newAutocertManager 向 Let's Encrypt 申请并自动续期证书，只为白名单中的域名签发
+func newAutocertManager(c *Config) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.AutocertDomains...),
+		Cache:      autocert.DirCache(c.AutocertCacheDir),
+		Email:      c.AutocertEmail,
+	}
+}
+
+// httpsRedirectHandler 把 HTTP 请求 301 重定向到 HTTPS 的同一地址，httpsAddr 为 HTTPS 监听地址，
+// 端口不是 443 时保留在重定向地址中
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing Host header", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 地址
+		}
+		if port != "" && port != "443" {
+			host += ":" + port
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// serveTLS 在 TLSAddr 上提供 HTTPS，并在 HTTPRedirectAddr 上监听 HTTP：
+// 使用自动证书时该监听同时响应 ACME HTTP-01 验证，其余请求重定向到 HTTPS
+func serveTLS(handler http.Handler) error {
+	srv := &http.Server{Addr: cfg.TLSAddr, Handler: handler}
+	redirect := httpsRedirectHandler(cfg.TLSAddr)
+	if len(cfg.AutocertDomains) > 0 {
+		m := newAutocertManager(&cfg)
+		srv.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect)
+	} else {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.HTTPRedirectAddr != "" {
+		go func() {
+			log.Printf("HTTP 重定向监听在 %s", cfg.HTTPRedirectAddr)
+			if err := http.ListenAndServe(cfg.HTTPRedirectAddr, redirect); err != nil {
+				log.Printf("HTTP 重定向监听失败: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("服务器启动在 https://%s", cfg.TLSAddr)
+	// 自动证书时证书由 TLSConfig.GetCertificate 提供，文件参数为空
+	return srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	for _, tc := range []struct {
+		httpsAddr, host, path, want string
+	}{
+		{":443", "vote.example.com", "/poll/abc?token=x", "https://vote.example.com/poll/abc?token=x"},
+		{":443", "vote.example.com:80", "/", "https://vote.example.com/"},
+		{":8443", "vote.example.com:8080", "/api/polls", "https://vote.example.com:8443/api/polls"},
+		{"0.0.0.0:443", "[::1]:80", "/", "https://[::1]/"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		httpsRedirectHandler(tc.httpsAddr).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tc.want {
+			t.Errorf("%s%s（HTTPS %s）: %d %q，期望 301 %q", tc.host, tc.path, tc.httpsAddr, rec.Code, rec.Header().Get("Location"), tc.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = ""
+	rec := httptest.NewRecorder()
+	httpsRedirectHandler(":443").ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("没有 Host 时状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	for _, tc := range []struct {
+		cfg     Config
+		enabled bool
+		valid   bool
+	}{
+		{Config{}, false, true},
+		{Config{TLSCert: "cert.pem", TLSKey: "key.pem"}, true, true},
+		{Config{AutocertDomains: []string{"vote.example.com"}}, true, true},
+		{Config{TLSCert: "cert.pem"}, true, false},
+		{Config{TLSKey: "key.pem"}, false, false},
+		{Config{TLSCert: "cert.pem", TLSKey: "key.pem", AutocertDomains: []string{"vote.example.com"}}, true, false},
+	} {
+		if got := tc.cfg.tlsEnabled(); got != tc.enabled {
+			t.Errorf("%+v: tlsEnabled = %v，期望 %v", tc.cfg, got, tc.enabled)
+		}
+		if err := tc.cfg.validateTLS(); (err == nil) != tc.valid {
+			t.Errorf("%+v: validateTLS = %v", tc.cfg, err)
+		}
+	}
+}
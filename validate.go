@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 一次校验中累积的全部字段错误
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Field + ": " + e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add 记录一个字段错误
+func (v *ValidationErrors) Add(field, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// parseOptionsText 把粘贴的多行文本拆成选项：每行一个，去掉首尾空白、空行和重复项
+func parseOptionsText(text string) []string {
+	var options []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		options = append(options, line)
+	}
+	return options
+}
+
+// applyOptionsText 未提供 options 时用 options_text 拆出的选项代替。两者都提供时保留原样，由 Validate 报错
+func (req *CreatePollRequest) applyOptionsText() {
+	if req.OptionsText != "" && len(req.Options) == 0 {
+		req.Options = parseOptionsText(req.OptionsText)
+		req.OptionsText = ""
+	}
+}
+
+// Validate 校验创建请求，返回所有字段错误而不是在第一个错误处停止
+func (req *CreatePollRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(req.Title) == "" {
+		errs.Add("title", "title is required")
+	} else if blocklist.Contains(req.Title) {
+		errs.Add("title", "title contains a blocked word")
+	}
+
+	if req.OptionsText != "" && len(req.Options) > 0 {
+		errs.Add("options_text", "provide either options or options_text, not both")
+	}
+	if len(req.Options) < 2 {
+		errs.Add("options", "at least 2 options are required")
+	}
+	// 严格模式下忽略大小写和多余空白判断重复，避免 "Yes" 和 "yes " 分走选票
+	seen := make(map[string]string, len(req.Options))
+	for i, opt := range req.Options {
+		key := opt
+		if cfg.StrictOptions {
+			key = normalizeWriteIn(opt)
+		}
+		first, dup := seen[key]
+		if strings.TrimSpace(opt) == "" {
+			errs.Add(fmt.Sprintf("options[%d]", i), "option must not be empty")
+		} else if strings.Contains(opt, "|||") {
+			// 选项列表以 "|||" 拼接存储，含分隔符的选项会被拆成多个
+			errs.Add(fmt.Sprintf("options[%d]", i), "option must not contain %q", "|||")
+		} else if dup && first == opt {
+			errs.Add(fmt.Sprintf("options[%d]", i), "duplicate option %q", opt)
+		} else if dup {
+			errs.Add(fmt.Sprintf("options[%d]", i), "option %q differs from %q only in case or spacing", opt, first)
+		} else if blocklist.Contains(opt) {
+			errs.Add(fmt.Sprintf("options[%d]", i), "option contains a blocked word")
+		}
+		if !dup {
+			seen[key] = opt
+		}
+	}
+
+	minChoices, maxChoices := req.choiceLimits()
+	if minChoices < 0 {
+		errs.Add("min_choices", "min_choices must not be negative")
+	}
+	if maxChoices < 0 {
+		errs.Add("max_choices", "max_choices must not be negative")
+	}
+	if maxChoices > 0 && minChoices > maxChoices {
+		errs.Add("min_choices", "min_choices must not exceed max_choices")
+	}
+	if minChoices > len(req.Options) {
+		errs.Add("min_choices", "min_choices must not exceed the number of options")
+	}
+	if maxChoices > len(req.Options) {
+		errs.Add("max_choices", "max_choices must not exceed the number of options")
+	}
+
+	req.validateGroupLimits(&errs)
+	switch req.Kind {
+	case "", KindPoll:
+	case KindSchedule:
+		req.validateSlots(&errs)
+	default:
+		errs.Add("kind", "kind must be %q or %q", KindPoll, KindSchedule)
+	}
+
+	if req.WebhookURL != "" && !isHTTPURL(req.WebhookURL) {
+		errs.Add("webhook_url", "webhook_url must be an http(s) URL")
+	}
+	if req.RedirectURL != "" && !isHTTPURL(req.RedirectURL) {
+		errs.Add("redirect_url", "redirect_url must be an http(s) URL")
+	}
+	if req.NotifyEmail != "" && !isEmailAddress(req.NotifyEmail) {
+		errs.Add("notify_email", "notify_email must be a plain e-mail address")
+	}
+	if req.ExpectedVoters < 0 {
+		errs.Add("expected_voters", "expected_voters must not be negative")
+	}
+	if len(req.CreatorID) > maxCreatorIDLength {
+		errs.Add("creator_id", "creator_id must be at most %d bytes", maxCreatorIDLength)
+	}
+	if req.AccessMode != "" && req.AccessMode != AccessPublic && req.AccessMode != AccessAllowlist {
+		errs.Add("access_mode", "access_mode must be %q or %q", AccessPublic, AccessAllowlist)
+	}
+	if req.PercentMode != "" && req.PercentMode != PercentOfVoters && req.PercentMode != PercentOfSelections {
+		errs.Add("percent_mode", "percent_mode must be %q or %q", PercentOfVoters, PercentOfSelections)
+	}
+	if req.ResultVisibility != "" && req.ResultVisibility != ResultVisibilityPublic && req.ResultVisibility != ResultVisibilityVoters && req.ResultVisibility != ResultVisibilityOwner {
+		errs.Add("result_visibility", "result_visibility must be %q, %q or %q", ResultVisibilityPublic, ResultVisibilityVoters, ResultVisibilityOwner)
+	}
+	if req.OptionOrder != "" && req.OptionOrder != OptionOrderFixed && req.OptionOrder != OptionOrderVotes {
+		errs.Add("option_order", "option_order must be %q or %q", OptionOrderFixed, OptionOrderVotes)
+	}
+	if req.CloseAfterFirstVote < 0 {
+		errs.Add("close_after_first_vote_seconds", "close_after_first_vote_seconds must not be negative")
+	}
+	if req.Slug != "" {
+		if err := validateSlug(req.Slug); err != nil {
+			errs.Add("slug", "%s", err.Error())
+		}
+	}
+	if _, err := normalizeOptionColors(req.Options, req.OptionColors); err != nil {
+		errs.Add("option_colors", "%s", strings.TrimPrefix(err.Error(), "option_colors: "))
+	}
+	for opt, capacity := range req.OptionCapacity {
+		if !slices.Contains(req.Options, opt) {
+			errs.Add("option_capacity", "unknown option %q", opt)
+		} else if capacity < 0 {
+			errs.Add("option_capacity", "capacity for option %q must not be negative", opt)
+		} else if capacity > 0 && req.InitialVotes[opt] > capacity {
+			errs.Add("option_capacity", "initial votes for option %q exceed its capacity", opt)
+		}
+	}
+	for _, opt := range req.HiddenOptions {
+		if !slices.Contains(req.Options, opt) {
+			errs.Add("hidden_options", "unknown option %q", opt)
+		}
+	}
+	if len(req.HiddenOptions) > 0 && len(req.HiddenOptions) >= len(req.Options) {
+		errs.Add("hidden_options", "at least one option must stay visible")
+	}
+	if _, err := checkInitialVotes(req.Options, req.MultiSelect, req.InitialVotes, req.InitialVoterCount); err != nil {
+		field := "initial_voter_count"
+		if strings.HasPrefix(err.Error(), "initial_votes: ") {
+			field = "initial_votes"
+		}
+		errs.Add(field, "%s", strings.TrimPrefix(err.Error(), "initial_votes: "))
+	}
+
+	return errs
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateReturnsAllFieldErrors(t *testing.T) {
+	setupTest(t)
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{
+		"title":   "",
+		"options": []string{"only one"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("状态码 = %d，期望 400", rec.Code)
+	}
+	var resp struct {
+		Success bool         `json:"success"`
+		Errors  []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Success || len(resp.Errors) != 2 {
+		t.Fatalf("errors = %+v，期望两个字段错误", resp.Errors)
+	}
+	if resp.Errors[0].Field != "title" || resp.Errors[1].Field != "options" {
+		t.Errorf("字段 = %s, %s，期望 title, options", resp.Errors[0].Field, resp.Errors[1].Field)
+	}
+	for _, e := range resp.Errors {
+		if e.Message == "" {
+			t.Errorf("%s 缺少错误说明", e.Field)
+		}
+	}
+}
+
+func TestValidateAcceptsValidRequest(t *testing.T) {
+	req := CreatePollRequest{Title: "t", Options: []string{"a", "b"}}
+	if errs := req.Validate(); len(errs) != 0 {
+		t.Errorf("合法的请求报错: %v", errs)
+	}
+}
+
+func TestRedirectURL(t *testing.T) {
+	setupTest(t)
+	const target = "https://example.com/thanks?from=vote"
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "redirect_url": target})
+	body := decodeBody(t, castVote(t, pollID, "a"))
+	if body["success"] != true || body["redirect_url"] != target {
+		t.Errorf("投票响应 = %v，期望 redirect_url %s", body, target)
+	}
+
+	plain, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if body := decodeBody(t, castVote(t, plain, "a")); body["redirect_url"] != nil {
+		t.Errorf("没有设置跳转地址时返回了 redirect_url: %v", body)
+	}
+
+	for _, url := range []string{"javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,hi", "//evil.example", "/relative", "ftp://example.com"} {
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "redirect_url": url})
+		var resp struct {
+			Errors []FieldError `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusBadRequest || len(resp.Errors) != 1 || resp.Errors[0].Field != "redirect_url" {
+			t.Errorf("%q: 状态码 = %d，errors = %+v，期望 400 和 redirect_url 字段错误", url, rec.Code, resp.Errors)
+		}
+	}
+}
+
+func TestParseOptionsText(t *testing.T) {
+	got := parseOptionsText("  Apple \r\n\nBanana\n\n   \nApple\nCherry\nbanana\n")
+	if want := []string{"Apple", "Banana", "Cherry", "banana"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOptionsText = %q，期望 %q", got, want)
+	}
+	if got := parseOptionsText("\n \n"); got != nil {
+		t.Errorf("空文本 = %q", got)
+	}
+}
+
+func TestCreateWithOptionsText(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options_text": "红\n\n绿\n红\n 蓝 \n"})
+	if got := mustGet(t, pollID).Options; !reflect.DeepEqual(got, []string{"红", "绿", "蓝"}) {
+		t.Errorf("options = %q", got)
+	}
+
+	for name, req := range map[string]map[string]interface{}{
+		"options_text": {"title": "t", "options": []string{"a", "b"}, "options_text": "c\nd"},
+		"options":      {"title": "t", "options_text": "only\n\nonly\n"},
+	} {
+		rec := doRequest(t, http.MethodPost, "/api/create-poll", req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"`+name+`"`) {
+			t.Errorf("%v（%d）: %s，期望 %s 字段错误", req, rec.Code, rec.Body.String(), name)
+		}
+	}
+}
+
+func TestStrictOptions(t *testing.T) {
+	setupTest(t)
+	// optionErrors 校验选项，返回 options[i] 字段的错误说明
+	optionErrors := func(options ...string) map[string]string {
+		req := CreatePollRequest{Title: "t", Options: options}
+		got := make(map[string]string)
+		for _, e := range req.Validate() {
+			if strings.HasPrefix(e.Field, "options[") {
+				got[e.Field] = e.Message
+			}
+		}
+		return got
+	}
+
+	// 默认模式只拒绝完全相同的选项
+	if errs := optionErrors("Yes", "yes", "YES "); len(errs) != 0 {
+		t.Errorf("默认模式下大小写不同的选项被拒绝: %v", errs)
+	}
+	if errs := optionErrors("Yes", "No", "Yes"); errs["options[2]"] != `duplicate option "Yes"` {
+		t.Errorf("默认模式下重复选项的错误 = %v", errs)
+	}
+
+	cfg.StrictOptions = true
+	for _, tc := range []struct {
+		options []string
+		want    map[string]string
+	}{
+		{[]string{"Yes", "yes"}, map[string]string{"options[1]": `option "yes" differs from "Yes" only in case or spacing`}},
+		{[]string{"New York", "  new   york "}, map[string]string{"options[1]": `option "  new   york " differs from "New York" only in case or spacing`}},
+		{[]string{"Yes", "No", "Yes"}, map[string]string{"options[2]": `duplicate option "Yes"`}},
+		{[]string{"Yes", "No", "Maybe"}, map[string]string{}},
+	} {
+		if got := optionErrors(tc.options...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("严格模式 %q: 错误 = %v，期望 %v", tc.options, got, tc.want)
+		}
+	}
+
+	rec := doRequest(t, http.MethodPost, "/api/create-poll", map[string]interface{}{"title": "t", "options": []string{"Yes", "yes"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("严格模式下创建 Yes/yes 状态码 = %d，期望 400", rec.Code)
+	}
+
+	// 重命名选项同样按严格模式检查
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"Yes", "No"}})
+	if rec := renameOption(t, pollID, "No", "yes", adminHeader...); rec.Code != http.StatusBadRequest {
+		t.Errorf("严格模式下重命名为 yes 状态码 = %d，期望 400", rec.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// seenViews 记录窗口期内已计数的浏览（投票 ID + 投票人标识），同一访问者在窗口期内只计一次
+var seenViews = newReplayGuard()
+
+// pendingViews 尚未写完的后台浏览计数，关闭数据库前等待
+var pendingViews sync.WaitGroup
+
+// IncrementViews 浏览次数加一
+func (ps *PollStore) IncrementViews(pollID string) error {
+	_, err := ps.db.Exec(`UPDATE polls SET views = views + 1 WHERE id = ?`, pollID)
+	return err
+}
+
+// recordView 记录一次投票页浏览，issuedID 为本次请求下发的投票人标识。在后台写库，不阻塞页面渲染
+func recordView(r *http.Request, pollID, issuedID string) {
+	// 首次访问时没有 Cookie，按本次下发的标识记录，带着新 Cookie 再次打开时不会重复计数。
+	// 不按 IP 去重，否则同一出口 IP（公司、校园网）后的不同访问者只计一次
+	id := voterID(r)
+	if id == "" {
+		id = issuedID
+	}
+	if !seenViews.Use(pollID+":"+id, time.Now().Add(cfg.ViewWindow)) {
+		return
+	}
+	s := store
+	pendingViews.Add(1)
+	go func() {
+		defer pendingViews.Done()
+		if err := s.IncrementViews(pollID); err != nil {
+			log.Printf("记录浏览 %s 失败: %v", pollID, err)
+		}
+	}()
+}
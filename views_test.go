@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// waitForViews 等待后台写入的浏览次数达到 want，超时后返回最后读到的值
+func waitForViews(t *testing.T, pollID string, want int) int {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		views := mustGet(t, pollID).Views
+		if views >= want || time.Now().After(deadline) {
+			return views
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestViewsCountedOncePerVisitor(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+
+	// 首次访问没有 Cookie，带着下发的 Cookie 再次打开不重复计数
+	rec := doRequest(t, http.MethodGet, "/poll/"+pollID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d", rec.Code)
+	}
+	var issued string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == voterCookieName {
+			issued = c.Value
+		}
+	}
+	if issued == "" {
+		t.Fatal("首次访问没有下发投票人 Cookie")
+	}
+	if got := waitForViews(t, pollID, 1); got != 1 {
+		t.Fatalf("首次访问后 views = %d，期望 1", got)
+	}
+	for i := 0; i < 3; i++ {
+		doRequest(t, http.MethodGet, "/poll/"+pollID, nil, "Cookie", voterCookieName+"="+issued)
+	}
+
+	// 另一位访问者计数
+	other := randomHex(16)
+	doRequest(t, http.MethodGet, "/poll/"+pollID, nil, "Cookie", voterCookieName+"="+other)
+	doRequest(t, http.MethodGet, "/poll/"+pollID, nil, "Cookie", voterCookieName+"="+other)
+	if got := waitForViews(t, pollID, 2); got != 2 {
+		t.Fatalf("两位访问者 views = %d，期望 2", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := mustGet(t, pollID).Views; got != 2 {
+		t.Errorf("窗口期内重复打开被重复计数: views = %d", got)
+	}
+
+	// 浏览次数在票数接口中公开
+	counts := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil))
+	if counts["views"] != float64(2) {
+		t.Errorf("counts views = %v，期望 2", counts["views"])
+	}
+
+	// 窗口期过后同一访问者再次计数
+	cfg.ViewWindow = time.Millisecond
+	later := randomHex(16)
+	doRequest(t, http.MethodGet, "/poll/"+pollID, nil, "Cookie", voterCookieName+"="+later)
+	if got := waitForViews(t, pollID, 3); got != 3 {
+		t.Fatalf("views = %d，期望 3", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	doRequest(t, http.MethodGet, "/poll/"+pollID, nil, "Cookie", voterCookieName+"="+later)
+	if got := waitForViews(t, pollID, 4); got != 4 {
+		t.Errorf("窗口期过后 views = %d，期望 4", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ValidateVote 按真实投票的流程检查一张选票，但不写入任何数据：在事务中执行与 AddVote 相同的 addVoteTx 并始终回滚，
+// 因此选项、选择数量、投票结束、名单令牌已用、同一 IP 已投、名额已满等情况返回的错误都与此刻真实投票一致
+func (ps *PollStore) ValidateVote(pollID string, options []string, writeIn string, voter Voter) error {
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 不提交，defer 中的 Rollback 撤销 addVoteTx 的全部写入
+	_, err = addVoteTx(tx, pollID, options, writeIn, voter, "")
+	return err
+}
+
+// apiVoteValidateHandler 试投：请求体与 /api/vote 相同，返回 {"valid": true} 或 {"valid": false, "errors": [...]}，
+// 不计票。工作量证明、投票页令牌等一次性的防刷检查不在这里执行，以免消耗令牌
+func apiVoteValidateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if !checkCSRF(w, r) {
+		return
+	}
+
+	var req VoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// 不签发新的投票人 Cookie，试投不应改变访问者的状态
+	voter := Voter{Token: req.Token, ID: voterID(r), Name: req.Name, IP: clientIP(r)}
+	err := store.ValidateVote(req.PollID, req.Options, req.WriteIn, voter)
+	if poll, getErr := store.Get(req.PollID); err == nil && getErr == nil && poll.ConfirmVote {
+		// 与 /api/vote 一致：需要两步确认的投票不能直接投
+		err = errConfirmRequired
+	}
+
+	resp := map[string]interface{}{
+		"success": true,
+		"valid":   err == nil,
+		"errors":  []string{},
+	}
+	if err != nil {
+		resp["errors"] = []string{err.Error()}
+		var fullErr *OptionsFullError
+		if errors.As(err, &fullErr) {
+			resp["full_options"] = fullErr.Options
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// validateVote 试投，返回响应体
+func validateVote(t *testing.T, req map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/api/vote/validate", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("试投状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Set-Cookie") != "" {
+		t.Errorf("试投签发了 Cookie: %s", rec.Header().Get("Set-Cookie"))
+	}
+	return decodeBody(t, rec)
+}
+
+// pollState 票数、投票人数和投票记录数，用于确认试投没有写入数据
+func pollState(t *testing.T, pollID string) []interface{} {
+	t.Helper()
+	poll := mustGet(t, pollID)
+	var events int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM vote_events WHERE poll_id = ?`, pollID).Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+	return []interface{}{poll.Votes, poll.VoterCount, events}
+}
+
+func TestValidateVoteMatchesRealVote(t *testing.T) {
+	setupTest(t)
+	for _, tc := range []struct {
+		name  string
+		poll  map[string]interface{}
+		setup func(pollID string)
+		vote  map[string]interface{}
+	}{
+		{name: "不存在的选项", poll: map[string]interface{}{}, vote: map[string]interface{}{"options": []string{"z"}}},
+		{name: "需要两步确认", poll: map[string]interface{}{"confirm_vote": true}, vote: map[string]interface{}{"options": []string{"a"}}},
+		{name: "没有选择", poll: map[string]interface{}{}, vote: map[string]interface{}{"options": []string{}}},
+		{
+			name: "少于最少选择数",
+			poll: map[string]interface{}{"multi_select": true, "min_choices": 2, "max_choices": 3},
+			vote: map[string]interface{}{"options": []string{"a"}},
+		},
+		{
+			name:  "投票已结束",
+			poll:  map[string]interface{}{},
+			setup: func(pollID string) { doRequest(t, http.MethodPost, "/api/close-poll/"+pollID, nil, adminHeader...) },
+			vote:  map[string]interface{}{"options": []string{"a"}},
+		},
+		{
+			name:  "同一 IP 已投票",
+			poll:  map[string]interface{}{"ip_limit": true},
+			setup: func(pollID string) { mustVote(t, pollID, "a") },
+			vote:  map[string]interface{}{"options": []string{"b"}},
+		},
+		{
+			name:  "名额已满",
+			poll:  map[string]interface{}{"option_capacity": map[string]int{"a": 1}},
+			setup: func(pollID string) { mustVote(t, pollID, "a") },
+			vote:  map[string]interface{}{"options": []string{"a"}},
+		},
+		{
+			name: "名单令牌已使用",
+			poll: map[string]interface{}{"access_mode": AccessAllowlist},
+			setup: func(pollID string) {
+				doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/allowed-voters", map[string]interface{}{"voters": []string{"alice-token"}}, adminHeader...)
+				if rec := voteWithToken(t, pollID, "alice-token", "a"); rec.Code != http.StatusOK {
+					t.Fatalf("名单投票失败（%d）: %s", rec.Code, rec.Body.String())
+				}
+			},
+			vote: map[string]interface{}{"options": []string{"a"}, "token": "alice-token"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := map[string]interface{}{"title": "t", "options": []string{"a", "b", "c"}}
+			for k, v := range tc.poll {
+				req[k] = v
+			}
+			pollID, _ := createTestPoll(t, req)
+			if tc.setup != nil {
+				tc.setup(pollID)
+			}
+			vote := map[string]interface{}{"poll_id": pollID}
+			for k, v := range tc.vote {
+				vote[k] = v
+			}
+
+			before := pollState(t, pollID)
+			result := validateVote(t, vote)
+			if result["valid"] != false {
+				t.Fatalf("试投 valid = %v，期望 false", result["valid"])
+			}
+			if after := pollState(t, pollID); !reflect.DeepEqual(before, after) {
+				t.Errorf("试投改变了数据: %v -> %v", before, after)
+			}
+
+			// 真实投票给出相同的错误
+			rec := doRequest(t, http.MethodPost, "/api/vote", vote)
+			errs, _ := result["errors"].([]interface{})
+			if body := decodeBody(t, rec); body["success"] != false || len(errs) != 1 || errs[0] != body["error"] {
+				t.Errorf("试投错误 %v 与真实投票 %v 不一致", result["errors"], body["error"])
+			}
+		})
+	}
+}
+
+func TestValidateVoteDoesNotPersist(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "ip_limit": true, "close_after_first_vote": true,
+	})
+
+	before := pollState(t, pollID)
+	for range 3 {
+		result := validateVote(t, map[string]interface{}{"poll_id": pollID, "options": []string{"a"}})
+		if result["valid"] != true || len(result["errors"].([]interface{})) != 0 {
+			t.Fatalf("有效选票试投结果 = %v", result)
+		}
+	}
+	if after := pollState(t, pollID); !reflect.DeepEqual(before, after) {
+		t.Errorf("试投改变了数据: %v -> %v", before, after)
+	}
+	if poll := mustGet(t, pollID); poll.ClosedAt != nil || poll.FirstVoteAt != nil {
+		t.Errorf("试投触发了首票后关闭: closed_at=%v first_vote_at=%v", poll.ClosedAt, poll.FirstVoteAt)
+	}
+
+	// 多次试投不占用 IP 限制，真实投票仍然成功
+	mustVote(t, pollID, "a")
+	if got := mustGet(t, pollID).Votes["a"]; got != 1 {
+		t.Errorf("a = %d，期望 1", got)
+	}
+
+	result := validateVote(t, map[string]interface{}{"poll_id": "no-such-poll", "options": []string{"a"}})
+	if result["valid"] != false {
+		t.Errorf("不存在的投票试投 valid = %v", result["valid"])
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// voterCookieName 标识浏览器投票人的 Cookie，数据库中只保存其哈希
+const voterCookieName = "voter_id"
+
+// voterCookieMaxAge 投票人 Cookie 有效期
+const voterCookieMaxAge = 365 * 24 * time.Hour
+
+// voterID 返回请求携带的投票人标识，没有时返回空串
+func voterID(r *http.Request) string {
+	c, err := r.Cookie(voterCookieName)
+	if err != nil || len(c.Value) != 32 {
+		return ""
+	}
+	return c.Value
+}
+
+// ensureVoterCookie 确保浏览器持有投票人标识，没有时签发一个新的
+func ensureVoterCookie(w http.ResponseWriter, r *http.Request) string {
+	if id := voterID(r); id != "" {
+		return id
+	}
+	id := randomHex(16)
+	http.SetCookie(w, &http.Cookie{
+		Name:     voterCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(voterCookieMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// HasVoted 该投票人标识是否已在投票中投过票
+func (ps *PollStore) HasVoted(pollID, voterID string) (bool, error) {
+	var n int
+	err := ps.db.QueryRow(`
+		SELECT COUNT(*) FROM voters WHERE poll_id = ? AND voter_hash = ?
+	`, pollID, hashVoterKey(voterID)).Scan(&n)
+	return n > 0, err
+}
+
+// TokenUsed 名单令牌是否已在投票中使用过
+func (ps *PollStore) TokenUsed(pollID, token string) (bool, error) {
+	var n int
+	err := ps.db.QueryRow(`
+		SELECT COUNT(*) FROM allowed_voters WHERE poll_id = ? AND voter_hash = ? AND used_at IS NOT NULL
+	`, pollID, hashVoterKey(token)).Scan(&n)
+	return n > 0, err
+}
+
+// requesterVoted 请求者是否已在投票中投过票：voter_id Cookie 有投票记录，或链接中的 ?token= 是已使用的名单令牌
+func requesterVoted(pollID string, r *http.Request) bool {
+	if voted := viewerHasVoted(r, pollID); voted != nil && *voted {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return false
+	}
+	used, err := store.TokenUsed(pollID, token)
+	return err == nil && used
+}
+
+// viewerHasVoted 当前访问者是否已投票；请求没有投票人标识或查询失败时返回 nil（未知）
+func viewerHasVoted(r *http.Request, pollID string) *bool {
+	id := voterID(r)
+	if id == "" {
+		return nil
+	}
+	voted, err := store.HasVoted(pollID, id)
+	if err != nil {
+		return nil
+	}
+	return &voted
+}
+
+// VoterRecord 实名投票的一条投票记录（不含所选选项）
+type VoterRecord struct {
+	Voter   string    `json:"voter"`
+	VotedAt time.Time `json:"voted_at"`
+}
+
+// ListVoters 按投票时间列出实名投票的投票人
+func (ps *PollStore) ListVoters(pollID string) ([]VoterRecord, error) {
+	rows, err := ps.db.Query(`
+		SELECT voter, voted_at FROM vote_events WHERE poll_id = ? AND voter != '' AND `+currentRoundEvents+` ORDER BY voted_at, id
+	`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []VoterRecord{}
+	for rows.Next() {
+		var v VoterRecord
+		if err := rows.Scan(&v.Voter, &v.VotedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// apiVotersHandler 管理员查看实名投票的投票人名单，匿名投票永远不公开
+func apiVotersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+	if poll.Anonymous {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "poll is anonymous",
+		})
+		return
+	}
+
+	voters, err := store.ListVoters(poll.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"voters":  voters,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// browserHeaders 带投票人 Cookie 和匹配的 CSRF 令牌的浏览器请求头
+func browserHeaders(voterID string) []string {
+	const csrf = "0123456789abcdef0123456789abcdef"
+	return []string{
+		"Cookie", voterCookieName + "=" + voterID + "; " + csrfCookieName + "=" + csrf,
+		csrfHeaderName, csrf,
+	}
+}
+
+func TestHasVoted(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	voted := randomHex(16)
+	fresh := randomHex(16)
+
+	rec := doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{"poll_id": pollID, "options": []string{"a"}}, browserHeaders(voted)...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		name    string
+		headers []string
+		want    interface{}
+	}{
+		{"已投票", browserHeaders(voted), true},
+		{"新访问者", browserHeaders(fresh), false},
+		{"没有投票人标识", nil, nil},
+	} {
+		body := decodeBody(t, doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/counts", nil, tc.headers...))
+		if got, ok := body["has_voted"]; got != tc.want || (tc.want == nil && ok) {
+			t.Errorf("%s: counts has_voted = %v，期望 %v", tc.name, got, tc.want)
+		}
+	}
+
+	// 投票页把状态交给前端脚本
+	hasVoted := regexp.MustCompile(`const hasVoted = \s*(\w+)\s*;`)
+	for id, want := range map[string]string{voted: "true", fresh: "false"} {
+		page := doRequest(t, http.MethodGet, "/poll/"+pollID, nil, browserHeaders(id)...).Body.Bytes()
+		if m := hasVoted.FindSubmatch(page); m == nil || string(m[1]) != want {
+			t.Errorf("投票页 hasVoted = %q，期望 %s", m, want)
+		}
+	}
+}
+
+// voteAs 以实名投票
+func voteAs(t *testing.T, pollID, name string, options ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{
+		"poll_id": pollID,
+		"options": options,
+		"name":    name,
+	})
+}
+
+func TestListVotersOfNamedPoll(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "anonymous": false})
+	for _, name := range []string{"张三", "李四"} {
+		if rec := voteAs(t, pollID, name, "a"); decodeBody(t, rec)["success"] != true {
+			t.Fatalf("%s 投票失败: %s", name, rec.Body.String())
+		}
+	}
+	if rec := voteAs(t, pollID, "", "b"); decodeBody(t, rec)["success"] == true {
+		t.Error("实名投票接受了没有姓名的投票")
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/voters", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("无管理令牌时状态码 = %d，期望 401", rec.Code)
+	}
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/voters", nil, adminHeader...)
+	var resp struct {
+		Voters []map[string]interface{} `json:"voters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Voters) != 2 || resp.Voters[0]["voter"] != "张三" || resp.Voters[1]["voter"] != "李四" {
+		t.Fatalf("voters = %v", resp.Voters)
+	}
+	for _, v := range resp.Voters {
+		if v["voted_at"] == nil {
+			t.Errorf("缺少投票时间: %v", v)
+		}
+		// 只列出投票人，不公开所选选项
+		if _, ok := v["options"]; ok {
+			t.Errorf("列表公开了所选选项: %v", v)
+		}
+	}
+}
+
+func TestListVotersOfAnonymousPoll(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}})
+	if rec := voteAs(t, pollID, "张三", "a"); decodeBody(t, rec)["success"] != true {
+		t.Fatalf("投票失败: %s", rec.Body.String())
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/voters", nil, adminHeader...)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("匿名投票状态码 = %d，期望 403", rec.Code)
+	}
+	// 匿名投票不保存姓名
+	var n int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM vote_events WHERE poll_id = ? AND voter != ''`, pollID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("匿名投票保存了 %d 个投票人姓名", n)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minHashSaltLength -hash-salt 的最小长度（字节）
+const minHashSaltLength = 16
+
+// setupHashSalt 准备投票人哈希的密钥。设置了 -hash-salt 时必须足够长；未设置时使用 -hash-salt-file 中的密钥，
+// 文件不存在则随机生成并写入，以后启动沿用同一个密钥。内存数据库重启后数据不保留，密钥只在内存中随机生成
+func (c *Config) setupHashSalt() error {
+	if c.HashSalt != "" {
+		if len(c.HashSalt) < minHashSaltLength {
+			return fmt.Errorf("-hash-salt (or HASH_SALT) must be at least %d characters", minHashSaltLength)
+		}
+		return nil
+	}
+	if c.Memory {
+		c.HashSalt = newHashSalt()
+		return nil
+	}
+	salt, err := loadOrCreateHashSalt(c.HashSaltFile)
+	if err != nil {
+		return err
+	}
+	c.HashSalt = salt
+	return nil
+}
+
+// newHashSalt 随机生成 32 字节的密钥，以十六进制表示
+func newHashSalt() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// loadOrCreateHashSalt 读取保存的密钥，文件不存在时生成一个并以 0600 权限写入。
+// 文件以 O_EXCL 创建，多个进程同时首次启动时只有一个写入成功，其余读取它写入的密钥
+func loadOrCreateHashSalt(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("-hash-salt or -hash-salt-file must be set")
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		salt := strings.TrimSpace(string(data))
+		if len(salt) < minHashSaltLength {
+			return "", fmt.Errorf("%s: hash salt must be at least %d characters", path, minHashSaltLength)
+		}
+		return salt, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return loadOrCreateHashSalt(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	salt := newHashSalt()
+	if _, err := f.WriteString(salt + "\n"); err != nil {
+		f.Close()
+		return "", err
+	}
+	return salt, f.Close()
+}
+
+// hashVoterKey 投票人标识、名单令牌和 IP 在数据库中保存的形式：以 -hash-salt 为密钥的 HMAC-SHA256。
+// 同一输入总得到同一结果，仍可判断重复；不加盐的 SHA-256 可以穷举全部 IPv4 地址还原，因此必须加盐
+func hashVoterKey(s string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.HashSalt))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashVoterKey(t *testing.T) {
+	setupTest(t)
+	a, b := hashVoterKey("alice-token"), hashVoterKey("alice-token")
+	if a != b {
+		t.Fatalf("同一令牌两次哈希结果不同: %s / %s", a, b)
+	}
+	if len(a) != 64 || strings.Contains(a, "alice") {
+		t.Errorf("哈希 = %q，期望 64 位十六进制", a)
+	}
+	if hashVoterKey("bob-token") == a {
+		t.Error("不同令牌的哈希相同")
+	}
+	// 不加盐的 SHA-256 可以穷举还原，保存的形式必须依赖 -hash-salt
+	cfg.HashSalt = "another-salt-0123456789"
+	if hashVoterKey("alice-token") == a {
+		t.Error("更换 -hash-salt 后哈希不变")
+	}
+}
+
+func TestSetupHashSalt(t *testing.T) {
+	for salt, valid := range map[string]bool{
+		"short":              false,
+		"0123456789abcde":    false,
+		"0123456789abcdef":   true,
+		"a-much-longer-salt": true,
+	} {
+		c := Config{HashSalt: salt}
+		if err := c.setupHashSalt(); (err == nil) != valid || c.HashSalt != salt {
+			t.Errorf("-hash-salt %q: setupHashSalt = %v，密钥 %q", salt, err, c.HashSalt)
+		}
+	}
+
+	// 未设置时首次启动生成密钥并保存，以后启动沿用
+	path := filepath.Join(t.TempDir(), "data", "hash_salt")
+	first := Config{HashSaltFile: path}
+	if err := first.setupHashSalt(); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.HashSalt) < minHashSaltLength {
+		t.Fatalf("生成的密钥 %q 太短", first.HashSalt)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("密钥文件 = %v, %v，期望权限 0600", info, err)
+	}
+	second := Config{HashSaltFile: path}
+	if err := second.setupHashSalt(); err != nil || second.HashSalt != first.HashSalt {
+		t.Errorf("再次启动的密钥 = %q（%v），期望沿用 %q", second.HashSalt, err, first.HashSalt)
+	}
+
+	// 文件中的密钥太短时报错，不覆盖
+	if err := os.WriteFile(path, []byte("short\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Config{HashSaltFile: path}).setupHashSalt(); err == nil {
+		t.Error("密钥文件内容太短时应报错")
+	}
+
+	// 内存数据库不写文件
+	memory := Config{Memory: true, HashSaltFile: filepath.Join(t.TempDir(), "hash_salt")}
+	if err := memory.setupHashSalt(); err != nil || len(memory.HashSalt) < minHashSaltLength {
+		t.Errorf("内存数据库的密钥 = %q（%v）", memory.HashSalt, err)
+	}
+	if _, err := os.Stat(memory.HashSaltFile); !os.IsNotExist(err) {
+		t.Errorf("内存数据库写入了密钥文件: %v", err)
+	}
+}
+
+// dumpDatabase 把数据库所有表的全部值拼成一个字符串，用于检查是否保存了明文
+func dumpDatabase(t *testing.T) string {
+	t.Helper()
+	rows, err := store.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	var dump strings.Builder
+	for _, table := range tables {
+		rows, err := store.db.Query(`SELECT * FROM "` + table + `"`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns, _ := rows.Columns()
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range values {
+				dump.WriteString(table + "." + columns[i] + "=" + v.String + "\n")
+			}
+		}
+		rows.Close()
+	}
+	return dump.String()
+}
+
+func TestVoterKeysHashedAtRest(t *testing.T) {
+	setupTest(t)
+	const token = "alice-secret-token"
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b"}, "access_mode": AccessAllowlist, "ip_limit": true,
+	})
+	doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/allowed-voters", map[string]interface{}{"voters": []string{token}}, adminHeader...)
+
+	rec := voteWithToken(t, pollID, token, "a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("投票失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+	var cookie string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == voterCookieName {
+			cookie = c.Value
+		}
+	}
+	if cookie == "" {
+		t.Fatal("投票没有签发投票人 Cookie")
+	}
+
+	// 令牌、IP 和投票人 Cookie 只以加盐哈希保存
+	dump := dumpDatabase(t)
+	for name, raw := range map[string]string{"名单令牌": token, "IP": "192.0.2.1", "投票人 Cookie": cookie} {
+		if strings.Contains(dump, raw) {
+			t.Errorf("数据库中保存了%s的明文 %q", name, raw)
+		}
+	}
+	for _, tc := range []struct{ query, raw string }{
+		{`SELECT COUNT(*) FROM allowed_voters WHERE poll_id = ? AND voter_hash = ?`, token},
+		{`SELECT COUNT(*) FROM voter_ips WHERE poll_id = ? AND ip_hash = ?`, "192.0.2.1"},
+		{`SELECT COUNT(*) FROM voters WHERE poll_id = ? AND voter_hash = ?`, cookie},
+	} {
+		var n int
+		if err := store.db.QueryRow(tc.query, pollID, hashVoterKey(tc.raw)).Scan(&n); err != nil || n != 1 {
+			t.Errorf("%s: 找到 %d 行（%v），期望按哈希找到 1 行", tc.query, n, err)
+		}
+	}
+
+	// 确定性的哈希仍能识别重复
+	if rec := voteWithToken(t, pollID, token, "b"); rec.Code != http.StatusForbidden {
+		t.Errorf("令牌再次投票状态码 = %d，期望 403", rec.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+)
+
+// voteSchema 描述某个投票的 VoteRequest 的 JSON Schema，供客户端提交前自行校验。
+// group 为投票人分组，该分组有单独的选择数量限制时按分组生成
+func voteSchema(poll *Poll, group string) map[string]interface{} {
+	minItems, maxItems := 1, 1
+	if poll.MultiSelect {
+		limits := poll.ChoiceLimits(group)
+		minItems = max(limits.MinChoices, 1)
+		maxItems = len(poll.Options)
+		if limits.MaxChoices > 0 {
+			maxItems = limits.MaxChoices
+		}
+	}
+
+	choices := map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string", "enum": poll.Options},
+		"uniqueItems": true,
+		"minItems":    minItems,
+		"maxItems":    maxItems,
+	}
+	// 允许弃权或自填答案时 options 也可以为空
+	options := choices
+	if poll.AllowAbstain || poll.AllowWriteIns {
+		options = map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "array", "maxItems": 0},
+				choices,
+			},
+		}
+	}
+
+	properties := map[string]interface{}{
+		"poll_id": map[string]interface{}{"const": poll.ID},
+		"options": options,
+	}
+	required := []string{"poll_id", "options"}
+
+	if poll.AccessMode == AccessAllowlist {
+		properties["token"] = map[string]interface{}{"type": "string", "minLength": 1, "description": "名单令牌或邀请令牌"}
+		required = append(required, "token")
+	}
+	if !poll.Anonymous {
+		properties["name"] = map[string]interface{}{"type": "string", "description": "实名投票的投票人姓名，名单投票可用 token 代替"}
+	}
+	if poll.AllowWriteIns {
+		description := "自填答案"
+		if !poll.MultiSelect {
+			description = "自填答案，单选投票提供时 options 必须为空"
+		}
+		properties["write_in"] = map[string]interface{}{"type": "string", "maxLength": maxWriteInLength, "description": description}
+	}
+	if cfg.MinVoteDelay > 0 {
+		properties["page_token"] = map[string]interface{}{"type": "string", "description": "打开投票页时签发的令牌"}
+		required = append(required, "page_token")
+	}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "VoteRequest: " + poll.Title,
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// apiVoteSchemaHandler 返回投票请求的 JSON Schema 和投票当前的限制条件
+func apiVoteSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+
+	// 带上邀请令牌时按令牌中的分组返回限制
+	group := inviteGroup(poll.ID, r.URL.Query().Get("token"))
+	limits := poll.ChoiceLimits(group)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"constraints": map[string]interface{}{
+			"options":         poll.Options,
+			"multi_select":    poll.MultiSelect,
+			"min_choices":     limits.MinChoices,
+			"max_choices":     limits.MaxChoices,
+			"group":           group,
+			"allow_abstain":   poll.AllowAbstain,
+			"allow_write_ins": poll.AllowWriteIns,
+			"access_mode":     poll.AccessMode,
+			"anonymous":       poll.Anonymous,
+			"closed":          poll.IsClosed(),
+		},
+		"schema": voteSchema(poll, group),
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// voteSchemaResponse GET /api/poll/{id}/vote-schema 的响应
+type voteSchemaResponse struct {
+	Constraints struct {
+		Options       []string `json:"options"`
+		MultiSelect   bool     `json:"multi_select"`
+		MinChoices    int      `json:"min_choices"`
+		MaxChoices    int      `json:"max_choices"`
+		AllowAbstain  bool     `json:"allow_abstain"`
+		AllowWriteIns bool     `json:"allow_write_ins"`
+		Anonymous     bool     `json:"anonymous"`
+		Closed        bool     `json:"closed"`
+	} `json:"constraints"`
+	Schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	} `json:"schema"`
+}
+
+func getVoteSchema(t *testing.T, pollID string) voteSchemaResponse {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/vote-schema", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp voteSchemaResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestVoteSchemaReflectsConstraints(t *testing.T) {
+	setupTest(t)
+	multi, _ := createTestPoll(t, map[string]interface{}{
+		"title": "t", "options": []string{"a", "b", "c", "d"},
+		"multi_select": true, "min_choices": 2, "max_choices": 3, "allow_write_ins": true,
+	})
+	resp := getVoteSchema(t, multi)
+	c := resp.Constraints
+	if !reflect.DeepEqual(c.Options, []string{"a", "b", "c", "d"}) || !c.MultiSelect || c.MinChoices != 2 || c.MaxChoices != 3 || !c.AllowWriteIns || c.Closed {
+		t.Errorf("多选投票的限制 = %+v", c)
+	}
+	var options struct {
+		AnyOf []struct {
+			MinItems *int                    `json:"minItems"`
+			MaxItems *int                    `json:"maxItems"`
+			Items    struct{ Enum []string } `json:"items"`
+		} `json:"anyOf"`
+	}
+	if err := json.Unmarshal(resp.Schema.Properties["options"], &options); err != nil || len(options.AnyOf) != 2 {
+		t.Fatalf("允许自填时 options 应可以为空: %s", resp.Schema.Properties["options"])
+	}
+	choices := options.AnyOf[1]
+	if *choices.MinItems != 2 || *choices.MaxItems != 3 || !reflect.DeepEqual(choices.Items.Enum, c.Options) {
+		t.Errorf("options schema = %s", resp.Schema.Properties["options"])
+	}
+	if _, ok := resp.Schema.Properties["write_in"]; !ok {
+		t.Error("允许自填时 schema 缺少 write_in")
+	}
+
+	// schema 中的字段都是 VoteRequest 实际接受的字段
+	fields := jsonFieldNames(VoteRequest{})
+	for name := range resp.Schema.Properties {
+		if !slices.Contains(fields, name) {
+			t.Errorf("schema 字段 %q 不在 VoteRequest 中", name)
+		}
+	}
+
+	single, singleToken := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "anonymous": true})
+	doRequest(t, http.MethodPost, "/api/close-poll/"+single, nil, manageTokenHeader, singleToken)
+	resp = getVoteSchema(t, single)
+	if c := resp.Constraints; c.MultiSelect || c.AllowWriteIns || !c.Anonymous || !c.Closed {
+		t.Errorf("单选投票的限制 = %+v", c)
+	}
+	var singleOptions struct{ MinItems, MaxItems int }
+	json.Unmarshal(resp.Schema.Properties["options"], &singleOptions)
+	if singleOptions.MinItems != 1 || singleOptions.MaxItems != 1 {
+		t.Errorf("单选 options schema = %s", resp.Schema.Properties["options"])
+	}
+	if _, ok := resp.Schema.Properties["name"]; ok {
+		t.Error("匿名投票的 schema 不应包含 name")
+	}
+	if !slices.Contains(resp.Schema.Required, "options") || slices.Contains(resp.Schema.Required, "token") {
+		t.Errorf("required = %v", resp.Schema.Required)
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/missing/vote-schema", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+}
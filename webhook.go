@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// webhook 事件类型
+const (
+	EventPollCreated = "poll.created"
+	EventVoteCast    = "vote.cast"
+	EventPollClosed  = "poll.closed"
+)
+
+// WebhookEvent 推送给外部系统的事件内容
+type WebhookEvent struct {
+	Event     string      `json:"event"`
+	PollID    string      `json:"poll_id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// webhookWorkers 同时投递的 goroutine 数，某个地址响应慢时不会拖住其他投票的事件
+const webhookWorkers = 4
+
+// errWebhookAddrBlocked 回调地址解析到本机、内网等非公网地址
+var errWebhookAddrBlocked = errors.New("webhook address is not a public address")
+
+type webhookJob struct {
+	url     string
+	event   string
+	body    []byte
+	attempt int // 已失败的次数
+}
+
+// WebhookDispatcher 由几个后台 goroutine 投递 webhook，队列有界。失败的事件按指数退避延后重新入队，
+// 等待期间不占用 goroutine。回调地址由创建者填写，连接时拒绝本机和内网地址，防止借此访问内部服务
+type WebhookDispatcher struct {
+	secret       string
+	client       *http.Client
+	queue        chan webhookJob
+	workers      int
+	maxRetries   int
+	retryDelay   time.Duration
+	allowPrivate bool // 允许投递到本机和内网地址
+}
+
+func NewWebhookDispatcher(secret string, queueSize int) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		secret:     secret,
+		queue:      make(chan webhookJob, queueSize),
+		workers:    webhookWorkers,
+		maxRetries: 3,
+		retryDelay: time.Second,
+	}
+	// 在连接时检查解析后的地址，域名解析到内网（包括 DNS 重绑定和重定向）同样会被拒绝；
+	// 不使用环境变量中的代理，否则检查的是代理的地址
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: d.checkDialAddr}
+	d.client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	return d
+}
+
+// Start 启动投递的 goroutine
+func (d *WebhookDispatcher) Start() {
+	for range d.workers {
+		go func() {
+			for job := range d.queue {
+				d.deliver(job)
+			}
+		}()
+	}
+}
+
+// checkDialAddr 拒绝连接本机、内网、链路本地、组播等非公网地址，-webhook-allow-private 时不检查
+func (d *WebhookDispatcher) checkDialAddr(network, address string, _ syscall.RawConn) error {
+	if d.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddrBlocked, host)
+	}
+	return nil
+}
+
+// sharedAddressSpace 运营商级 NAT 地址段（RFC 6598），不属于 IsPrivate 但同样不可从公网访问
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP 是否为公网单播地址
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// Enqueue 将事件放入队列，队列已满时丢弃并返回 false，不阻塞请求
+func (d *WebhookDispatcher) Enqueue(url string, event WebhookEvent) bool {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Println("webhook 序列化失败:", err)
+		return false
+	}
+
+	select {
+	case d.queue <- webhookJob{url: url, event: event.Event, body: body}:
+		return true
+	default:
+		log.Printf("webhook 队列已满，丢弃事件 %s (poll %s)", event.Event, event.PollID)
+		return false
+	}
+}
+
+// deliver 投递一次。失败时在 retryDelay × 2^(失败次数-1) 后重新入队，地址被拒绝时不再重试
+func (d *WebhookDispatcher) deliver(job webhookJob) {
+	err := d.post(job)
+	if err == nil {
+		return
+	}
+	job.attempt++
+	log.Printf("webhook 投递失败 (%s, 第 %d 次): %v", job.url, job.attempt, err)
+	if job.attempt > d.maxRetries || errors.Is(err, errWebhookAddrBlocked) {
+		return
+	}
+	time.AfterFunc(d.retryDelay<<(job.attempt-1), func() { d.requeue(job) })
+}
+
+// requeue 把待重试的事件放回队列，队列已满时放弃
+func (d *WebhookDispatcher) requeue(job webhookJob) {
+	select {
+	case d.queue <- job:
+	default:
+		log.Printf("webhook 队列已满，放弃重试 %s (%s)", job.event, job.url)
+	}
+}
+
+func (d *WebhookDispatcher) post(job webhookJob) error {
+	req, err := http.NewRequest(http.MethodPost, job.url, bytes.NewReader(job.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", job.event)
+	if d.secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+signPayload(d.secret, job.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signPayload 计算请求体的 HMAC-SHA256 签名（十六进制）
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhook 投票配置了回调地址时推送事件
+func notifyWebhook(poll *Poll, event string, data interface{}) {
+	if webhooks == nil || poll.WebhookURL == "" {
+		return
+	}
+	webhooks.Enqueue(poll.WebhookURL, WebhookEvent{
+		Event:     event,
+		PollID:    poll.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// startWebhookReceiver 启动接收回调的测试服务器，并让全局 webhooks 允许投递到本机地址
+func startWebhookReceiver(t *testing.T, secret string) (*httptest.Server, <-chan receivedWebhook) {
+	t.Helper()
+	received := make(chan receivedWebhook, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedWebhook{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := webhooks
+	webhooks = NewWebhookDispatcher(secret, 16)
+	webhooks.allowPrivate = true
+	webhooks.Start()
+	t.Cleanup(func() { webhooks = prev })
+	return srv, received
+}
+
+// waitWebhook 等待下一个指定类型的事件
+func waitWebhook(t *testing.T, received <-chan receivedWebhook, event string) receivedWebhook {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case hook := <-received:
+			if hook.header.Get("X-Webhook-Event") == event {
+				return hook
+			}
+		case <-timeout:
+			t.Fatalf("没有收到 %s 事件", event)
+		}
+	}
+}
+
+func TestWebhookVoteCast(t *testing.T) {
+	setupTest(t)
+	const secret = "webhook-secret"
+	srv, received := startWebhookReceiver(t, secret)
+
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":       "午饭吃什么",
+		"options":     []string{"面", "饭"},
+		"webhook_url": srv.URL,
+	})
+	mustVote(t, pollID, "面")
+
+	hook := waitWebhook(t, received, EventVoteCast)
+	if got, want := hook.header.Get("X-Webhook-Signature"), "sha256="+signPayload(secret, hook.body); got != want {
+		t.Errorf("签名 = %q，期望 %q", got, want)
+	}
+	var event struct {
+		Event  string `json:"event"`
+		PollID string `json:"poll_id"`
+		Data   struct {
+			Options    []string `json:"options"`
+			VoterCount int      `json:"voter_count"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(hook.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventVoteCast || event.PollID != pollID {
+		t.Errorf("事件 = %s/%s，期望 %s/%s", event.Event, event.PollID, EventVoteCast, pollID)
+	}
+	if len(event.Data.Options) != 1 || event.Data.Options[0] != "面" || event.Data.VoterCount != 1 {
+		t.Errorf("事件数据 = %+v", event.Data)
+	}
+}
+
+func TestWebhookVoteCastSendsAppliedOptions(t *testing.T) {
+	setupTest(t)
+	srv, received := startWebhookReceiver(t, "")
+
+	pollID, _ := createTestPoll(t, map[string]interface{}{
+		"title":        "t",
+		"options":      []string{"a", "b", "c"},
+		"multi_select": true,
+		"webhook_url":  srv.URL,
+	})
+	mustVote(t, pollID, "a", "不存在", "c")
+
+	hook := waitWebhook(t, received, EventVoteCast)
+	var event struct {
+		Data struct {
+			Options []string `json:"options"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(hook.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	// 只包含实际计入的选项，与票数一致
+	if !reflect.DeepEqual(event.Data.Options, []string{"a", "c"}) {
+		t.Errorf("事件中的选项 = %v，期望 [a c]", event.Data.Options)
+	}
+	if votes := mustGet(t, pollID).Votes; votes["a"] != 1 || votes["b"] != 0 || votes["c"] != 1 {
+		t.Errorf("votes = %v", votes)
+	}
+}
+
+func TestWebhookUnsignedWithoutSecret(t *testing.T) {
+	setupTest(t)
+	srv, received := startWebhookReceiver(t, "")
+
+	createTestPoll(t, map[string]interface{}{
+		"title":       "t",
+		"options":     []string{"a", "b"},
+		"webhook_url": srv.URL,
+	})
+	hook := waitWebhook(t, received, EventPollCreated)
+	if sig := hook.header.Get("X-Webhook-Signature"); sig != "" {
+		t.Errorf("未配置密钥时不应签名，得到 %q", sig)
+	}
+}
+
+func TestWebhookBlocksPrivateAddresses(t *testing.T) {
+	d := NewWebhookDispatcher("", 1)
+	for _, addr := range []string{"127.0.0.1:80", "10.1.2.3:443", "192.168.0.1:80", "169.254.169.254:80", "100.64.0.1:80", "[::1]:80"} {
+		if err := d.checkDialAddr("tcp", addr, nil); !errors.Is(err, errWebhookAddrBlocked) {
+			t.Errorf("%s: err = %v，期望被拒绝", addr, err)
+		}
+	}
+	if err := d.checkDialAddr("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("公网地址被拒绝: %v", err)
+	}
+
+	d.allowPrivate = true
+	if err := d.checkDialAddr("tcp", "127.0.0.1:80", nil); err != nil {
+		t.Errorf("-webhook-allow-private 时仍被拒绝: %v", err)
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	attempts := make(chan time.Time, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- time.Now()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDispatcher("", 8)
+	d.allowPrivate = true
+	d.retryDelay = 20 * time.Millisecond
+	d.maxRetries = 2
+	d.Start()
+	d.Enqueue(srv.URL, WebhookEvent{Event: EventVoteCast, PollID: "p"})
+
+	var times []time.Time
+	timeout := time.After(5 * time.Second)
+	for len(times) < 3 {
+		select {
+		case at := <-attempts:
+			times = append(times, at)
+		case <-timeout:
+			t.Fatalf("只收到 %d 次投递，期望 3 次", len(times))
+		}
+	}
+	if gap := times[2].Sub(times[1]); gap < 40*time.Millisecond {
+		t.Errorf("第二次重试间隔 %v，期望按指数退避不少于 40ms", gap)
+	}
+	select {
+	case <-attempts:
+		t.Error("超过 maxRetries 后仍在重试")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
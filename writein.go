@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxWriteInLength 自填答案的最大字符数
+const maxWriteInLength = 100
+
+var (
+	errWriteInsDisabled  = errors.New("write-ins are not allowed for this poll")
+	errWriteInTooLong    = errors.New("write-in is too long")
+	errWriteInWithOption = errors.New("choose either an option or a write-in")
+	errWriteInNotFound   = errors.New("write-in not found")
+	errWriteInSeparator  = errors.New(`write-in contains "|||" and cannot become an option`)
+)
+
+// isWriteInError 是否为投票人提交的自填答案不合法
+func isWriteInError(err error) bool {
+	return errors.Is(err, errWriteInsDisabled) || errors.Is(err, errWriteInTooLong) || errors.Is(err, errWriteInWithOption) || errors.Is(err, errWriteInBlocked)
+}
+
+// WriteInCount 某个自填答案（原文）及其票数
+type WriteInCount struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// WriteInSuggestion 建议合并的一组相似自填答案，需管理员确认后才会合并
+type WriteInSuggestion struct {
+	Into  string   `json:"into"`  // 建议保留的写法（票数最多）
+	Merge []string `json:"merge"` // 建议并入的其他写法
+	Count int      `json:"count"` // 合并后的总票数
+}
+
+// normalizeWriteIn 归一化自填答案：转小写、去掉首尾空白并合并连续空白
+func normalizeWriteIn(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// levenshtein 按字符（rune）计算编辑距离
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// similarWriteIns 两个归一化后的答案是否相似。编辑距离必须小于较短答案的长度，
+// 避免把 "ab" 和 "cd" 这类很短的答案归为一组
+func similarWriteIns(a, b string, distance int) bool {
+	if a == b {
+		return true
+	}
+	if distance <= 0 {
+		return false
+	}
+	d := levenshtein(a, b)
+	return d <= distance && d < min(len([]rune(a)), len([]rune(b)))
+}
+
+// clusterWriteIns 把归一化后相同或编辑距离不超过 distance 的答案归为一组，
+// 返回包含两种以上写法的组作为合并建议
+func clusterWriteIns(counts []WriteInCount, distance int) []WriteInSuggestion {
+	parent := make([]int, len(counts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	normalized := make([]string, len(counts))
+	for i, c := range counts {
+		normalized[i] = normalizeWriteIn(c.Text)
+	}
+	for i := range counts {
+		for j := i + 1; j < len(counts); j++ {
+			if similarWriteIns(normalized[i], normalized[j], distance) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]WriteInCount)
+	var roots []int
+	for i, c := range counts {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], c)
+	}
+
+	suggestions := []WriteInSuggestion{}
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].Count != group[j].Count {
+				return group[i].Count > group[j].Count
+			}
+			return group[i].Text < group[j].Text
+		})
+		s := WriteInSuggestion{Into: group[0].Text, Merge: []string{}}
+		for _, c := range group {
+			s.Count += c.Count
+			if c.Text != s.Into {
+				s.Merge = append(s.Merge, c.Text)
+			}
+		}
+		suggestions = append(suggestions, s)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Count > suggestions[j].Count
+	})
+	return suggestions
+}
+
+// WriteInCounts 按原文统计自填答案票数，票数多的在前
+func (ps *PollStore) WriteInCounts(pollID string) ([]WriteInCount, error) {
+	rows, err := ps.db.Query(`
+		SELECT text, COUNT(*) FROM write_ins WHERE poll_id = ? GROUP BY text ORDER BY COUNT(*) DESC, text
+	`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []WriteInCount{}
+	for rows.Next() {
+		var c WriteInCount
+		if err := rows.Scan(&c.Text, &c.Count); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// MergeWriteIns 把 from 中的写法统一改为 into，返回修改的票数
+func (ps *PollStore) MergeWriteIns(pollID, into string, from []string) (int64, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, text := range from {
+		if text == into {
+			continue
+		}
+		result, err := tx.Exec(`UPDATE write_ins SET text = ? WHERE poll_id = ? AND text = ?`, into, pollID, text)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, tx.Commit()
+}
+
+// PromoteWriteIn 把自填答案 name（原文，需与审核列表中的写法完全相同）转为正式选项并保留票数：
+// 追加到选项列表末尾，票数同时记为初始票数，重新计票时不会丢失；原自填答案记录删除。
+// 与已有选项同名时返回 errOptionExists，没有这个写法时返回 errWriteInNotFound
+func (ps *PollStore) PromoteWriteIn(pollID, name string) (*Poll, error) {
+	// 选项列表以 "|||" 拼接存储，含分隔符的写法会被拆成多个选项
+	if strings.Contains(name, "|||") {
+		return nil, errWriteInSeparator
+	}
+
+	ps.writeMu.RLock()
+	defer ps.writeMu.RUnlock()
+
+	poll, err := ps.Get(pollID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(poll.Options, name) {
+		return nil, errOptionExists
+	}
+
+	req := templateConfigFromPoll(poll)
+	req.Options = append(slices.Clone(poll.Options), name)
+	if errs := req.Validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM write_ins WHERE poll_id = ? AND text = ?`, pollID, name).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errWriteInNotFound
+	}
+
+	if err := replaceOptionsTx(tx, poll, req.Options); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO votes (poll_id, option_name, vote_count, initial_count) VALUES (?, ?, ?, ?)
+	`, pollID, name, count, count); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM write_ins WHERE poll_id = ? AND text = ?`, pollID, name); err != nil {
+		return nil, err
+	}
+	if err := logAudit(tx, pollID, "promote_write_in", fmt.Sprintf("%q (%d)", name, count)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps.Get(pollID)
+}
+
+// apiWriteInsHandler 管理员审核自填答案：列出各写法票数和相似写法的合并建议。
+// ?distance= 覆盖 -write-in-distance 配置
+func apiWriteInsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	distance := cfg.WriteInDistance
+	if v := r.URL.Query().Get("distance"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "invalid distance",
+			})
+			return
+		}
+		distance = d
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+
+	counts, err := store.WriteInCounts(poll.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"write_ins":   counts,
+		"suggestions": clusterWriteIns(counts, distance),
+	})
+}
+
+// apiMergeWriteInsHandler 管理员确认合并自填答案的写法
+func apiMergeWriteInsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		Into  string   `json:"into"`
+		Merge []string `json:"merge"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Into) == "" || len(req.Merge) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request: provide into and merge",
+		})
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+
+	merged, err := store.MergeWriteIns(poll.ID, strings.TrimSpace(req.Into), req.Merge)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"merged":  merged,
+	})
+}
+
+// apiPromoteWriteInHandler 管理员把自填答案转为正式选项，请求体 {"text": "Pizza"}
+func apiPromoteWriteInHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request: provide text",
+		})
+		return
+	}
+
+	poll, err := store.Resolve(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"error":   "poll not found",
+		})
+		return
+	}
+
+	poll, err = store.PromoteWriteIn(poll.ID, req.Text)
+	writeOptionEditResult(w, poll, err)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestClusterWriteIns(t *testing.T) {
+	counts := []WriteInCount{
+		{"Pizza", 3},
+		{"Sushi", 2},
+		{"pizza ", 1},
+		{"Pizaa", 1},
+		{"ab", 1},
+		{"cd", 1},
+	}
+
+	got := clusterWriteIns(counts, 2)
+	want := []WriteInSuggestion{{Into: "Pizza", Merge: []string{"Pizaa", "pizza "}, Count: 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("distance 2: %+v，期望 %+v", got, want)
+	}
+
+	// 距离为 0 时只合并大小写和空白不同的写法
+	got = clusterWriteIns(counts, 0)
+	want = []WriteInSuggestion{{Into: "Pizza", Merge: []string{"pizza "}, Count: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("distance 0: %+v，期望 %+v", got, want)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"pizza", "pizza", 0},
+		{"pizza", "pizaa", 1},
+		{"pizza", "piza", 1},
+		{"披萨", "比萨", 1},
+		{"", "abc", 3},
+	} {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d，期望 %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// voteWriteIn 提交自填答案
+func voteWriteIn(t *testing.T, pollID, writeIn string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/vote", map[string]interface{}{"poll_id": pollID, "write_in": writeIn})
+}
+
+func TestWriteInSuggestionsNotAutoMerged(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "allow_write_ins": true})
+	for _, text := range []string{"Pizza", "Pizza", "pizza"} {
+		if rec := voteWriteIn(t, pollID, text); decodeBody(t, rec)["success"] != true {
+			t.Fatalf("自填投票失败: %s", rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/write-ins", nil, adminHeader...)
+	var resp struct {
+		WriteIns    []WriteInCount      `json:"write_ins"`
+		Suggestions []WriteInSuggestion `json:"suggestions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Suggestions) != 1 || resp.Suggestions[0].Into != "Pizza" || !reflect.DeepEqual(resp.Suggestions[0].Merge, []string{"pizza"}) {
+		t.Errorf("suggestions = %+v", resp.Suggestions)
+	}
+	// 只给出建议，两种写法仍分开统计
+	if !reflect.DeepEqual(resp.WriteIns, []WriteInCount{{"Pizza", 2}, {"pizza", 1}}) {
+		t.Errorf("write_ins = %+v", resp.WriteIns)
+	}
+
+	if rec := doRequest(t, http.MethodGet, "/api/poll/"+pollID+"/write-ins?distance=-1", nil, adminHeader...); rec.Code != http.StatusBadRequest {
+		t.Errorf("非法的 distance 状态码 = %d，期望 400", rec.Code)
+	}
+}
+
+// promoteWriteIn 调用转为正式选项的接口
+func promoteWriteIn(t *testing.T, pollID, text string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(t, http.MethodPost, "/api/poll/"+pollID+"/write-ins/promote", map[string]interface{}{"text": text}, headers...)
+}
+
+func TestPromoteWriteInKeepsCount(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "allow_write_ins": true})
+	mustVote(t, pollID, "a")
+	for _, text := range []string{"Pizza", "Pizza", "pizza"} {
+		if rec := voteWriteIn(t, pollID, text); decodeBody(t, rec)["success"] != true {
+			t.Fatalf("自填投票失败: %s", rec.Body.String())
+		}
+	}
+
+	if rec := promoteWriteIn(t, pollID, "Pizza"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未带管理令牌状态码 = %d，期望 401", rec.Code)
+	}
+	rec := promoteWriteIn(t, pollID, "Pizza", adminHeader...)
+	if rec.Code != http.StatusOK || decodeBody(t, rec)["success"] != true {
+		t.Fatalf("转为正式选项失败（%d）: %s", rec.Code, rec.Body.String())
+	}
+
+	poll := mustGet(t, pollID)
+	if !reflect.DeepEqual(poll.Options, []string{"a", "b", "Pizza"}) {
+		t.Errorf("options = %v，期望追加到末尾", poll.Options)
+	}
+	if !reflect.DeepEqual(poll.Votes, map[string]int{"a": 1, "b": 0, "Pizza": 2}) {
+		t.Errorf("votes = %v，期望保留自填的 2 票", poll.Votes)
+	}
+	// 原写法从审核列表移除，写法不同的仍保留
+	counts, err := store.WriteInCounts(pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, []WriteInCount{{"pizza", 1}}) {
+		t.Errorf("write_ins = %+v", counts)
+	}
+
+	// 出现在公开的投票列表中，重新计票后票数不变
+	rec = doRequest(t, http.MethodGet, "/api/polls", nil)
+	var list struct {
+		Polls []Poll `json:"polls"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range list.Polls {
+		if p.ID == pollID {
+			found = true
+			if !slices.Contains(p.Options, "Pizza") || p.Votes["Pizza"] != 2 {
+				t.Errorf("投票列表中 options = %v，votes = %v", p.Options, p.Votes)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("投票列表中没有 %s: %s", pollID, rec.Body.String())
+	}
+	if _, err := store.Recount(pollID); err != nil {
+		t.Fatalf("Recount: %v", err)
+	}
+	if got := mustGet(t, pollID).Votes["Pizza"]; got != 2 {
+		t.Errorf("重新计票后 Pizza = %d，期望 2", got)
+	}
+
+	// 之后可以像其他选项一样直接投票
+	mustVote(t, pollID, "Pizza")
+	if got := mustGet(t, pollID).Votes["Pizza"]; got != 3 {
+		t.Errorf("投票后 Pizza = %d，期望 3", got)
+	}
+}
+
+func TestPromoteWriteInErrors(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "allow_write_ins": true})
+	for _, text := range []string{"a", "x|||y"} {
+		if rec := voteWriteIn(t, pollID, text); decodeBody(t, rec)["success"] != true {
+			t.Fatalf("自填投票失败: %s", rec.Body.String())
+		}
+	}
+
+	for _, tc := range []struct {
+		text   string
+		status int
+	}{
+		{"a", http.StatusConflict},
+		{"missing", http.StatusNotFound},
+		{"x|||y", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	} {
+		if rec := promoteWriteIn(t, pollID, tc.text, adminHeader...); rec.Code != tc.status {
+			t.Errorf("转为正式选项 %q 状态码 = %d，期望 %d: %s", tc.text, rec.Code, tc.status, rec.Body.String())
+		}
+	}
+	if rec := promoteWriteIn(t, "no-such-poll", "a", adminHeader...); rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的投票状态码 = %d，期望 404", rec.Code)
+	}
+
+	// 失败不改变选项列表
+	if got := mustGet(t, pollID).Options; !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("options = %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxContentType Excel 工作簿的 MIME 类型
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// newResultsXLSX 生成投票结果工作簿：加粗的表头、各选项票数和百分比，以及票数柱状图
+func newResultsXLSX(view ResultsView) (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "Results"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	bold, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"E8EAF6"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	percentFmt := "0.0%"
+	percent, err := f.NewStyle(&excelize.Style{CustomNumFmt: &percentFmt})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.SetSheetRow(sheet, "A1", &[]interface{}{"Option", "Votes", "Percent"}); err != nil {
+		return nil, err
+	}
+	if err := f.SetCellStyle(sheet, "A1", "C1", bold); err != nil {
+		return nil, err
+	}
+	f.SetColWidth(sheet, "A", "A", 30)
+	f.SetColWidth(sheet, "B", "C", 12)
+
+	options := view.ResultOptions()
+	if view.Withheld {
+		f.SetCellValue(sheet, "A2", view.withheldNotice())
+	} else {
+		// 隐去投票人数时票数列留空，图表改用百分比列
+		series := "B"
+		if view.VoterCountWithheld() {
+			series = "C"
+		}
+		for i, option := range options {
+			var count interface{} = view.Votes[option]
+			if view.VoterCountWithheld() {
+				count = nil
+			}
+			share := view.OptionPercent(option) / 100
+			cell, _ := excelize.CoordinatesToCellName(1, i+2)
+			if err := f.SetSheetRow(sheet, cell, &[]interface{}{option, count, share}); err != nil {
+				return nil, err
+			}
+		}
+
+		last := len(options) + 1
+		if err := f.SetCellStyle(sheet, "C2", fmt.Sprintf("C%d", last), percent); err != nil {
+			return nil, err
+		}
+
+		// 隐藏图例：只有一个数据系列
+		err := f.AddChart(sheet, "E2", &excelize.Chart{
+			Type: excelize.Bar,
+			Series: []excelize.ChartSeries{{
+				Name:       fmt.Sprintf("%s!$%s$1", sheet, series),
+				Categories: fmt.Sprintf("%s!$A$2:$A$%d", sheet, last),
+				Values:     fmt.Sprintf("%s!$%s$2:$%s$%d", sheet, series, series, last),
+				Fill:       excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{strings.TrimPrefix(defaultBarColor, "#")}},
+			}},
+			Title:  []excelize.RichTextRun{{Text: view.Title}},
+			Legend: excelize.ChartLegend{Position: "none"},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 表格下方附上投票人数和状态
+	status := "Open"
+	if view.IsClosed() {
+		status = "Closed"
+	}
+	footer := len(options) + 3
+	if view.Withheld {
+		footer = 4
+	}
+	if !view.VoterCountWithheld() {
+		f.SetSheetRow(sheet, fmt.Sprintf("A%d", footer), &[]interface{}{"Voters", view.VoterCount})
+		footer++
+	}
+	f.SetSheetRow(sheet, fmt.Sprintf("A%d", footer), &[]interface{}{"Status", status})
+
+	return f.WriteToBuffer()
+}
+
+// writeResultsXLSX 以附件形式输出投票结果工作簿
+func writeResultsXLSX(w http.ResponseWriter, view ResultsView) {
+	buf, err := newResultsXLSX(view)
+	if err != nil {
+		log.Printf("生成 xlsx 失败: %v", err)
+		http.Error(w, "Failed to generate xlsx", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", xlsxContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="poll-%s.xlsx"`, view.ID))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// openResultsXLSX 下载并解析结果工作簿，返回 Results 表的全部行
+func openResultsXLSX(t *testing.T, pollID string, headers ...string) [][]string {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/api/results/"+pollID+".xlsx", nil, headers...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != xlsxContentType {
+		t.Errorf("Content-Type = %q，期望 %q", ct, xlsxContentType)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("无法解析工作簿: %v", err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestResultsXLSX(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"pizza", "sushi"}})
+	mustVote(t, pollID, "pizza")
+	mustVote(t, pollID, "pizza")
+	mustVote(t, pollID, "pizza")
+	mustVote(t, pollID, "sushi")
+
+	rows := openResultsXLSX(t, pollID)
+	want := [][]string{
+		{"Option", "Votes", "Percent"},
+		{"pizza", "3", "75.0%"},
+		{"sushi", "1", "25.0%"},
+		nil,
+		{"Voters", "4"},
+		{"Status", "Open"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\n期望 %q", rows, want)
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(doRequest(t, http.MethodGet, "/api/results/"+pollID+".xlsx", nil).Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	styleID, err := f.GetCellStyle("Results", "A1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	style, err := f.GetStyle(styleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if style.Font == nil || !style.Font.Bold {
+		t.Error("表头没有加粗")
+	}
+}
+
+func TestResultsXLSXWithheld(t *testing.T) {
+	setupTest(t)
+	pollID, _ := createTestPoll(t, map[string]interface{}{"title": "t", "options": []string{"a", "b"}, "hide_results": true})
+	mustVote(t, pollID, "a")
+
+	rows := openResultsXLSX(t, pollID)
+	if len(rows) < 2 || !reflect.DeepEqual(rows[1], []string{"Results are hidden until the poll closes."}) {
+		t.Fatalf("隐藏结果时 rows = %q，期望表头之后是说明", rows)
+	}
+	for _, row := range rows {
+		if len(row) > 0 && row[0] == "a" {
+			t.Errorf("隐藏结果时输出了选项票数: %q", row)
+		}
+	}
+}